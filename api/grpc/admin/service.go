@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"context"
+
+	"github.com/PAFFx/job-poll-queue/auth"
+	"github.com/PAFFx/job-poll-queue/proto/admin"
+	"github.com/PAFFx/job-poll-queue/queue"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Service implements the AdminService gRPC service
+type Service struct {
+	admin.UnimplementedAdminServiceServer
+	jobQueue *queue.Queue
+}
+
+// NewService creates a new admin service with the given job queue
+func NewService(jobQueue *queue.Queue) *Service {
+	return &Service{
+		jobQueue: jobQueue,
+	}
+}
+
+// PauseQueue stops workers from taking jobs
+func (s *Service) PauseQueue(ctx context.Context, req *admin.PauseQueueRequest) (*admin.QueueStats, error) {
+	if auth.FromContext(ctx).TenantID() != "" {
+		return nil, status.Errorf(codes.PermissionDenied, "tenant admins cannot pause the queue")
+	}
+	if err := s.jobQueue.Pause(); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to pause queue: %v", err)
+	}
+	return s.stats(""), nil
+}
+
+// ResumeQueue lets workers take jobs again
+func (s *Service) ResumeQueue(ctx context.Context, req *admin.ResumeQueueRequest) (*admin.QueueStats, error) {
+	if auth.FromContext(ctx).TenantID() != "" {
+		return nil, status.Errorf(codes.PermissionDenied, "tenant admins cannot resume the queue")
+	}
+	if err := s.jobQueue.Resume(); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to resume queue: %v", err)
+	}
+	return s.stats(""), nil
+}
+
+// GetQueueStats returns job counts and the paused state, tenant admins only count their own jobs
+func (s *Service) GetQueueStats(ctx context.Context, req *admin.QueueStatsRequest) (*admin.QueueStats, error) {
+	return s.stats(auth.FromContext(ctx).TenantID()), nil
+}
+
+// stats counts the jobs of a tenant, or of every tenant when it is empty
+func (s *Service) stats(tenant string) *admin.QueueStats {
+	counts := s.jobQueue.GetStatusManager().CountJobs(queue.JobFilter{Tenant: tenant})
+	var total int64
+	for _, count := range counts {
+		total += int64(count)
+	}
+	return &admin.QueueStats{
+		Total:     total,
+		Pending:   int64(counts[queue.JobStatusPending]),
+		Running:   int64(counts[queue.JobStatusProcessing]),
+		Completed: int64(counts[queue.JobStatusCompleted]),
+		Paused:    s.jobQueue.IsPaused(),
+	}
+}
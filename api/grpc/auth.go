@@ -0,0 +1,108 @@
+package grpc
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"strings"
+
+	"github.com/PAFFx/job-poll-queue/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// serviceRoles maps fully qualified service names to the role they require
+// Other services, such as reflection, are public
+var serviceRoles = map[string]auth.Role{
+	"worker.WorkerService": auth.RoleWorker,
+	"admin.AdminService":   auth.RoleAdmin,
+}
+
+// authInterceptor checks the API key of every call to a protected service
+type authInterceptor struct {
+	auth      *auth.Authenticator
+	queueName string
+}
+
+// unary authenticates unary calls
+func (a *authInterceptor) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// stream authenticates streaming calls
+func (a *authInterceptor) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authorize returns a context carrying the principal of the call
+// When authentication is disabled valid credentials still identify the caller
+func (a *authInterceptor) authorize(ctx context.Context, fullMethod string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	creds := auth.Credentials{
+		Token:       auth.Credential(firstValue(md, "authorization"), firstValue(md, "x-api-key")),
+		Certificate: clientCertificate(ctx),
+	}
+
+	if !a.auth.Enabled() {
+		if principal := a.auth.Identify(creds); principal != nil {
+			ctx = auth.NewContext(ctx, principal)
+		}
+		return ctx, nil
+	}
+	service, _, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	role, protected := serviceRoles[service]
+	if !protected {
+		return ctx, nil
+	}
+
+	principal, err := a.auth.Authorize(creds, role, a.queueName)
+	switch {
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return nil, status.Errorf(codes.PermissionDenied, "credentials do not grant the %s role on this queue", role)
+	case err != nil:
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return auth.NewContext(ctx, principal), nil
+}
+
+// clientCertificate returns the verified client certificate of the call, if any
+func clientCertificate(ctx context.Context) *x509.Certificate {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return info.State.VerifiedChains[0][0]
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// authenticatedStream replaces the context of a stream with one carrying the principal
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
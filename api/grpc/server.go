@@ -1,14 +1,19 @@
 package grpc
 
 import (
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net"
 
+	"github.com/PAFFx/job-poll-queue/api/grpc/admin"
 	"github.com/PAFFx/job-poll-queue/api/grpc/worker"
+	"github.com/PAFFx/job-poll-queue/auth"
+	adminpb "github.com/PAFFx/job-poll-queue/proto/admin"
 	pb "github.com/PAFFx/job-poll-queue/proto/worker"
 	"github.com/PAFFx/job-poll-queue/queue"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
 )
 
@@ -19,21 +24,40 @@ type Server struct {
 	port       string
 }
 
-// NewServer creates a new gRPC server with the provided job queue
-func NewServer(jobQueue *queue.Queue, port string) *Server {
-	grpcServer := grpc.NewServer()
+// Config holds the settings of the gRPC server
+type Config struct {
+	Port   string
+	TLS    *tls.Config // Plain text when nil
+	Worker worker.Config
+}
+
+// NewServer creates a new gRPC server with the provided job queue and authenticator
+func NewServer(jobQueue *queue.Queue, authenticator *auth.Authenticator, config Config) *Server {
+	interceptor := &authInterceptor{auth: authenticator, queueName: jobQueue.GetName()}
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(interceptor.unary),
+		grpc.ChainStreamInterceptor(interceptor.stream),
+	}
+	if config.TLS != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(config.TLS)))
+	}
+	grpcServer := grpc.NewServer(options...)
 
 	// Create and register the worker service
-	workerService := worker.NewService(jobQueue)
+	workerService := worker.NewService(jobQueue, config.Worker)
 	pb.RegisterWorkerServiceServer(grpcServer, workerService)
 
+	// Create and register the admin service
+	adminService := admin.NewService(jobQueue)
+	adminpb.RegisterAdminServiceServer(grpcServer, adminService)
+
 	// Register reflection service for development tools like grpcurl
 	reflection.Register(grpcServer)
 
 	return &Server{
 		grpcServer: grpcServer,
 		jobQueue:   jobQueue,
-		port:       port,
+		port:       config.Port,
 	}
 }
 
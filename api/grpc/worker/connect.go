@@ -0,0 +1,314 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/PAFFx/job-poll-queue/auth"
+	"github.com/PAFFx/job-poll-queue/proto/worker"
+	"github.com/PAFFx/job-poll-queue/queue"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxConcurrency bounds the jobs one Connect stream may hold at once
+const maxConcurrency = 1000
+
+// session is a worker connected through a Connect stream
+type session struct {
+	jobQueue *queue.Queue
+	service  *Service
+	stream   worker.WorkerService_ConnectServer
+	workerID string
+	filter   queue.JobFilter
+	slots    chan struct{} // One token per job the worker is processing
+	sendMu   sync.Mutex    // Jobs and acks are sent from different goroutines
+	mu       sync.Mutex
+	held     map[string]string // Lease tokens of the jobs pushed to the worker and not finished yet
+}
+
+// Connect runs a worker session: jobs are pushed while the worker has free capacity,
+// and the jobs it still holds are put back in the queue when the stream ends
+func (s *Service) Connect(stream worker.WorkerService_ConnectServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	hello := first.GetHello()
+	if hello == nil {
+		return status.Error(codes.InvalidArgument, "the first message must be a hello")
+	}
+	concurrency := max(int(hello.Concurrency), 1)
+	if concurrency > maxConcurrency {
+		return status.Errorf(codes.InvalidArgument, "concurrency must not exceed %d", maxConcurrency)
+	}
+	if err := queue.Requirements(hello.Capabilities).Validate(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// The worker stays listed as connected until the stream ends
+	tenant := auth.FromContext(stream.Context()).TenantID()
+	info := queue.WorkerInfo{ID: hello.WorkerId, Hostname: hello.Hostname, Version: hello.Version, Labels: hello.Labels}
+	if _, err := s.jobQueue.ConnectWorker(info, tenant); err != nil {
+		return workerStatus(err)
+	}
+	defer s.jobQueue.DisconnectWorker(hello.WorkerId)
+
+	// Workers scoped to a tenant only receive that tenant's jobs, and only jobs they are capable of
+	sess := &session{
+		jobQueue: s.jobQueue,
+		service:  s,
+		stream:   stream,
+		workerID: hello.WorkerId,
+		filter:   queue.JobFilter{Tenant: tenant, Capabilities: hello.Capabilities},
+		slots:    make(chan struct{}, concurrency),
+		held:     make(map[string]string),
+	}
+	log.Printf("Worker %s connected with concurrency %d", sess.workerID, concurrency)
+
+	ctx, cancel := context.WithCancelCause(stream.Context())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sess.pushJobs(ctx, cancel)
+	}()
+
+	err = sess.receive(ctx)
+	cancel(err)
+	wg.Wait()
+
+	released := sess.releaseAll()
+	log.Printf("Worker %s disconnected, %d jobs released: %v", sess.workerID, released, err)
+	return err
+}
+
+// pushJobs sends jobs to the worker whenever it has a free slot, until the session ends
+func (sess *session) pushJobs(ctx context.Context, cancel context.CancelCauseFunc) {
+	for {
+		select {
+		case sess.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		msg, err := sess.jobQueue.PopWait(ctx, sess.workerID, sess.filter)
+		if err != nil {
+			cancel(status.Errorf(codes.Internal, "failed to retrieve job: %v", err))
+			return
+		}
+		if msg == nil {
+			<-sess.slots
+			continue
+		}
+
+		// Held first, so a broken stream releases the job
+		sess.hold(msg.ID, msg.LeaseToken)
+		if err := sess.send(&worker.ServerMessage{Job: toJob(msg)}); err != nil {
+			cancel(err)
+			return
+		}
+	}
+}
+
+// receive handles worker messages until the stream ends, fails or stays silent past the heartbeat timeout
+func (sess *session) receive(ctx context.Context) error {
+	incoming := make(chan *worker.WorkerMessage)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := sess.stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case incoming <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	timeout := sess.service.config.HeartbeatTimeout
+	var silence <-chan time.Time
+	var timer *time.Timer
+	if timeout > 0 {
+		timer = time.NewTimer(timeout)
+		defer timer.Stop()
+		silence = timer.C
+	}
+
+	for {
+		select {
+		case msg := <-incoming:
+			if timer != nil {
+				timer.Reset(timeout)
+			}
+			sess.jobQueue.TouchWorker(sess.workerID)
+			if err := sess.handle(ctx, msg); err != nil {
+				return err
+			}
+		case err := <-recvErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case <-silence:
+			return status.Errorf(codes.DeadlineExceeded, "no message from the worker within %s", timeout)
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+	}
+}
+
+// handle processes one worker message, errors end the session
+func (sess *session) handle(ctx context.Context, msg *worker.WorkerMessage) error {
+	switch {
+	case msg.Hello != nil:
+		return status.Error(codes.InvalidArgument, "hello may only be sent once")
+	case msg.Progress != nil:
+		return sess.progress(msg.Progress)
+	case msg.Result != nil:
+		return sess.complete(ctx, msg.Result)
+	case msg.Failure != nil:
+		return sess.fail(ctx, msg.Failure)
+	default:
+		// Heartbeats renew the leases of every held job
+		sess.extendLeases()
+		return nil
+	}
+}
+
+// progress records the progress of a job held by the session and renews its lease, rejections are acknowledged
+func (sess *session) progress(progress *worker.Progress) error {
+	var err error
+	if leaseToken, ok := sess.leaseToken(progress.JobId); !ok {
+		err = status.Errorf(codes.NotFound, "job %s is not held by this session", progress.JobId)
+	} else if _, err = sess.jobQueue.ExtendLease(progress.JobId, leaseToken, &queue.Progress{Percent: int(progress.Percent), Message: progress.Message}); err != nil {
+		err = leaseStatus(progress.JobId, err, "record progress")
+	}
+	if err == nil {
+		return nil
+	}
+	return sess.send(&worker.ServerMessage{Ack: &worker.Ack{JobId: progress.JobId, Error: status.Convert(err).Message()}})
+}
+
+// complete stores a result like CompleteJob and acknowledges it, freeing the job's slot once stored
+// Results without a lease token are stored under the lease the session holds
+func (sess *session) complete(ctx context.Context, result *worker.JobResult) error {
+	ack := &worker.Ack{JobId: result.JobId, Success: true}
+	leaseToken, ok := sess.leaseToken(result.JobId)
+	if !ok {
+		ack.Success = false
+		ack.Error = "job " + result.JobId + " is not held by this session"
+		return sess.send(&worker.ServerMessage{Ack: ack})
+	}
+	if result.LeaseToken == "" {
+		result.LeaseToken = leaseToken
+	}
+
+	if _, err := sess.service.CompleteJob(ctx, result); err != nil {
+		ack.Success = false
+		ack.Error = status.Convert(err).Message()
+	} else {
+		sess.finish(result.JobId)
+	}
+	return sess.send(&worker.ServerMessage{Ack: ack})
+}
+
+// fail records a failure like FailJob and acknowledges it, freeing the job's slot once recorded
+// Failures without a lease token are recorded under the lease the session holds
+func (sess *session) fail(ctx context.Context, failure *worker.FailRequest) error {
+	ack := &worker.Ack{JobId: failure.JobId, Success: true}
+	leaseToken, ok := sess.leaseToken(failure.JobId)
+	if !ok {
+		ack.Success = false
+		ack.Error = "job " + failure.JobId + " is not held by this session"
+		return sess.send(&worker.ServerMessage{Ack: ack})
+	}
+	if failure.LeaseToken == "" {
+		failure.LeaseToken = leaseToken
+	}
+
+	if _, err := sess.service.FailJob(ctx, failure); err != nil {
+		ack.Success = false
+		ack.Error = status.Convert(err).Message()
+	} else {
+		sess.finish(failure.JobId)
+	}
+	return sess.send(&worker.ServerMessage{Ack: ack})
+}
+
+func (sess *session) send(msg *worker.ServerMessage) error {
+	sess.sendMu.Lock()
+	defer sess.sendMu.Unlock()
+	return sess.stream.Send(msg)
+}
+
+func (sess *session) hold(jobID string, leaseToken string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.held[jobID] = leaseToken
+}
+
+// leaseToken returns the token of a job held by the session
+func (sess *session) leaseToken(jobID string) (string, bool) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	leaseToken, ok := sess.held[jobID]
+	return leaseToken, ok
+}
+
+// finish forgets a held job and frees its slot
+func (sess *session) finish(jobID string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if _, ok := sess.held[jobID]; ok {
+		delete(sess.held, jobID)
+		<-sess.slots
+	}
+}
+
+// extendLeases renews the leases of held jobs, and frees the slots of jobs the session lost,
+// such as jobs deleted by an admin or released after their lease expired
+func (sess *session) extendLeases() {
+	for jobID, leaseToken := range sess.heldJobs() {
+		if _, err := sess.jobQueue.ExtendLease(jobID, leaseToken, nil); leaseLost(err) {
+			sess.finish(jobID)
+		}
+	}
+}
+
+// heldJobs returns a copy of the held jobs and their lease tokens
+func (sess *session) heldJobs() map[string]string {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return maps.Clone(sess.held)
+}
+
+// releaseAll puts the unfinished jobs of the session back in the queue and returns how many were released
+func (sess *session) releaseAll() int {
+	released := 0
+	for jobID, leaseToken := range sess.heldJobs() {
+		err := sess.jobQueue.Release(jobID, leaseToken)
+		switch {
+		case err == nil:
+			released++
+		case !leaseLost(err):
+			log.Printf("Failed to release job %s of worker %s: %v", jobID, sess.workerID, err)
+		}
+	}
+	return released
+}
+
+// leaseLost reports whether an error means the job is no longer held under the session's lease
+func leaseLost(err error) bool {
+	return errors.Is(err, queue.ErrJobNotFound) || errors.Is(err, queue.ErrInvalidJobState) ||
+		errors.Is(err, queue.ErrLeaseExpired) || errors.Is(err, queue.ErrLeaseMismatch)
+}
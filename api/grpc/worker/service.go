@@ -2,9 +2,14 @@ package worker
 
 import (
 	"context"
+	"errors"
+	"time"
+	"unicode/utf8"
 
+	"github.com/PAFFx/job-poll-queue/auth"
 	"github.com/PAFFx/job-poll-queue/proto/worker"
 	"github.com/PAFFx/job-poll-queue/queue"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -13,19 +18,41 @@ import (
 type Service struct {
 	worker.UnimplementedWorkerServiceServer
 	jobQueue *queue.Queue
+	config   Config
+}
+
+// Config holds the limits of the worker service
+type Config struct {
+	MaxPollWait      time.Duration // Longest a job request may wait for a job
+	HeartbeatTimeout time.Duration // Connect streams silent for longer are closed and their jobs released
 }
 
 // NewService creates a new worker service with the given job queue
-func NewService(jobQueue *queue.Queue) *Service {
+func NewService(jobQueue *queue.Queue, config Config) *Service {
 	return &Service{
 		jobQueue: jobQueue,
+		config:   config,
 	}
 }
 
 // RequestJob handles job requests from workers
 func (s *Service) RequestJob(ctx context.Context, req *worker.JobRequest) (*worker.Job, error) {
-	// Pop a job from the queue
-	msg, err := s.jobQueue.Pop()
+	// Pop a job from the queue, workers scoped to a tenant only receive that tenant's jobs
+	filter := queue.JobFilter{Tenant: auth.FromContext(ctx).TenantID(), Capabilities: req.Capabilities}
+	if err := queue.Requirements(req.Capabilities).Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Identified workers are registered, and the job is leased to them
+	var workerID string
+	if req.Worker != nil {
+		if _, err := s.jobQueue.RegisterWorker(toWorkerInfo(req.Worker), filter.Tenant); err != nil {
+			return nil, workerStatus(err)
+		}
+		workerID = req.Worker.WorkerId
+	}
+
+	msg, err := s.popJob(ctx, workerID, filter, time.Duration(req.WaitMs)*time.Millisecond)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to retrieve job: %v", err)
 	}
@@ -35,30 +62,204 @@ func (s *Service) RequestJob(ctx context.Context, req *worker.JobRequest) (*work
 		return nil, status.Errorf(codes.NotFound, "no jobs available")
 	}
 
-	// Convert queue.Message to worker.Job
+	return toJob(msg), nil
+}
+
+// toWorkerInfo converts the identity a worker sends into the queue's
+func toWorkerInfo(info *worker.WorkerInfo) queue.WorkerInfo {
+	return queue.WorkerInfo{
+		ID:       info.WorkerId,
+		Hostname: info.Hostname,
+		Version:  info.Version,
+		Labels:   info.Labels,
+	}
+}
+
+// workerStatus converts a worker registration error into a gRPC status
+func workerStatus(err error) error {
+	if errors.Is(err, queue.ErrWorkerTenant) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// toJob converts a queued message into the job sent to workers
+func toJob(msg *queue.Message) *worker.Job {
 	job := &worker.Job{
-		Id:      msg.ID,
-		Payload: msg.Payload,
-		Headers: msg.Headers,
+		Id:           msg.ID,
+		Headers:      msg.Headers,
+		PayloadBytes: msg.Payload,
+		ContentType:  msg.ContentType,
+		LeaseToken:   msg.LeaseToken,
+	}
+
+	// Proxied jobs carry the original HTTP request
+	if msg.Request != nil {
+		job.Request = &worker.HttpRequest{
+			Method: msg.Request.Method,
+			Path:   msg.Request.Path,
+			Query:  msg.Request.Query,
+		}
 	}
 
-	return job, nil
+	// Keep the text field populated for workers that only read strings
+	if utf8.Valid(msg.Payload) {
+		job.Payload = string(msg.Payload)
+	}
+
+	return job
+}
+
+// popJob takes the next job, waiting up to wait for one when the queue has none
+// The wait ends early when the worker cancels the call
+func (s *Service) popJob(ctx context.Context, workerID string, filter queue.JobFilter, wait time.Duration) (*queue.Message, error) {
+	if wait = min(wait, s.config.MaxPollWait); wait <= 0 {
+		return s.jobQueue.Pop(workerID, filter)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	job, err := s.jobQueue.PopWait(waitCtx, workerID, filter)
+	if job != nil && ctx.Err() != nil {
+		// Handed over as the worker cancelled the call, the job goes back to the queue
+		return nil, s.jobQueue.Release(job.ID, job.LeaseToken)
+	}
+	return job, err
 }
 
 // CompleteJob handles job completion reports from workers
 func (s *Service) CompleteJob(ctx context.Context, result *worker.JobResult) (*worker.CompleteResponse, error) {
-	// Get the job status manager
-	statusMgr := s.jobQueue.GetStatusManager()
+	// Prefer the raw bytes, fall back to the text payload
+	payload := result.PayloadBytes
+	if len(payload) == 0 {
+		payload = []byte(result.Payload)
+	}
+
+	// Status codes outside the HTTP range would break transparent submitters
+	if result.StatusCode != 0 && (result.StatusCode < 100 || result.StatusCode > 599) {
+		return &worker.CompleteResponse{
+			Success: false,
+		}, status.Errorf(codes.InvalidArgument, "invalid status code: %d", result.StatusCode)
+	}
+
+	// Workers scoped to a tenant cannot complete other tenants' jobs
+	if tenant := auth.FromContext(ctx).TenantID(); tenant != "" {
+		if job, err := s.jobQueue.GetStatusManager().GetJobStatus(result.JobId); err != nil || job.Tenant != tenant {
+			return &worker.CompleteResponse{
+				Success: false,
+			}, status.Errorf(codes.NotFound, "job not found: %s", result.JobId)
+		}
+	}
 
-	// Submit the result to the status manager
-	err := statusMgr.SubmitResult(result.JobId, result.Payload, nil)
+	// Store the result under the worker's lease, checking it against the queue's result schema
+	err := s.jobQueue.Complete(result.JobId, result.LeaseToken, queue.JobResult{
+		Payload:     payload,
+		ContentType: result.ContentType,
+		StatusCode:  int(result.StatusCode),
+		Headers:     result.Headers,
+	})
+	var schemaErr *queue.SchemaError
+	if errors.As(err, &schemaErr) {
+		return &worker.CompleteResponse{
+			Success: false,
+		}, schemaViolation(schemaErr)
+	}
 	if err != nil {
 		return &worker.CompleteResponse{
 			Success: false,
-		}, status.Errorf(codes.Internal, "failed to save job result: %v", err)
+		}, leaseStatus(result.JobId, err, "save job result")
 	}
 
 	return &worker.CompleteResponse{
 		Success: true,
 	}, nil
 }
+
+// FailJob finishes a job the worker could not process under the worker's lease
+func (s *Service) FailJob(ctx context.Context, req *worker.FailRequest) (*worker.CompleteResponse, error) {
+	if err := queue.ValidateFailure(req.Error); err != nil {
+		return &worker.CompleteResponse{
+			Success: false,
+		}, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Workers scoped to a tenant cannot fail other tenants' jobs
+	if tenant := auth.FromContext(ctx).TenantID(); tenant != "" {
+		if job, err := s.jobQueue.GetStatusManager().GetJobStatus(req.JobId); err != nil || job.Tenant != tenant {
+			return &worker.CompleteResponse{
+				Success: false,
+			}, status.Errorf(codes.NotFound, "job not found: %s", req.JobId)
+		}
+	}
+
+	if err := s.jobQueue.Fail(req.JobId, req.LeaseToken, req.Error); err != nil {
+		return &worker.CompleteResponse{
+			Success: false,
+		}, leaseStatus(req.JobId, err, "fail job")
+	}
+
+	return &worker.CompleteResponse{
+		Success: true,
+	}, nil
+}
+
+// ExtendLease renews the lease of a job being processed and records its progress when reported
+func (s *Service) ExtendLease(ctx context.Context, req *worker.LeaseRequest) (*worker.LeaseResponse, error) {
+	// Workers scoped to a tenant cannot extend other tenants' jobs
+	if tenant := auth.FromContext(ctx).TenantID(); tenant != "" {
+		if job, err := s.jobQueue.GetStatusManager().GetJobStatus(req.JobId); err != nil || job.Tenant != tenant {
+			return nil, status.Errorf(codes.NotFound, "job not found: %s", req.JobId)
+		}
+	}
+
+	var progress *queue.Progress
+	if req.Percent > 0 || req.Message != "" {
+		progress = &queue.Progress{Percent: int(req.Percent), Message: req.Message}
+		if err := progress.Validate(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	job, err := s.jobQueue.ExtendLease(req.JobId, req.LeaseToken, progress)
+	if err != nil {
+		return nil, leaseStatus(req.JobId, err, "extend lease")
+	}
+
+	response := &worker.LeaseResponse{}
+	if job.LeaseExpiresAt != nil {
+		response.LeaseExpiresAt = job.LeaseExpiresAt.UnixMilli()
+	}
+	return response, nil
+}
+
+// leaseStatus converts the errors of calls made under a lease into gRPC statuses
+func leaseStatus(jobID string, err error, action string) error {
+	switch {
+	case errors.Is(err, queue.ErrJobNotFound):
+		return status.Errorf(codes.NotFound, "job not found: %s", jobID)
+	case errors.Is(err, queue.ErrLeaseTokenRequired):
+		return status.Error(codes.InvalidArgument, "lease_token is required")
+	case errors.Is(err, queue.ErrLeaseExpired), errors.Is(err, queue.ErrLeaseMismatch):
+		return status.Errorf(codes.FailedPrecondition, "job %s: %v", jobID, err)
+	case errors.Is(err, queue.ErrInvalidJobState):
+		return status.Errorf(codes.FailedPrecondition, "job %s is already finished", jobID)
+	default:
+		return status.Errorf(codes.Internal, "failed to %s: %v", action, err)
+	}
+}
+
+// schemaViolation converts a schema error into InvalidArgument with one field violation per error
+func schemaViolation(err *queue.SchemaError) error {
+	st := status.New(codes.InvalidArgument, err.Error())
+	violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(err.Errors))
+	for _, fieldErr := range err.Errors {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       fieldErr.Path,
+			Description: fieldErr.Message,
+		})
+	}
+	if detailed, detailErr := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); detailErr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PAFFx/job-poll-queue/proto/worker"
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+// newTestService creates a worker service over a queue stored in a temporary directory
+func newTestService(t *testing.T) (*Service, *queue.Queue) {
+	t.Helper()
+	q, err := queue.NewQueue("test", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+	return NewService(q, Config{MaxPollWait: time.Minute}), q
+}
+
+// checkQueued fails the test unless the job is pending again and the next poll takes it
+func checkQueued(t *testing.T, q *queue.Queue, jobID string) {
+	t.Helper()
+	status, err := q.GetStatusManager().GetJobStatus(jobID)
+	if err != nil || status.Status != queue.JobStatusPending || status.LeaseToken != "" {
+		t.Fatalf("status = %+v, %v, want pending without a lease", status, err)
+	}
+	if job, err := q.Pop("", queue.JobFilter{}); err != nil || job == nil || job.ID != jobID {
+		t.Fatalf("Pop = %v, %v, want %s", job, err, jobID)
+	}
+}
+
+func TestRequestJobReleasesJobHandedOverAfterCancel(t *testing.T) {
+	s, q := newTestService(t)
+	if err := q.Push(queue.Message{ID: "job", Payload: []byte("x")}); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	// The job is handed to the waiting call although the worker has already gone
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if job, err := s.RequestJob(ctx, &worker.JobRequest{WaitMs: 60000}); err == nil {
+		t.Fatalf("RequestJob = %s, want no job for a cancelled call", job.Id)
+	}
+	checkQueued(t, q, "job")
+}
+
+func TestRequestJobCancelledDuringPush(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		s, q := newTestService(t)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		var job *worker.Job
+		var err error
+		polled := make(chan struct{})
+		go func() {
+			defer close(polled)
+			job, err = s.RequestJob(ctx, &worker.JobRequest{WaitMs: 60000})
+		}()
+		time.Sleep(time.Millisecond)
+
+		// The push races the waiting call noticing the cancel, a job handed over meanwhile goes back
+		cancel()
+		if err := q.Push(queue.Message{ID: "job", Payload: []byte("x")}); err != nil {
+			t.Fatalf("Push: %v", err)
+		}
+		<-polled
+
+		if err == nil {
+			t.Fatalf("RequestJob = %s, want no job for a cancelled call", job.Id)
+		}
+		checkQueued(t, q, "job")
+	}
+}
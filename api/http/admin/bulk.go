@@ -0,0 +1,122 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+// Bulk actions applied to every job matching a filter
+const (
+	BulkRequeue    = "requeue"
+	BulkDelete     = "delete"
+	BulkMoveFront  = "move_front"
+	BulkMoveBack   = "move_back"
+	BulkReschedule = "reschedule"
+)
+
+// ErrUnknownBulkAction is returned for bulk requests with an unsupported action
+var ErrUnknownBulkAction = errors.New("unknown bulk action")
+
+// ScheduleRequest is the body of a priority or schedule change
+// scheduled_at takes an RFC 3339 time, or null to make the job due immediately
+type ScheduleRequest struct {
+	Priority    *int            `json:"priority"`
+	ScheduledAt json.RawMessage `json:"scheduled_at"`
+}
+
+// Schedule converts the request into a queue schedule change
+func (r ScheduleRequest) Schedule() (queue.Schedule, error) {
+	schedule := queue.Schedule{Priority: r.Priority}
+	if len(r.ScheduledAt) == 0 {
+		return schedule, nil
+	}
+	if string(r.ScheduledAt) == "null" {
+		schedule.ClearSchedule = true
+		return schedule, nil
+	}
+
+	var scheduledAt time.Time
+	if err := json.Unmarshal(r.ScheduledAt, &scheduledAt); err != nil {
+		return schedule, errors.New("invalid scheduled_at, expected RFC 3339")
+	}
+	schedule.ScheduledAt = &scheduledAt
+	return schedule, nil
+}
+
+// BulkRequest selects jobs with a filter and applies one action to them
+type BulkRequest struct {
+	ScheduleRequest
+	Action string          `json:"action"`
+	Filter queue.JobFilter `json:"filter"`
+}
+
+// BulkFailure records a job the action could not be applied to
+type BulkFailure struct {
+	JobID string `json:"job_id"`
+	Error string `json:"error"`
+}
+
+// BulkResult summarizes a bulk action
+type BulkResult struct {
+	Action   string        `json:"action"`
+	Matched  int           `json:"matched"`
+	Affected int           `json:"affected"`
+	Failed   []BulkFailure `json:"failed,omitempty"`
+}
+
+// ApplyBulk applies the requested action to every matching job, each change is persisted
+func (h *Handler) ApplyBulk(req BulkRequest) (*BulkResult, error) {
+	var apply func(jobID string) error
+	switch req.Action {
+	case BulkRequeue:
+		apply = func(jobID string) error {
+			_, err := h.jobQueue.Requeue(jobID)
+			return err
+		}
+	case BulkDelete:
+		apply = h.jobQueue.Delete
+	case BulkMoveFront, BulkMoveBack:
+		position := queue.PositionFront
+		if req.Action == BulkMoveBack {
+			position = queue.PositionBack
+		}
+		apply = func(jobID string) error {
+			return h.jobQueue.Move(jobID, position)
+		}
+	case BulkReschedule:
+		schedule, err := req.Schedule()
+		if err != nil {
+			return nil, err
+		}
+		apply = func(jobID string) error {
+			_, err := h.jobQueue.Reschedule(jobID, schedule)
+			return err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBulkAction, req.Action)
+	}
+
+	jobs, _ := h.jobQueue.GetStatusManager().ListJobs(req.Filter, nil, math.MaxInt)
+
+	// Moving to the front in listing order would reverse the jobs, so walk backwards
+	if req.Action == BulkMoveFront {
+		for i, j := 0, len(jobs)-1; i < j; i, j = i+1, j-1 {
+			jobs[i], jobs[j] = jobs[j], jobs[i]
+		}
+	}
+
+	result := &BulkResult{Action: req.Action, Matched: len(jobs)}
+	for _, job := range jobs {
+		if err := apply(job.ID); err != nil {
+			result.Failed = append(result.Failed, BulkFailure{JobID: job.ID, Error: err.Error()})
+			continue
+		}
+		result.Affected++
+	}
+	return result, nil
+}
@@ -0,0 +1,29 @@
+package admin
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+// GetFairnessHandler returns the fairness policy of the queue
+func (h *Handler) GetFairnessHandler(c *fiber.Ctx) error {
+	return c.JSON(h.jobQueue.GetFairness())
+}
+
+// SetFairnessHandler replaces the fairness policy, turns between keys start over
+func (h *Handler) SetFairnessHandler(c *fiber.Ctx) error {
+	var fairness queue.Fairness
+	if err := c.BodyParser(&fairness); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidFairness, "Invalid fairness policy: "+err.Error())
+	}
+	if err := fairness.Validate(); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidFairness, err.Error())
+	}
+
+	if err := h.jobQueue.SetFairness(fairness); err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to save fairness policy")
+	}
+	return c.JSON(h.jobQueue.GetFairness())
+}
@@ -1,31 +1,135 @@
 package admin
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v2"
 
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/api/http/events"
+	"github.com/PAFFx/job-poll-queue/auth"
 	"github.com/PAFFx/job-poll-queue/queue"
+	"github.com/PAFFx/job-poll-queue/webhook"
 )
 
 type Handler struct {
 	jobQueue *queue.Queue
+	webhooks *webhook.Dispatcher
+	keys     *auth.Keyring
 }
 
-func NewHandler(jobQueue *queue.Queue) *Handler {
-	return &Handler{jobQueue: jobQueue}
+func NewHandler(jobQueue *queue.Queue, webhooks *webhook.Dispatcher, keys *auth.Keyring) *Handler {
+	return &Handler{jobQueue: jobQueue, webhooks: webhooks, keys: keys}
 }
 
 func (h *Handler) RegisterRoutes(router fiber.Router) {
 	router.Get("/stats", h.GetQueueStatsHandler)
 	router.Delete("/clear", h.ClearQueueHandler)
+	router.Post("/purge", h.PurgeJobsHandler)
+	router.Post("/pause", h.RequireGlobalAdmin, h.PauseQueueHandler)
+	router.Post("/resume", h.RequireGlobalAdmin, h.ResumeQueueHandler)
+	router.Get("/next", h.PeekJobHandler)
+	router.Get("/jobs", h.ListJobsHandler)
+	router.Post("/jobs/bulk", h.BulkJobsHandler)
+	router.Get("/jobs/:id", h.GetJobHandler)
+	router.Patch("/jobs/:id", h.UpdateJobHandler)
+	router.Delete("/jobs/:id", h.DeleteJobHandler)
+	router.Post("/jobs/:id/requeue", h.RequeueJobHandler)
+	router.Post("/jobs/:id/move", h.MoveJobHandler)
+	router.Get("/events", h.EventsHandler)
+	router.Get("/schemas", h.GetSchemasHandler)
+	router.Put("/schemas", h.RequireGlobalAdmin, h.SetSchemasHandler)
+	router.Delete("/schemas", h.RequireGlobalAdmin, h.DeleteSchemasHandler)
+	router.Get("/limits", h.GetLimitsHandler)
+	router.Put("/limits", h.RequireGlobalAdmin, h.SetLimitsHandler)
+	router.Get("/fairness", h.GetFairnessHandler)
+	router.Put("/fairness", h.RequireGlobalAdmin, h.SetFairnessHandler)
+	router.Get("/headers", h.GetHeaderRulesHandler)
+	router.Put("/headers", h.RequireGlobalAdmin, h.SetHeaderRulesHandler)
+	router.Delete("/headers", h.RequireGlobalAdmin, h.DeleteHeaderRulesHandler)
+	router.Get("/keys", h.RequireGlobalAdmin, h.ListKeysHandler)
+	router.Post("/keys", h.RequireGlobalAdmin, h.CreateKeyHandler)
+	router.Delete("/keys/:id", h.RequireGlobalAdmin, h.RevokeKeyHandler)
+	router.Get("/tenants", h.RequireGlobalAdmin, h.ListTenantsHandler)
+	router.Get("/tenants/:id", h.GetTenantHandler)
+	router.Put("/tenants/:id", h.RequireGlobalAdmin, h.SetTenantHandler)
+	router.Delete("/tenants/:id", h.RequireGlobalAdmin, h.DeleteTenantHandler)
+	router.Get("/workers", h.ListWorkersHandler)
+	router.Get("/workers/:id", h.GetWorkerHandler)
+	router.Post("/workers/:id/drain", h.DrainWorkerHandler)
+	router.Post("/workers/:id/resume", h.ResumeWorkerHandler)
+	router.Post("/workers/:id/release", h.ReleaseWorkerHandler)
+	router.Get("/webhooks", h.RequireGlobalAdmin, h.ListWebhooksHandler)
+	router.Get("/webhooks/:id", h.RequireGlobalAdmin, h.GetWebhookHandler)
+	router.Post("/webhooks/:id/replay", h.RequireGlobalAdmin, h.ReplayWebhookHandler)
 }
 
+// GetQueueStatsHandler returns job counts, only those of the caller's tenant for tenant admins
 func (h *Handler) GetQueueStatsHandler(c *fiber.Ctx) error {
-	return c.JSON(h.GetQueueStatistics())
+	return c.JSON(h.GetQueueStatistics(h.TenantScope(c)))
 }
 
+// ClearQueueHandler removes every job, ?dry_run=true reports what would be removed instead
+// Tenant admins only clear their own tenant's jobs
 func (h *Handler) ClearQueueHandler(c *fiber.Ctx) error {
-	if err := h.ClearQueue(); err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to clear queue")
+	dryRun := c.QueryBool("dry_run")
+	report, err := h.ClearQueue(h.TenantScope(c), dryRun)
+	if err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to clear queue")
+	}
+
+	message := "Queue cleared successfully"
+	if dryRun {
+		message = "Dry run, no jobs were removed"
+	}
+	return c.JSON(fiber.Map{"message": message, "report": report})
+}
+
+// PurgeJobsHandler removes the jobs matching a status, age or header filter
+func (h *Handler) PurgeJobsHandler(c *fiber.Ctx) error {
+	var req PurgeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body")
+	}
+
+	filter, err := req.JobFilter()
+	if errors.Is(err, ErrConfirmationRequired) {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeConfirmationRequired, err.Error())
+	}
+	if err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidFilter, err.Error())
+	}
+	if tenant := callerTenant(c); tenant != "" {
+		filter.Tenant = tenant
 	}
-	return c.JSON(fiber.Map{"message": "Queue cleared successfully"})
+
+	report, err := h.PurgeJobs(filter, req.DryRun)
+	if err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to purge jobs")
+	}
+	return c.JSON(report)
+}
+
+func (h *Handler) PauseQueueHandler(c *fiber.Ctx) error {
+	if err := h.jobQueue.Pause(); err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to pause queue")
+	}
+	return c.JSON(fiber.Map{"message": "Queue paused", "paused": true})
+}
+
+func (h *Handler) ResumeQueueHandler(c *fiber.Ctx) error {
+	if err := h.jobQueue.Resume(); err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to resume queue")
+	}
+	return c.JSON(fiber.Map{"message": "Queue resumed", "paused": false})
+}
+
+// EventsHandler streams lifecycle events of all jobs over SSE or WebSocket
+// Filter with the comma separated type, status and job_id query parameters, and tenant
+func (h *Handler) EventsHandler(c *fiber.Ctx) error {
+	filter := events.ParseFilter(c)
+	filter.Tenant = h.TenantScope(c)
+	return events.Stream(c, h.jobQueue.GetEventBus(), events.Options{
+		Filter: filter,
+	})
 }
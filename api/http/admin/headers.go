@@ -0,0 +1,45 @@
+package admin
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+// GetHeaderRulesHandler returns the rules applied to headers captured with new jobs
+func (h *Handler) GetHeaderRulesHandler(c *fiber.Ctx) error {
+	return c.JSON(headerRules(h.jobQueue.GetHeaderRules()))
+}
+
+// SetHeaderRulesHandler replaces the header rules of the queue, jobs already queued keep their headers
+func (h *Handler) SetHeaderRulesHandler(c *fiber.Ctx) error {
+	var rules queue.HeaderRules
+	if err := c.BodyParser(&rules); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidHeaderRules, "Invalid header rules: "+err.Error())
+	}
+	if err := rules.Validate(); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidHeaderRules, err.Error())
+	}
+
+	if err := h.jobQueue.SetHeaderRules(&rules); err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to save header rules")
+	}
+	return c.JSON(headerRules(h.jobQueue.GetHeaderRules()))
+}
+
+// DeleteHeaderRulesHandler goes back to the header rules set by the environment
+func (h *Handler) DeleteHeaderRulesHandler(c *fiber.Ctx) error {
+	if err := h.jobQueue.SetHeaderRules(nil); err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to save header rules")
+	}
+	return c.JSON(headerRules(h.jobQueue.GetHeaderRules()))
+}
+
+// headerRules spells out a queue without rules as rules that keep every header
+func headerRules(rules *queue.HeaderRules) queue.HeaderRules {
+	if rules == nil {
+		return queue.HeaderRules{}
+	}
+	return *rules
+}
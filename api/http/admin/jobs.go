@@ -0,0 +1,158 @@
+package admin
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+// ListJobsHandler lists jobs matching the query filters, one page at a time
+func (h *Handler) ListJobsHandler(c *fiber.Ctx) error {
+	filter, err := h.ParseJobFilter(c)
+	if err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidFilter, err.Error())
+	}
+
+	limit := c.QueryInt("limit", defaultPageSize)
+	if limit < 1 || limit > maxPageSize {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed, "limit must be between 1 and 500")
+	}
+
+	cursor, err := DecodeCursor(c.Query("cursor"))
+	if err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidCursor, err.Error())
+	}
+
+	jobs, next := h.jobQueue.GetStatusManager().ListJobs(filter, cursor, limit)
+
+	items := make([]fiber.Map, 0, len(jobs))
+	for i := range jobs {
+		items = append(items, h.FormatJobSummary(&jobs[i]))
+	}
+
+	return c.JSON(fiber.Map{
+		"jobs":        items,
+		"next_cursor": EncodeCursor(next),
+	})
+}
+
+// GetJobHandler returns the full detail of one job
+func (h *Handler) GetJobHandler(c *fiber.Ctx) error {
+	job, err := h.VisibleJob(c, c.Params("id"))
+	if err != nil {
+		return apierror.New(fiber.StatusNotFound, apierror.CodeJobNotFound, err.Error())
+	}
+	return c.JSON(h.FormatJob(job))
+}
+
+// PeekJobHandler shows the job at the head of the queue without taking it
+func (h *Handler) PeekJobHandler(c *fiber.Ctx) error {
+	job := h.PeekNextJob(h.TenantScope(c))
+	if job == nil {
+		return c.Status(fiber.StatusNoContent).JSON(fiber.Map{
+			"message": "No job available",
+		})
+	}
+	return c.JSON(h.FormatJob(job))
+}
+
+// RequeueJobHandler puts a processing or completed job back in the queue
+func (h *Handler) RequeueJobHandler(c *fiber.Ctx) error {
+	if _, err := h.VisibleJob(c, c.Params("id")); err != nil {
+		return jobError(err)
+	}
+	job, err := h.jobQueue.Requeue(c.Params("id"))
+	if err != nil {
+		return jobError(err)
+	}
+	return c.JSON(h.FormatJobSummary(job))
+}
+
+// UpdateJobHandler changes the priority or scheduled time of a pending job
+func (h *Handler) UpdateJobHandler(c *fiber.Ctx) error {
+	var req ScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body")
+	}
+	schedule, err := req.Schedule()
+	if err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidSchedule, err.Error())
+	}
+
+	if _, err := h.VisibleJob(c, c.Params("id")); err != nil {
+		return jobError(err)
+	}
+	job, err := h.jobQueue.Reschedule(c.Params("id"), schedule)
+	if err != nil {
+		return jobError(err)
+	}
+	return c.JSON(h.FormatJobSummary(job))
+}
+
+// DeleteJobHandler removes a job entirely
+func (h *Handler) DeleteJobHandler(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+	if _, err := h.VisibleJob(c, jobID); err != nil {
+		return jobError(err)
+	}
+	if err := h.jobQueue.Delete(jobID); err != nil {
+		return jobError(err)
+	}
+	return c.JSON(fiber.Map{"message": "Job deleted", "job_id": jobID})
+}
+
+// MoveJobHandler moves a pending job to the front or back of the queue
+func (h *Handler) MoveJobHandler(c *fiber.Ctx) error {
+	var req struct {
+		Position queue.Position `json:"position"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body")
+	}
+	if req.Position != queue.PositionFront && req.Position != queue.PositionBack {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed, "position must be front or back")
+	}
+
+	jobID := c.Params("id")
+	if _, err := h.VisibleJob(c, jobID); err != nil {
+		return jobError(err)
+	}
+	if err := h.jobQueue.Move(jobID, req.Position); err != nil {
+		return jobError(err)
+	}
+	return c.JSON(fiber.Map{"message": "Job moved", "job_id": jobID, "position": req.Position})
+}
+
+// BulkJobsHandler applies one action to every job matching a filter
+func (h *Handler) BulkJobsHandler(c *fiber.Ctx) error {
+	var req BulkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body")
+	}
+	if tenant := callerTenant(c); tenant != "" {
+		req.Filter.Tenant = tenant
+	}
+
+	result, err := h.ApplyBulk(req)
+	if errors.Is(err, ErrUnknownBulkAction) {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidBulkAction, err.Error())
+	}
+	if err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidSchedule, err.Error())
+	}
+	return c.JSON(result)
+}
+
+func jobError(err error) error {
+	switch {
+	case errors.Is(err, queue.ErrJobNotFound):
+		return apierror.New(fiber.StatusNotFound, apierror.CodeJobNotFound, err.Error())
+	case errors.Is(err, queue.ErrInvalidJobState):
+		return apierror.New(fiber.StatusConflict, apierror.CodeInvalidJobState, err.Error())
+	default:
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, err.Error())
+	}
+}
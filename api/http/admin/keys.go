@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/auth"
+)
+
+// ListKeysHandler lists the API keys without their secrets
+func (h *Handler) ListKeysHandler(c *fiber.Ctx) error {
+	keys := h.keys.List()
+	formatted := make([]fiber.Map, 0, len(keys))
+	for _, key := range keys {
+		formatted = append(formatted, FormatKey(key))
+	}
+	return c.JSON(fiber.Map{"keys": formatted})
+}
+
+// CreateKeyHandler creates an API key, its secret is only returned here
+func (h *Handler) CreateKeyHandler(c *fiber.Ctx) error {
+	var spec auth.KeySpec
+	if err := c.BodyParser(&spec); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body")
+	}
+	if err := spec.Validate(); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidKey, err.Error())
+	}
+
+	key, secret, err := h.keys.Create(spec)
+	if err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to create API key")
+	}
+
+	response := FormatKey(key)
+	response["secret"] = secret
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+// RevokeKeyHandler deletes an API key
+func (h *Handler) RevokeKeyHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	err := h.keys.Revoke(id)
+	if errors.Is(err, auth.ErrKeyNotFound) {
+		return apierror.New(fiber.StatusNotFound, apierror.CodeKeyNotFound, "API key not found")
+	}
+	if err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to revoke API key")
+	}
+	return c.JSON(fiber.Map{"message": "API key revoked", "id": id})
+}
+
+// FormatKey converts a key into a response without its hash
+func FormatKey(key auth.Key) fiber.Map {
+	queues := key.Queues
+	if queues == nil {
+		queues = []string{}
+	}
+	return fiber.Map{
+		"id":         key.ID,
+		"name":       key.Name,
+		"prefix":     key.Prefix,
+		"roles":      key.Roles,
+		"queues":     queues,
+		"tenant":     key.Tenant,
+		"created_at": key.CreatedAt,
+	}
+}
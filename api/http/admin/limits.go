@@ -0,0 +1,41 @@
+package admin
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+)
+
+// Limits bound the queue, zero is unlimited
+type Limits struct {
+	MaxDepth int `json:"max_depth"`
+}
+
+// GetLimitsHandler returns the queue limits and the current depth
+func (h *Handler) GetLimitsHandler(c *fiber.Ctx) error {
+	return c.JSON(h.FormatLimits())
+}
+
+// SetLimitsHandler replaces the queue limits, submitters waiting for room are woken when it grows
+func (h *Handler) SetLimitsHandler(c *fiber.Ctx) error {
+	var limits Limits
+	if err := c.BodyParser(&limits); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidLimits, "Invalid limits: "+err.Error())
+	}
+	if limits.MaxDepth < 0 {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidLimits, "max_depth must not be negative")
+	}
+
+	if err := h.jobQueue.SetMaxDepth(limits.MaxDepth); err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to save limits")
+	}
+	return c.JSON(h.FormatLimits())
+}
+
+// FormatLimits returns the limits with the depth they apply to
+func (h *Handler) FormatLimits() fiber.Map {
+	return fiber.Map{
+		"max_depth": h.jobQueue.GetMaxDepth(),
+		"depth":     h.jobQueue.Depth(),
+	}
+}
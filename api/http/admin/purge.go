@@ -0,0 +1,73 @@
+package admin
+
+import (
+	"errors"
+	"time"
+
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+// ErrConfirmationRequired is returned for purges that are neither confirmed nor dry runs
+var ErrConfirmationRequired = errors.New("purge requires confirm: true, or dry_run: true to preview")
+
+// PurgeRequest selects the jobs to remove
+// older_than takes a Go duration such as 30m or 24h and matches jobs created before now minus it
+type PurgeRequest struct {
+	Filter    queue.JobFilter `json:"filter"`
+	OlderThan string          `json:"older_than"`
+	DryRun    bool            `json:"dry_run"` // Only report what would be removed
+	Confirm   bool            `json:"confirm"` // Required to remove jobs
+}
+
+// PurgeReport lists the jobs removed, or that would be removed by a dry run
+type PurgeReport struct {
+	DryRun   bool                    `json:"dry_run"`
+	Matched  int                     `json:"matched"`
+	ByStatus map[queue.JobStatus]int `json:"by_status"`
+	JobIDs   []string                `json:"job_ids"`
+}
+
+// JobFilter validates the request and converts it into a job filter
+func (r PurgeRequest) JobFilter() (queue.JobFilter, error) {
+	filter := r.Filter
+	if !r.DryRun && !r.Confirm {
+		return filter, ErrConfirmationRequired
+	}
+	if r.OlderThan == "" {
+		return filter, nil
+	}
+
+	age, err := time.ParseDuration(r.OlderThan)
+	if err != nil || age < 0 {
+		return filter, errors.New("invalid older_than, expected a duration such as 24h")
+	}
+	cutoff := time.Now().Add(-age)
+	if filter.CreatedBefore.IsZero() || cutoff.Before(filter.CreatedBefore) {
+		filter.CreatedBefore = cutoff
+	}
+	return filter, nil
+}
+
+// PurgeJobs removes the jobs matching the filter, unless it is a dry run
+func (h *Handler) PurgeJobs(filter queue.JobFilter, dryRun bool) (*PurgeReport, error) {
+	jobs, err := h.jobQueue.Purge(filter, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	return NewPurgeReport(jobs, dryRun), nil
+}
+
+// NewPurgeReport summarizes purged jobs
+func NewPurgeReport(jobs []queue.Message, dryRun bool) *PurgeReport {
+	report := &PurgeReport{
+		DryRun:   dryRun,
+		Matched:  len(jobs),
+		ByStatus: make(map[queue.JobStatus]int),
+		JobIDs:   make([]string, 0, len(jobs)),
+	}
+	for _, job := range jobs {
+		report.ByStatus[job.Status]++
+		report.JobIDs = append(report.JobIDs, job.ID)
+	}
+	return report
+}
@@ -0,0 +1,56 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+// GetSchemasHandler returns the payload and result schemas of the queue
+func (h *Handler) GetSchemasHandler(c *fiber.Ctx) error {
+	return c.JSON(h.jobQueue.GetSchemas())
+}
+
+// SetSchemasHandler replaces the payload and result schemas, omitted schemas are removed
+func (h *Handler) SetSchemasHandler(c *fiber.Ctx) error {
+	var schemas queue.Schemas
+	if err := c.BodyParser(&schemas); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidSchema, "Invalid schema: "+err.Error())
+	}
+	if err := CheckSchemas(schemas); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidSchema, err.Error())
+	}
+
+	if err := h.jobQueue.SetSchemas(schemas); err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to save schemas")
+	}
+	return c.JSON(schemas)
+}
+
+// DeleteSchemasHandler stops validating payloads and results
+func (h *Handler) DeleteSchemasHandler(c *fiber.Ctx) error {
+	if err := h.jobQueue.SetSchemas(queue.Schemas{}); err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to save schemas")
+	}
+	return c.JSON(queue.Schemas{})
+}
+
+// CheckSchemas makes sure the schemas can be used for validation
+func CheckSchemas(schemas queue.Schemas) error {
+	switch schemas.ResultMode {
+	case "", queue.ResultSchemaReject, queue.ResultSchemaFlag:
+	default:
+		return errors.New("result_mode must be reject or flag")
+	}
+	if err := schemas.Payload.Check(); err != nil {
+		return fmt.Errorf("invalid payload schema: %w", err)
+	}
+	if err := schemas.Result.Check(); err != nil {
+		return fmt.Errorf("invalid result schema: %w", err)
+	}
+	return nil
+}
@@ -1,35 +1,187 @@
 package admin
 
 import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/api/http/payload"
 	"github.com/PAFFx/job-poll-queue/queue"
 )
 
-// QueueService provides additional functionality for queue operations
-func (h *Handler) GetQueueStatistics() map[string]interface{} {
-	return map[string]interface{}{
-		"total":     h.jobQueue.GetStatusManager().CountTotalJobs(),
-		"pending":   h.jobQueue.GetStatusManager().CountPendingJobs(),
-		"running":   h.jobQueue.GetStatusManager().CountProcessingJobs(),
-		"completed": h.jobQueue.GetStatusManager().CountCompletedJobs(),
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// headerFilterPrefix marks query parameters that filter on captured headers
+const headerFilterPrefix = "header."
+
+// GetQueueStatistics counts the jobs of a tenant, or of every tenant when it is empty
+func (h *Handler) GetQueueStatistics(tenant string) map[string]interface{} {
+	counts := h.jobQueue.GetStatusManager().CountJobs(queue.JobFilter{Tenant: tenant})
+	total := 0
+	for _, count := range counts {
+		total += count
 	}
+
+	stats := map[string]interface{}{
+		"total":     total,
+		"pending":   counts[queue.JobStatusPending],
+		"running":   counts[queue.JobStatusProcessing],
+		"completed": counts[queue.JobStatusCompleted],
+		"paused":    h.jobQueue.IsPaused(),
+	}
+	if tenant == "" {
+		stats["depth"] = h.jobQueue.Depth()
+		stats["max_depth"] = h.jobQueue.GetMaxDepth()
+	} else {
+		stats["tenant"] = tenant
+		stats["quota"] = h.jobQueue.TenantQuota(tenant)
+		stats["usage"] = h.jobQueue.GetStatusManager().TenantUsage(tenant)
+	}
+	if backlog := h.jobQueue.Backlog(tenant); backlog != nil {
+		stats["backlog"] = backlog
+	}
+	return stats
 }
 
-// ClearQueue clears both the job queue and results
-func (h *Handler) ClearQueue() error {
-	// Clear queue
-	if err := h.jobQueue.Clear(); err != nil {
-		return err
+// ClearQueue clears both the job queue and results of a tenant, or of every tenant when it is empty
+// Submitters waiting on unfinished jobs get a cancellation error; a dry run only reports the jobs
+func (h *Handler) ClearQueue(tenant string, dryRun bool) (*PurgeReport, error) {
+	jobs, err := h.jobQueue.Purge(queue.JobFilter{Tenant: tenant}, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	return NewPurgeReport(jobs, dryRun), nil
+}
+
+// PeekNextJob returns the next job of a tenant, or of every tenant when it is empty, without removing it
+func (h *Handler) PeekNextJob(tenant string) *queue.Message {
+	return h.jobQueue.Peek(queue.JobFilter{Tenant: tenant})
+}
+
+// ParseJobFilter reads job filters from the query string:
+// status (comma separated), from and to (RFC 3339), id_prefix, tenant, worker_id, group and header.<Name>=<value>
+func (h *Handler) ParseJobFilter(c *fiber.Ctx) (queue.JobFilter, error) {
+	filter := queue.JobFilter{
+		IDPrefix: c.Query("id_prefix"),
+		Tenant:   h.TenantScope(c),
+		WorkerID: c.Query("worker_id"),
+		Group:    c.Query("group"),
+	}
+
+	for _, status := range strings.Split(c.Query("status"), ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			filter.Statuses = append(filter.Statuses, queue.JobStatus(status))
+		}
+	}
+
+	var err error
+	if from := c.Query("from"); from != "" {
+		if filter.CreatedAfter, err = time.Parse(time.RFC3339, from); err != nil {
+			return filter, errors.New("invalid from time, expected RFC 3339")
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if filter.CreatedBefore, err = time.Parse(time.RFC3339, to); err != nil {
+			return filter, errors.New("invalid to time, expected RFC 3339")
+		}
 	}
 
-	// Clear job status
-	if err := h.jobQueue.GetStatusManager().Clear(); err != nil {
-		return err
+	for key, value := range c.Queries() {
+		if name, ok := strings.CutPrefix(key, headerFilterPrefix); ok && name != "" {
+			if filter.Headers == nil {
+				filter.Headers = make(map[string]string)
+			}
+			filter.Headers[name] = value
+		}
 	}
 
-	return nil
+	return filter, nil
 }
 
-// GetNextJob retrieves the next job from the queue
-func (h *Handler) GetNextJob() (*queue.Message, error) {
-	return h.jobQueue.Pop()
+// EncodeCursor turns a listing position into an opaque cursor, empty for the last page
+func EncodeCursor(cursor *queue.JobCursor) string {
+	if cursor == nil {
+		return ""
+	}
+	raw := strconv.FormatInt(cursor.CreatedAt.UnixNano(), 10) + ":" + cursor.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor produced by EncodeCursor, nil for an empty cursor
+func DecodeCursor(value string) (*queue.JobCursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, errors.New("invalid cursor")
+	}
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	return &queue.JobCursor{CreatedAt: time.Unix(0, unixNano), ID: id}, nil
+}
+
+// FormatJobSummary formats a job for listings, without payloads
+func (h *Handler) FormatJobSummary(job *queue.Message) fiber.Map {
+	return fiber.Map{
+		"job_id":           job.ID,
+		"status":           job.Status,
+		"content_type":     job.ContentType,
+		"request":          job.Request,
+		"priority":         job.Priority,
+		"group":            job.Group,
+		"scheduled_at":     job.ScheduledAt,
+		"requirements":     job.Requirements,
+		"created_at":       job.CreatedAt,
+		"updated_at":       job.UpdatedAt,
+		"completed_at":     job.CompletedAt,
+		"submitted_by":     job.SubmittedBy,
+		"tenant":           job.Tenant,
+		"progress":         job.Progress,
+		"lease_expires_at": job.LeaseExpiresAt,
+		"worker_id":        job.WorkerID,
+	}
+}
+
+// FormatJob formats the full detail of a job for admins
+// Headers are redacted and callback secrets are never shown
+func (h *Handler) FormatJob(job *queue.Message) fiber.Map {
+	response := h.FormatJobSummary(job)
+
+	body, encoding := payload.Encode(job.Payload, false)
+	response["payload"] = body
+	response["payload_encoding"] = encoding
+	response["headers"] = h.jobQueue.GetHeaderRules().Redacted(job.Headers)
+
+	if job.Callback != nil {
+		response["callback_url"] = job.Callback.URL
+	}
+
+	if job.Status == queue.JobStatusCompleted {
+		result, resultEncoding := payload.Encode(job.Result, false)
+		response["result"] = fiber.Map{
+			"payload":          result,
+			"payload_encoding": resultEncoding,
+			"content_type":     job.ResultContentType,
+			"status_code":      job.ResultStatusCode,
+			"headers":          job.ResultHeaders,
+			"error":            job.Error,
+			"schema_errors":    job.ResultSchemaErrors,
+		}
+	}
+
+	return response
 }
@@ -0,0 +1,119 @@
+package admin
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/auth"
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+// callerTenant returns the tenant an admin is scoped to, empty for global admins
+func callerTenant(c *fiber.Ctx) string {
+	return auth.FromContext(c.UserContext()).TenantID()
+}
+
+// TenantScope returns the tenant whose jobs a request may see
+// Tenant admins are always limited to their own tenant, global admins can narrow views with ?tenant=
+func (h *Handler) TenantScope(c *fiber.Ctx) string {
+	if tenant := callerTenant(c); tenant != "" {
+		return tenant
+	}
+	return c.Query("tenant")
+}
+
+// RequireGlobalAdmin rejects tenant admins from operations affecting every tenant
+func (h *Handler) RequireGlobalAdmin(c *fiber.Ctx) error {
+	if callerTenant(c) != "" {
+		return apierror.New(fiber.StatusForbidden, apierror.CodePermissionDenied, "tenant admins cannot change queue-wide settings")
+	}
+	return c.Next()
+}
+
+// VisibleJob returns a job the caller may see, jobs of other tenants are reported as not found
+func (h *Handler) VisibleJob(c *fiber.Ctx, jobID string) (*queue.Message, error) {
+	job, err := h.jobQueue.GetStatusManager().GetJobStatus(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if tenant := callerTenant(c); tenant != "" && job.Tenant != tenant {
+		return nil, queue.ErrJobNotFound
+	}
+	return job, nil
+}
+
+// ListTenantsHandler lists the tenants with a stored quota
+func (h *Handler) ListTenantsHandler(c *fiber.Ctx) error {
+	tenants := h.jobQueue.ListTenants()
+	formatted := make([]fiber.Map, 0, len(tenants))
+	for i := range tenants {
+		formatted = append(formatted, h.FormatTenant(&tenants[i]))
+	}
+	return c.JSON(fiber.Map{
+		"tenants":       formatted,
+		"default_quota": h.jobQueue.TenantQuota(""),
+	})
+}
+
+// GetTenantHandler returns a tenant's quota and usage, tenant admins can only see their own
+func (h *Handler) GetTenantHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if tenant := callerTenant(c); tenant != "" && tenant != id {
+		return apierror.New(fiber.StatusNotFound, apierror.CodeTenantNotFound, queue.ErrTenantNotFound.Error())
+	}
+
+	tenant, err := h.jobQueue.GetTenant(id)
+	if errors.Is(err, queue.ErrTenantNotFound) {
+		// Tenants without a stored quota still have jobs under the default quota
+		tenant = &queue.Tenant{ID: id, Quota: h.jobQueue.TenantQuota(id)}
+	}
+	return c.JSON(h.FormatTenant(tenant))
+}
+
+// SetTenantHandler creates a tenant or replaces its quota
+func (h *Handler) SetTenantHandler(c *fiber.Ctx) error {
+	var req struct {
+		Quota queue.Quota `json:"quota"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body")
+	}
+	if err := req.Quota.Validate(); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidQuota, err.Error())
+	}
+
+	tenant, err := h.jobQueue.SetTenant(c.Params("id"), req.Quota)
+	if err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to save tenant")
+	}
+	return c.JSON(h.FormatTenant(tenant))
+}
+
+// DeleteTenantHandler removes a tenant's quota, its jobs are kept
+func (h *Handler) DeleteTenantHandler(c *fiber.Ctx) error {
+	id := c.Params("id")
+	err := h.jobQueue.DeleteTenant(id)
+	if errors.Is(err, queue.ErrTenantNotFound) {
+		return apierror.New(fiber.StatusNotFound, apierror.CodeTenantNotFound, err.Error())
+	}
+	if err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to delete tenant")
+	}
+	return c.JSON(fiber.Map{"message": "Tenant deleted", "id": id})
+}
+
+// FormatTenant formats a tenant with its current usage
+func (h *Handler) FormatTenant(tenant *queue.Tenant) fiber.Map {
+	response := fiber.Map{
+		"id":    tenant.ID,
+		"quota": tenant.Quota,
+		"usage": h.jobQueue.GetStatusManager().TenantUsage(tenant.ID),
+	}
+	if !tenant.CreatedAt.IsZero() {
+		response["created_at"] = tenant.CreatedAt
+		response["updated_at"] = tenant.UpdatedAt
+	}
+	return response
+}
@@ -0,0 +1,38 @@
+package admin
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/webhook"
+)
+
+func (h *Handler) ListWebhooksHandler(c *fiber.Ctx) error {
+	deliveries := h.webhooks.List(webhook.DeliveryStatus(c.Query("status")))
+	return c.JSON(fiber.Map{"deliveries": deliveries})
+}
+
+func (h *Handler) GetWebhookHandler(c *fiber.Ctx) error {
+	delivery, err := h.webhooks.Get(c.Params("id"))
+	if err != nil {
+		return webhookError(err)
+	}
+	return c.JSON(delivery)
+}
+
+func (h *Handler) ReplayWebhookHandler(c *fiber.Ctx) error {
+	delivery, err := h.webhooks.Replay(c.Params("id"))
+	if err != nil {
+		return webhookError(err)
+	}
+	return c.JSON(delivery)
+}
+
+func webhookError(err error) error {
+	if errors.Is(err, webhook.ErrDeliveryNotFound) {
+		return apierror.New(fiber.StatusNotFound, apierror.CodeDeliveryNotFound, err.Error())
+	}
+	return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, err.Error())
+}
@@ -0,0 +1,129 @@
+package admin
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+// ListWorkersHandler lists the workers seen since the server started with the jobs they hold
+// Tenant admins only see their own tenant's workers
+func (h *Handler) ListWorkersHandler(c *fiber.Ctx) error {
+	tenant := h.TenantScope(c)
+	leases := h.WorkerLeases(tenant)
+
+	workers := h.jobQueue.Workers(tenant)
+	formatted := make([]fiber.Map, 0, len(workers))
+	for i := range workers {
+		formatted = append(formatted, h.FormatWorker(&workers[i], leases[workers[i].ID]))
+	}
+	return c.JSON(fiber.Map{"workers": formatted})
+}
+
+// GetWorkerHandler returns one worker with the jobs it holds
+func (h *Handler) GetWorkerHandler(c *fiber.Ctx) error {
+	worker, err := h.VisibleWorker(c, c.Params("id"))
+	if err != nil {
+		return workerError(err)
+	}
+	return c.JSON(h.FormatWorker(worker, h.WorkerLeases(worker.Tenant)[worker.ID]))
+}
+
+// DrainWorkerHandler stops giving jobs to a worker, it keeps the jobs it holds
+func (h *Handler) DrainWorkerHandler(c *fiber.Ctx) error {
+	return h.setDraining(c, true)
+}
+
+// ResumeWorkerHandler gives jobs to a drained worker again
+func (h *Handler) ResumeWorkerHandler(c *fiber.Ctx) error {
+	return h.setDraining(c, false)
+}
+
+func (h *Handler) setDraining(c *fiber.Ctx, draining bool) error {
+	if _, err := h.VisibleWorker(c, c.Params("id")); err != nil {
+		return workerError(err)
+	}
+	worker, err := h.jobQueue.DrainWorker(c.Params("id"), draining)
+	if err != nil {
+		return workerError(err)
+	}
+	return c.JSON(h.FormatWorker(worker, h.WorkerLeases(worker.Tenant)[worker.ID]))
+}
+
+// ReleaseWorkerHandler puts the jobs of a dead worker back in the queue
+// Global admins may also release jobs of workers that are no longer registered, such as after a restart
+func (h *Handler) ReleaseWorkerHandler(c *fiber.Ctx) error {
+	workerID := c.Params("id")
+	if _, err := h.VisibleWorker(c, workerID); err != nil && (callerTenant(c) != "" || !errors.Is(err, queue.ErrWorkerNotFound)) {
+		return workerError(err)
+	}
+
+	released, err := h.jobQueue.ReleaseWorker(workerID)
+	if err != nil {
+		return workerError(err)
+	}
+	return c.JSON(fiber.Map{"worker_id": workerID, "released": released})
+}
+
+// VisibleWorker returns a worker the caller may see, workers of other tenants are reported as not found
+func (h *Handler) VisibleWorker(c *fiber.Ctx, workerID string) (*queue.Worker, error) {
+	worker, err := h.jobQueue.GetWorker(workerID)
+	if err != nil {
+		return nil, err
+	}
+	if tenant := callerTenant(c); tenant != "" && worker.Tenant != tenant {
+		return nil, queue.ErrWorkerNotFound
+	}
+	return worker, nil
+}
+
+// WorkerLeases returns the processing jobs of a tenant, or of every tenant when it is empty, by worker ID
+func (h *Handler) WorkerLeases(tenant string) map[string][]queue.Message {
+	jsm := h.jobQueue.GetStatusManager()
+	processing, _ := jsm.ListJobs(queue.JobFilter{Statuses: []queue.JobStatus{queue.JobStatusProcessing}, Tenant: tenant}, nil, jsm.CountTotalJobs())
+
+	leases := make(map[string][]queue.Message)
+	for _, job := range processing {
+		if job.WorkerID != "" {
+			leases[job.WorkerID] = append(leases[job.WorkerID], job)
+		}
+	}
+	return leases
+}
+
+// FormatWorker formats a worker with the jobs it currently holds, lease tokens are never shown
+func (h *Handler) FormatWorker(worker *queue.Worker, leases []queue.Message) fiber.Map {
+	jobs := make([]fiber.Map, 0, len(leases))
+	for _, job := range leases {
+		jobs = append(jobs, fiber.Map{
+			"job_id":           job.ID,
+			"lease_expires_at": job.LeaseExpiresAt,
+			"progress":         job.Progress,
+		})
+	}
+	return fiber.Map{
+		"worker_id":     worker.ID,
+		"hostname":      worker.Hostname,
+		"version":       worker.Version,
+		"labels":        worker.Labels,
+		"tenant":        worker.Tenant,
+		"registered_at": worker.RegisteredAt,
+		"last_seen":     worker.LastSeen,
+		"online":        worker.Online,
+		"draining":      worker.Draining,
+		"sessions":      worker.Sessions,
+		"completed":     worker.Completed,
+		"throughput":    worker.Throughput,
+		"leases":        jobs,
+	}
+}
+
+func workerError(err error) error {
+	if errors.Is(err, queue.ErrWorkerNotFound) {
+		return apierror.New(fiber.StatusNotFound, apierror.CodeWorkerNotFound, err.Error())
+	}
+	return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, err.Error())
+}
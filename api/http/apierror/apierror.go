@@ -0,0 +1,158 @@
+package apierror
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+// Code is a stable, machine readable error identifier
+// Messages may change between releases, codes do not
+type Code string
+
+const (
+	CodeBadRequest           Code = "bad_request"
+	CodeValidationFailed     Code = "validation_failed"    // The request does not match the API specification
+	CodeInvalidRequestBody   Code = "invalid_request_body" // The body is not valid JSON
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeInvalidHeader        Code = "invalid_header"
+	CodeInvalidFilter        Code = "invalid_filter"
+	CodeInvalidCursor        Code = "invalid_cursor"
+	CodeInvalidSchedule      Code = "invalid_schedule"
+	CodeInvalidBulkAction    Code = "invalid_bulk_action"
+	CodeConfirmationRequired Code = "confirmation_required"
+	CodeInvalidLastEventID   Code = "invalid_last_event_id"
+	CodeInvalidHandshake     Code = "invalid_websocket_handshake"
+	CodeUnauthenticated      Code = "unauthenticated"   // No API key, or an unknown one
+	CodePermissionDenied     Code = "permission_denied" // The API key lacks the role or queue access
+	CodeRouteNotFound        Code = "route_not_found"
+	CodeMethodNotAllowed     Code = "method_not_allowed"
+	CodeJobNotFound          Code = "job_not_found"
+	CodeInvalidJobState      Code = "invalid_job_state"
+	CodeLeaseExpired         Code = "lease_expired"  // The worker's lease ran out and the job went back to the queue
+	CodeLeaseMismatch        Code = "lease_mismatch" // The job was leased again, to another worker
+	CodeJobCancelled         Code = "job_cancelled"
+	CodeJobFailed            Code = "job_failed"
+	CodeDeliveryNotFound     Code = "delivery_not_found"
+	CodeInvalidPayload       Code = "invalid_payload" // The job payload does not match the queue schema
+	CodeInvalidResult        Code = "invalid_result"  // The job result does not match the queue schema
+	CodeInvalidSchema        Code = "invalid_schema"
+	CodeKeyNotFound          Code = "key_not_found"
+	CodeInvalidKey           Code = "invalid_key"
+	CodeTenantNotFound       Code = "tenant_not_found"
+	CodeWorkerNotFound       Code = "worker_not_found"
+	CodeInvalidQuota         Code = "invalid_quota"
+	CodeInvalidHeaderRules   Code = "invalid_header_rules"
+	CodeQuotaExceeded        Code = "quota_exceeded"   // The tenant is over its pending jobs, stored bytes or submit rate quota
+	CodeInvalidLimits        Code = "invalid_limits"   // Queue limits are negative or malformed
+	CodeInvalidFairness      Code = "invalid_fairness" // Unknown fairness policy or key, or a weight out of range
+	CodeQueueFull            Code = "queue_full"       // The queue holds its maximum number of pending jobs
+	CodeRateLimited          Code = "rate_limited"     // The API key or client IP sent too many requests
+	CodeInternal             Code = "internal_error"
+)
+
+// Detail locates one invalid part of a request
+type Detail struct {
+	In      string `json:"in,omitempty"`    // query, header, path or body
+	Field   string `json:"field,omitempty"` // Parameter name, or JSON Pointer into the body
+	Message string `json:"message"`
+}
+
+// Error is an API error rendered as {"error": message, "code": code, "details": [...]}
+type Error struct {
+	Status  int      `json:"-"`
+	Message string   `json:"error"`
+	Code    Code     `json:"code"`
+	Details []Detail `json:"details,omitempty"`
+}
+
+// New creates an API error with the given HTTP status
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// WithDetails adds field level details to the error
+func (e *Error) WithDetails(details ...Detail) *Error {
+	e.Details = append(e.Details, details...)
+	return e
+}
+
+// SchemaViolation converts a queue schema error into a 422 error with one detail per violation
+func SchemaViolation(code Code, err *queue.SchemaError) *Error {
+	apiErr := New(fiber.StatusUnprocessableEntity, code, err.Error())
+	for _, fieldErr := range err.Errors {
+		apiErr.Details = append(apiErr.Details, Detail{In: "body", Field: fieldErr.Path, Message: fieldErr.Message})
+	}
+	return apiErr
+}
+
+// QuotaExceeded converts a tenant quota error into a 429 error
+// Retry-After is set when the submit rate was exceeded
+func QuotaExceeded(c *fiber.Ctx, err *queue.QuotaError) *Error {
+	if err.RetryAfter > 0 {
+		c.Set(fiber.HeaderRetryAfter, retryAfterSeconds(err.RetryAfter))
+	}
+	return New(fiber.StatusTooManyRequests, CodeQuotaExceeded, err.Error()).
+		WithDetails(Detail{Field: err.Quota, Message: err.Error()})
+}
+
+// QueueFull converts a capacity error into a 429 error asking the client to retry shortly
+func QueueFull(c *fiber.Ctx, err *queue.CapacityError) *Error {
+	c.Set(fiber.HeaderRetryAfter, "1")
+	return New(fiber.StatusTooManyRequests, CodeQueueFull, err.Error())
+}
+
+// RateLimited returns a 429 error telling the client how long to wait
+func RateLimited(c *fiber.Ctx, retryAfter time.Duration) *Error {
+	c.Set(fiber.HeaderRetryAfter, retryAfterSeconds(retryAfter))
+	return New(fiber.StatusTooManyRequests, CodeRateLimited, "too many requests, retry later")
+}
+
+// Handler is the fiber error handler writing every error as a structured body
+// Errors raised by fiber itself, such as unknown routes, get a code derived from their status
+func Handler(c *fiber.Ctx, err error) error {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		apiErr = New(fiber.StatusInternalServerError, CodeInternal, err.Error())
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			apiErr = New(fiberErr.Code, codeForStatus(fiberErr.Code), fiberErr.Message)
+		}
+	}
+	return c.Status(apiErr.Status).JSON(apiErr)
+}
+
+func codeForStatus(status int) Code {
+	switch status {
+	case fiber.StatusUnauthorized:
+		return CodeUnauthenticated
+	case fiber.StatusForbidden:
+		return CodePermissionDenied
+	case fiber.StatusNotFound:
+		return CodeRouteNotFound
+	case fiber.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case fiber.StatusUnsupportedMediaType:
+		return CodeUnsupportedMediaType
+	case fiber.StatusUnprocessableEntity:
+		return CodeInvalidRequestBody
+	}
+	if status < fiber.StatusInternalServerError {
+		return CodeBadRequest
+	}
+	return CodeInternal
+}
+
+// retryAfterSeconds formats a wait as whole seconds, rounded up so clients do not retry too early
+func retryAfterSeconds(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"strings"
+
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/auth"
+	"github.com/gofiber/fiber/v2"
+)
+
+// routeRoles maps path prefixes to the role they require, the first match wins
+// Paths without a match, such as the OpenAPI document, are public
+var routeRoles = []struct {
+	prefix string
+	role   auth.Role
+}{
+	{"/api/admin", auth.RoleAdmin},
+	{"/api/worker", auth.RoleWorker},
+	{"/api/submit", auth.RoleSubmit},
+	{"/api/proxy", auth.RoleSubmit},
+	{"/api/jobs", auth.RoleSubmit},
+}
+
+// routeRole returns the role required by a path
+// Routing is case-insensitive, so is the match
+func routeRole(path string) (auth.Role, bool) {
+	path = strings.ToLower(path)
+	for _, route := range routeRoles {
+		if path == route.prefix || strings.HasPrefix(path, route.prefix+"/") {
+			return route.role, true
+		}
+	}
+	return "", false
+}
+
+// authenticate rejects requests whose credentials do not grant the role of the route
+// The principal is stored in the request context for handlers, when authentication
+// is disabled valid credentials still identify the caller
+func (s *Server) authenticate(c *fiber.Ctx) error {
+	credentials := auth.Credentials{
+		Token:       auth.Credential(c.Get(fiber.HeaderAuthorization), c.Get("X-API-Key")),
+		Certificate: clientCertificate(c.Context().TLSConnectionState()),
+	}
+
+	if !s.auth.Enabled() {
+		if principal := s.auth.Identify(credentials); principal != nil {
+			c.SetUserContext(auth.NewContext(c.UserContext(), principal))
+		}
+		return c.Next()
+	}
+	role, protected := routeRole(c.Path())
+	if !protected {
+		return c.Next()
+	}
+
+	principal, err := s.auth.Authorize(credentials, role, s.jobQueue.GetName())
+	switch {
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return apierror.New(fiber.StatusForbidden, apierror.CodePermissionDenied, "credentials do not grant the "+string(role)+" role on this queue")
+	case errors.Is(err, auth.ErrInvalidToken):
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="job-poll-queue", error="invalid_token"`)
+		return apierror.New(fiber.StatusUnauthorized, apierror.CodeUnauthenticated, err.Error())
+	case err != nil:
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="job-poll-queue"`)
+		return apierror.New(fiber.StatusUnauthorized, apierror.CodeUnauthenticated, err.Error())
+	}
+
+	c.SetUserContext(auth.NewContext(c.UserContext(), principal))
+	return c.Next()
+}
+
+// clientCertificate returns the verified client certificate of a TLS connection, if any
+func clientCertificate(state *tls.ConnectionState) *x509.Certificate {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
@@ -0,0 +1,80 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+// streamSSE writes events as a text/event-stream until the client disconnects
+func streamSSE(c *fiber.Ctx, bus *queue.EventBus, opts Options, lastEventID uint64) error {
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	sub := bus.Subscribe(opts.Filter, lastEventID)
+	leading := leadingEvents(sub, opts)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer sub.Close()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for _, event := range leading {
+			if err := writeSSE(w, event, false); err != nil {
+				return
+			}
+			if opts.Done != nil && opts.Done(event) {
+				return
+			}
+		}
+
+		for {
+			select {
+			case event, ok := <-sub.C:
+				if !ok {
+					// Dropped for being too slow, the client resumes from its last event ID
+					return
+				}
+				if err := writeSSE(w, event, true); err != nil {
+					return
+				}
+				if opts.Done != nil && opts.Done(event) {
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := w.WriteString(": heartbeat\n\n"); err != nil {
+					return
+				}
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+func writeSSE(w *bufio.Writer, event queue.Event, withID bool) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if withID {
+		if _, err := fmt.Fprintf(w, "id: %d\n", event.ID); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,91 @@
+package events
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+// heartbeatInterval keeps idle streams alive through proxies
+const heartbeatInterval = 15 * time.Second
+
+// ResyncEvent is sent first, without an event ID, when the events to resume from are no longer retained
+// The client must reload the state it tracks; live events follow
+const ResyncEvent queue.EventType = "stream.resync"
+
+// Options controls a single event stream
+type Options struct {
+	Filter queue.EventFilter
+	// Snapshot returns the event sent before any live events (without an event ID)
+	// It is called once subscribed, so no change between the snapshot and the live events is missed
+	Snapshot func() *queue.Event
+	// Done reports whether the stream should end after an event was sent
+	Done func(queue.Event) bool
+}
+
+// Stream sends events over WebSocket when the client asks for an upgrade and over SSE otherwise
+// Clients resume with the Last-Event-ID header or the last_event_id query parameter
+func Stream(c *fiber.Ctx, bus *queue.EventBus, opts Options) error {
+	lastEventID, err := parseLastEventID(c)
+	if err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidLastEventID, "invalid last event ID")
+	}
+
+	if IsWebSocketUpgrade(c) {
+		return streamWebSocket(c, bus, opts, lastEventID)
+	}
+	return streamSSE(c, bus, opts, lastEventID)
+}
+
+// ParseFilter reads event filters from the comma separated type, status and job_id query parameters
+func ParseFilter(c *fiber.Ctx) queue.EventFilter {
+	filter := queue.EventFilter{JobID: c.Query("job_id")}
+	for _, value := range splitList(c.Query("type")) {
+		filter.Types = append(filter.Types, queue.EventType(value))
+	}
+	for _, value := range splitList(c.Query("status")) {
+		filter.Statuses = append(filter.Statuses, queue.JobStatus(value))
+	}
+	return filter
+}
+
+// leadingEvents returns what is sent before live events: a resync notice and the snapshot
+// Must be called after subscribing
+func leadingEvents(sub *queue.Subscription, opts Options) []queue.Event {
+	var leading []queue.Event
+	if sub.Resync {
+		leading = append(leading, queue.Event{Type: ResyncEvent, Time: time.Now()})
+	}
+	if opts.Snapshot != nil {
+		if snapshot := opts.Snapshot(); snapshot != nil {
+			leading = append(leading, *snapshot)
+		}
+	}
+	return leading
+}
+
+func parseLastEventID(c *fiber.Ctx) (uint64, error) {
+	value := c.Get("Last-Event-ID")
+	if value == "" {
+		value = c.Query("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,216 @@
+package events
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+// websocketGUID is the fixed key suffix from RFC 6455
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes used by the event stream
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+)
+
+// maxControlPayload is the largest frame payload accepted from clients
+// The stream is server to client only, so clients only send control frames
+const maxControlPayload = 125
+
+// IsWebSocketUpgrade reports whether the request asks for a WebSocket connection
+func IsWebSocketUpgrade(c *fiber.Ctx) bool {
+	return strings.EqualFold(c.Get(fiber.HeaderUpgrade), "websocket") &&
+		strings.Contains(strings.ToLower(c.Get(fiber.HeaderConnection)), "upgrade")
+}
+
+// streamWebSocket upgrades the connection and sends each event as a JSON text frame
+func streamWebSocket(c *fiber.Ctx, bus *queue.EventBus, opts Options, lastEventID uint64) error {
+	key := c.Get("Sec-WebSocket-Key")
+	if key == "" || c.Get("Sec-WebSocket-Version") != "13" {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidHandshake, "unsupported WebSocket handshake")
+	}
+
+	sub := bus.Subscribe(opts.Filter, lastEventID)
+	leading := leadingEvents(sub, opts)
+
+	c.Status(fiber.StatusSwitchingProtocols)
+	c.Set(fiber.HeaderUpgrade, "websocket")
+	c.Set(fiber.HeaderConnection, "Upgrade")
+	c.Set("Sec-WebSocket-Accept", acceptKey(key))
+	c.Context().Response.Header.SetNoDefaultContentType(true)
+
+	c.Context().Hijack(func(conn net.Conn) {
+		defer sub.Close()
+		ws := &wsConn{conn: conn}
+
+		// Read control frames until the client goes away
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			ws.readLoop()
+		}()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		send := func(event queue.Event) bool {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return false
+			}
+			return ws.writeFrame(opText, data) == nil
+		}
+
+		for _, event := range leading {
+			if !send(event) || (opts.Done != nil && opts.Done(event)) {
+				ws.close()
+				return
+			}
+		}
+
+		for {
+			select {
+			case event, ok := <-sub.C:
+				if !ok {
+					ws.close()
+					return
+				}
+				if !send(event) {
+					return
+				}
+				if opts.Done != nil && opts.Done(event) {
+					ws.close()
+					return
+				}
+			case <-heartbeat.C:
+				if ws.writeFrame(opPing, nil) != nil {
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	})
+
+	return nil
+}
+
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// wsConn is a minimal server side WebSocket connection
+type wsConn struct {
+	conn  net.Conn
+	mutex sync.Mutex
+}
+
+// writeFrame writes a single unmasked frame
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length <= 125:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	ws.conn.SetWriteDeadline(time.Now().Add(heartbeatInterval))
+	if _, err := ws.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := ws.conn.Write(payload)
+	return err
+}
+
+// close sends a normal closure frame
+func (ws *wsConn) close() {
+	ws.writeFrame(opClose, []byte{0x03, 0xE8})
+}
+
+// readLoop answers pings and returns when the client closes or the connection fails
+func (ws *wsConn) readLoop() {
+	reader := bufio.NewReader(ws.conn)
+	for {
+		opcode, payload, err := readFrame(reader)
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case opClose:
+			ws.writeFrame(opClose, payload)
+			return
+		case opPing:
+			ws.writeFrame(opPong, payload)
+		}
+	}
+}
+
+// readFrame reads one masked client frame
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := head[0] & 0x0F
+	if head[1]&0x80 == 0 {
+		return 0, nil, errors.New("client frames must be masked")
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > maxControlPayload {
+		return 0, nil, errors.New("client frame too large")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/api/http/events"
+	"github.com/PAFFx/job-poll-queue/auth"
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+type Handler struct {
+	jobQueue *queue.Queue
+}
+
+func NewHandler(jobQueue *queue.Queue) *Handler {
+	return &Handler{jobQueue: jobQueue}
+}
+
+func (h *Handler) RegisterRoutes(router fiber.Router) {
+	router.Get("/:id", h.GetJobStatusHandler)
+	router.Get("/:id/events", h.JobEventsHandler)
+}
+
+// GetJobStatusHandler returns the current status of a job, with its result once completed
+func (h *Handler) GetJobStatusHandler(c *fiber.Ctx) error {
+	job, err := h.GetJob(c.Params("id"), auth.FromContext(c.UserContext()).TenantID())
+	if err != nil {
+		return apierror.New(fiber.StatusNotFound, apierror.CodeJobNotFound, err.Error())
+	}
+	return c.JSON(h.FormatJobStatus(job))
+}
+
+// JobEventsHandler streams the lifecycle events of one job over SSE or WebSocket
+// The stream starts with a snapshot of the current status and ends once the job completes
+func (h *Handler) JobEventsHandler(c *fiber.Ctx) error {
+	tenant := auth.FromContext(c.UserContext()).TenantID()
+	job, err := h.GetJob(c.Params("id"), tenant)
+	if err != nil {
+		return apierror.New(fiber.StatusNotFound, apierror.CodeJobNotFound, err.Error())
+	}
+
+	// The snapshot is read again once subscribed, the job may have finished in the meantime
+	return events.Stream(c, h.jobQueue.GetEventBus(), events.Options{
+		Filter: queue.EventFilter{JobID: job.ID},
+		Snapshot: func() *queue.Event {
+			current, err := h.GetJob(job.ID, tenant)
+			if err != nil {
+				// Removed since, which cancels unfinished jobs
+				removed := queue.Message{ID: job.ID, Status: queue.JobStatusCancelled}
+				if job.Status.IsTerminal() {
+					removed.Status = job.Status
+				}
+				return h.Snapshot(&removed)
+			}
+			return h.Snapshot(current)
+		},
+		Done: h.IsFinal,
+	})
+}
@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/api/http/payload"
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+// SnapshotEvent is the type of the first event of a job stream
+const SnapshotEvent queue.EventType = "job.snapshot"
+
+// GetJob retrieves a job by ID
+// Callers scoped to a tenant get ErrJobNotFound for jobs of other tenants
+func (h *Handler) GetJob(jobID string, tenant string) (*queue.Message, error) {
+	job, err := h.jobQueue.GetStatusManager().GetJobStatus(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if tenant != "" && job.Tenant != tenant {
+		return nil, queue.ErrJobNotFound
+	}
+	return job, nil
+}
+
+// FormatJobStatus formats a job's status for submitters
+func (h *Handler) FormatJobStatus(job *queue.Message) fiber.Map {
+	response := fiber.Map{
+		"job_id":       job.ID,
+		"status":       job.Status,
+		"created_at":   job.CreatedAt,
+		"updated_at":   job.UpdatedAt,
+		"completed_at": job.CompletedAt,
+	}
+
+	// The last progress reported by the worker
+	if job.Progress != nil {
+		response["progress"] = job.Progress
+	}
+
+	if job.Status == queue.JobStatusCompleted {
+		body, encoding := payload.Encode(job.Result, false)
+		response["payload"] = body
+		response["payload_encoding"] = encoding
+		response["content_type"] = job.ResultContentType
+		if job.Error != "" {
+			response["error"] = job.Error
+		}
+		if len(job.ResultSchemaErrors) > 0 {
+			response["schema_errors"] = job.ResultSchemaErrors
+		}
+	}
+
+	return response
+}
+
+// Snapshot describes the current status of a job as an event
+func (h *Handler) Snapshot(job *queue.Message) *queue.Event {
+	return &queue.Event{
+		Type:   SnapshotEvent,
+		JobID:  job.ID,
+		Status: job.Status,
+		Time:   time.Now(),
+	}
+}
+
+// IsFinal reports whether an event ends a job's stream
+func (h *Handler) IsFinal(event queue.Event) bool {
+	return event.Status.IsTerminal()
+}
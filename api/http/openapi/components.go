@@ -0,0 +1,422 @@
+package openapi
+
+import (
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/schema"
+)
+
+// components are the shared schemas referenced as #/components/schemas/<name>
+var components = map[string]*schema.Schema{
+	"Error": object(map[string]*schema.Schema{
+		"error": describe(str(), "Human readable message, may change between releases"),
+		"code":  describe(enum(errorCodes()...), "Stable machine readable error code"),
+		"details": array(object(map[string]*schema.Schema{
+			"in":      enum("query", "header", "path", "body"),
+			"field":   describe(str(), "Parameter name, or JSON Pointer into the body"),
+			"message": str(),
+		}, "message")),
+	}, "error", "code"),
+
+	"HTTPRequest": object(map[string]*schema.Schema{
+		"method": str(),
+		"path":   str(),
+		"query":  str(),
+	}, "method", "path"),
+
+	"SubmitResult": object(map[string]*schema.Schema{
+		"job_id":           str(),
+		"payload":          describe(&schema.Schema{}, "JSON results as-is, other results as a string"),
+		"payload_encoding": payloadEncoding(),
+		"content_type":     str(),
+		"created_at":       dateTime(),
+		"completed_at":     nullable(dateTime()),
+	}, "job_id", "payload", "payload_encoding"),
+
+	"SubmitAccepted": object(map[string]*schema.Schema{
+		"job_id": str(),
+		"status": jobStatus(),
+	}, "job_id", "status"),
+
+	"JobStatus": object(map[string]*schema.Schema{
+		"job_id":           str(),
+		"status":           jobStatus(),
+		"created_at":       dateTime(),
+		"updated_at":       dateTime(),
+		"completed_at":     nullable(dateTime()),
+		"payload":          describe(str(), "Result, present once the job is completed"),
+		"payload_encoding": payloadEncoding(),
+		"content_type":     str(),
+		"error":            str(),
+		"schema_errors":    describe(array(ref("FieldError")), "Violations of the result schema, when the queue flags invalid results"),
+		"progress":         ref("Progress"),
+	}, "job_id", "status"),
+
+	"Progress": object(map[string]*schema.Schema{
+		"percent":    integerRange(0, 100),
+		"message":    str(),
+		"updated_at": dateTime(),
+	}, "percent", "updated_at"),
+
+	"Lease": object(map[string]*schema.Schema{
+		"job_id":           str(),
+		"lease_expires_at": describe(nullable(dateTime()), "When the job is requeued without another heartbeat, null when leases never expire"),
+		"progress":         nullable(ref("Progress")),
+	}, "job_id", "lease_expires_at"),
+
+	"WorkerJob": object(map[string]*schema.Schema{
+		"job_id":           str(),
+		"payload":          str(),
+		"payload_encoding": payloadEncoding(),
+		"content_type":     str(),
+		"headers":          stringMap(),
+		"request":          ref("HTTPRequest"),
+		"lease_token":      describe(str(), "Send it in X-Lease-Token to complete the job or extend its lease"),
+	}, "job_id", "payload", "payload_encoding", "lease_token"),
+
+	"QueueStats": object(map[string]*schema.Schema{
+		"total":     integer(),
+		"pending":   integer(),
+		"running":   integer(),
+		"completed": integer(),
+		"paused":    boolean(),
+		"depth":     describe(integer(), "Jobs waiting in the queue, absent for tenant admins"),
+		"max_depth": describe(integer(), "Maximum number of waiting jobs, zero is unlimited, absent for tenant admins"),
+		"tenant":    describe(str(), "Tenant the counts are limited to, absent for every tenant"),
+		"quota":     ref("Quota"),
+		"usage":     ref("TenantUsage"),
+		"backlog":   describe(&schema.Schema{Type: schema.Types{schema.TypeObject}, AdditionalProperties: integer()}, "Queued jobs by fairness key value, absent while the queue is fifo"),
+	}, "total", "pending", "running", "completed", "paused"),
+
+	"Limits": object(map[string]*schema.Schema{
+		"max_depth": describe(integer(), "Maximum number of waiting jobs, zero is unlimited"),
+		"depth":     describe(integer(), "Jobs waiting in the queue"),
+	}, "max_depth", "depth"),
+
+	"Fairness": object(fairness(), "policy"),
+
+	"HeaderRules": object(headerRules()),
+
+	"JobSummary": object(jobSummaryProperties(), "job_id", "status"),
+
+	"Job": object(merge(jobSummaryProperties(), map[string]*schema.Schema{
+		"payload":          str(),
+		"payload_encoding": payloadEncoding(),
+		"headers":          describe(stringMap(), "Captured headers, sensitive values are redacted"),
+		"callback_url":     str(),
+		"result": object(map[string]*schema.Schema{
+			"payload":          str(),
+			"payload_encoding": payloadEncoding(),
+			"content_type":     str(),
+			"status_code":      integer(),
+			"headers":          stringMap(),
+			"error":            str(),
+			"schema_errors":    array(ref("FieldError")),
+		}),
+	}), "job_id", "status"),
+
+	"JobPage": object(map[string]*schema.Schema{
+		"jobs":        array(ref("JobSummary")),
+		"next_cursor": describe(str(), "Cursor of the next page, empty on the last page"),
+	}, "jobs", "next_cursor"),
+
+	"JobFilter": jobFilter(),
+
+	"FieldError": object(map[string]*schema.Schema{
+		"path":    describe(str(), "JSON Pointer to the invalid value"),
+		"message": str(),
+	}, "message"),
+
+	"Schemas": schemas(),
+
+	"Quota": quota(),
+
+	"TenantUsage": object(map[string]*schema.Schema{
+		"pending_jobs": integer(),
+		"stored_bytes": describe(integer(), "Payload and result bytes of the tenant's tracked jobs"),
+	}, "pending_jobs", "stored_bytes"),
+
+	"Tenant": object(map[string]*schema.Schema{
+		"id":         str(),
+		"quota":      ref("Quota"),
+		"usage":      ref("TenantUsage"),
+		"created_at": describe(dateTime(), "Absent for tenants using the default quota"),
+		"updated_at": dateTime(),
+	}, "id", "quota", "usage"),
+
+	"Worker": object(map[string]*schema.Schema{
+		"worker_id":     str(),
+		"hostname":      str(),
+		"version":       str(),
+		"labels":        stringMap(),
+		"tenant":        describe(str(), "Tenant of the credentials the worker uses"),
+		"registered_at": dateTime(),
+		"last_seen":     dateTime(),
+		"online":        describe(boolean(), "Connected, waiting for a job, or seen within WORKER_OFFLINE_AFTER"),
+		"draining":      describe(boolean(), "Drained workers keep their jobs but are not given new ones"),
+		"sessions":      describe(integer(), "Open Connect streams"),
+		"completed":     describe(integer(), "Jobs completed since the worker was first seen"),
+		"throughput":    describe(&schema.Schema{Type: schema.Types{schema.TypeNumber}}, "Jobs completed per minute over the last five minutes"),
+		"leases": array(object(map[string]*schema.Schema{
+			"job_id":           str(),
+			"lease_expires_at": nullable(dateTime()),
+			"progress":         nullable(ref("Progress")),
+		}, "job_id")),
+	}, "worker_id", "online", "draining", "leases"),
+
+	"Submitter": object(map[string]*schema.Schema{
+		"id":     describe(str(), "API key ID, or token subject"),
+		"name":   str(),
+		"method": enum("api_key", "jwt"),
+		"tenant": str(),
+	}, "id", "method"),
+
+	"APIKey": object(map[string]*schema.Schema{
+		"id":         str(),
+		"name":       str(),
+		"prefix":     describe(str(), "First characters of the secret"),
+		"roles":      array(role()),
+		"queues":     describe(array(str()), "Queues the key is limited to, empty for every queue"),
+		"tenant":     describe(str(), "Tenant the key is scoped to, empty for global keys"),
+		"created_at": dateTime(),
+		"secret":     describe(str(), "Only returned when the key is created"),
+	}, "id", "name", "prefix", "roles", "queues", "created_at"),
+
+	"BulkResult": object(map[string]*schema.Schema{
+		"action":   str(),
+		"matched":  integer(),
+		"affected": integer(),
+		"failed": array(object(map[string]*schema.Schema{
+			"job_id": str(),
+			"error":  str(),
+		})),
+	}, "action", "matched", "affected"),
+
+	"PurgeReport": object(map[string]*schema.Schema{
+		"dry_run":   boolean(),
+		"matched":   integer(),
+		"by_status": &schema.Schema{Type: schema.Types{schema.TypeObject}, AdditionalProperties: integer()},
+		"job_ids":   array(str()),
+	}, "dry_run", "matched", "by_status", "job_ids"),
+
+	"WebhookDelivery": object(map[string]*schema.Schema{
+		"id":               str(),
+		"job_id":           str(),
+		"url":              str(),
+		"signed":           describe(boolean(), "Signed with the secret given on submission, which is never returned"),
+		"body":             describe(&schema.Schema{}, "Notification sent to the callback URL"),
+		"status":           enum("pending", "succeeded", "failed"),
+		"attempts":         integer(),
+		"last_status_code": integer(),
+		"last_error":       str(),
+		"next_attempt_at":  dateTime(),
+		"created_at":       dateTime(),
+		"updated_at":       dateTime(),
+	}, "id", "job_id", "url", "status", "attempts"),
+
+	"Event": object(map[string]*schema.Schema{
+		"id":       integer(),
+		"type":     eventType(),
+		"job_id":   str(),
+		"status":   jobStatus(),
+		"tenant":   str(),
+		"time":     dateTime(),
+		"progress": describe(ref("Progress"), "Present on job.progress events"),
+	}, "type", "job_id", "status", "time"),
+}
+
+func jobSummaryProperties() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"job_id":           str(),
+		"status":           jobStatus(),
+		"content_type":     str(),
+		"request":          nullable(ref("HTTPRequest")),
+		"priority":         integer(),
+		"group":            describe(str(), "Message group, empty for jobs outside any group"),
+		"scheduled_at":     nullable(dateTime()),
+		"requirements":     describe(stringMap(), "Capabilities a worker needs to take the job, an empty value only needs the capability"),
+		"created_at":       dateTime(),
+		"updated_at":       dateTime(),
+		"completed_at":     nullable(dateTime()),
+		"submitted_by":     describe(nullable(ref("Submitter")), "Authenticated caller that submitted the job, null for anonymous submissions"),
+		"tenant":           describe(str(), "Tenant owning the job, empty for untenanted jobs"),
+		"progress":         describe(nullable(ref("Progress")), "Last progress reported by the worker"),
+		"lease_expires_at": describe(nullable(dateTime()), "When a processing job is requeued without a heartbeat"),
+		"worker_id":        describe(str(), "Registered worker holding or having completed the job, empty for anonymous workers"),
+	}
+}
+
+func errorCodes() []interface{} {
+	codes := []apierror.Code{
+		apierror.CodeBadRequest, apierror.CodeValidationFailed, apierror.CodeInvalidRequestBody,
+		apierror.CodeUnsupportedMediaType, apierror.CodeInvalidHeader, apierror.CodeInvalidFilter,
+		apierror.CodeInvalidCursor, apierror.CodeInvalidSchedule, apierror.CodeInvalidBulkAction,
+		apierror.CodeConfirmationRequired, apierror.CodeInvalidLastEventID, apierror.CodeInvalidHandshake,
+		apierror.CodeRouteNotFound, apierror.CodeMethodNotAllowed, apierror.CodeJobNotFound,
+		apierror.CodeInvalidJobState, apierror.CodeLeaseExpired, apierror.CodeLeaseMismatch, apierror.CodeJobCancelled, apierror.CodeJobFailed,
+		apierror.CodeDeliveryNotFound, apierror.CodeInvalidPayload, apierror.CodeInvalidResult,
+		apierror.CodeInvalidSchema, apierror.CodeUnauthenticated, apierror.CodePermissionDenied,
+		apierror.CodeKeyNotFound, apierror.CodeInvalidKey, apierror.CodeTenantNotFound, apierror.CodeWorkerNotFound,
+		apierror.CodeInvalidQuota, apierror.CodeQuotaExceeded, apierror.CodeInvalidLimits, apierror.CodeInvalidFairness,
+		apierror.CodeInvalidHeaderRules, apierror.CodeQueueFull, apierror.CodeRateLimited, apierror.CodeInternal,
+	}
+	values := make([]interface{}, len(codes))
+	for i, code := range codes {
+		values[i] = string(code)
+	}
+	return values
+}
+
+// jobFilter is inlined in request bodies so they validate without resolving components
+func jobFilter() *schema.Schema {
+	return object(map[string]*schema.Schema{
+		"statuses":       array(jobStatus()),
+		"created_after":  dateTime(),
+		"created_before": dateTime(),
+		"headers":        describe(stringMap(), "Captured header values that must match exactly"),
+		"id_prefix":      str(),
+		"tenant":         describe(str(), "Ignored for tenant admins, who only ever match their own jobs"),
+		"worker_id":      describe(str(), "Worker holding or having completed the job"),
+		"group":          describe(str(), "Message group of the job"),
+	})
+}
+
+// fairness is inlined in the request body so it validates without resolving components
+func fairness() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"policy":  enum("fifo", "round_robin", "weighted"),
+		"key":     describe(enum("tenant", "group", "header"), "Job attribute the queue is shared out by, required unless the policy is fifo"),
+		"header":  describe(str(), "Header name, for the header key"),
+		"weights": describe(&schema.Schema{Type: schema.Types{schema.TypeObject}, AdditionalProperties: integerRange(1, 1000)}, "Share of each key value with the weighted policy, 1 when absent"),
+	}
+}
+
+// headerRules is inlined in the request body so it validates without resolving components
+func headerRules() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"allow":  describe(array(str()), "Only keep these headers, all headers when empty"),
+		"deny":   describe(array(str()), "Drop these headers"),
+		"rename": describe(stringMap(), "Rename headers from key to value"),
+		"redact": describe(array(str()), "Keep these headers but replace their values with [REDACTED]"),
+	}
+}
+
+// quota is inlined in the request body so it validates without resolving components
+func quota() *schema.Schema {
+	return object(map[string]*schema.Schema{
+		"max_pending_jobs": describe(integer(), "Zero or absent is unlimited"),
+		"max_stored_bytes": describe(integer(), "Payload and result bytes of tracked jobs, zero or absent is unlimited"),
+		"submit_rate":      describe(&schema.Schema{Type: schema.Types{schema.TypeNumber}}, "Jobs per second, zero or absent is unlimited"),
+		"submit_burst":     describe(integer(), "Jobs accepted at once, defaults to the rate rounded up"),
+	})
+}
+
+// schemas is inlined in the request body so it validates without resolving components
+func schemas() *schema.Schema {
+	jsonSchema := describe(&schema.Schema{Type: schema.Types{schema.TypeObject, schema.TypeBoolean}}, "A JSON Schema")
+	return object(map[string]*schema.Schema{
+		"payload":     jsonSchema,
+		"result":      jsonSchema,
+		"result_mode": describe(enum("reject", "flag"), "reject refuses invalid results, flag stores them with their violations. Defaults to reject"),
+	})
+}
+
+// securitySchemes are the accepted ways to authenticate
+var securitySchemes = map[string]*SecurityScheme{
+	"apiKey":            {Type: "apiKey", Name: "X-API-Key", In: InHeader, Description: "API key"},
+	"bearer":            {Type: "http", Scheme: "bearer", Description: "API key, or a JWT signed by a key of the configured JWKS"},
+	"clientCertificate": {Type: "mutualTLS", Description: "TLS client certificate verified against the client CA, it identifies a worker"},
+}
+
+// keySpec is inlined in the request body so it validates without resolving components
+func keySpec() *schema.Schema {
+	return object(map[string]*schema.Schema{
+		"name":   str(),
+		"roles":  array(role()),
+		"queues": describe(array(str()), "Limit the key to these queues, every queue when omitted"),
+		"tenant": describe(str(), "Scope the key to one tenant's jobs, global when omitted"),
+	}, "name", "roles")
+}
+
+func role() *schema.Schema {
+	return enum("submit", "worker", "admin")
+}
+
+func jobStatus() *schema.Schema {
+	return enum("pending", "processing", "completed", "cancelled")
+}
+
+func eventType() *schema.Schema {
+	return enum("job.queued", "job.started", "job.status", "job.progress", "job.completed", "job.cancelled")
+}
+
+func payloadEncoding() *schema.Schema {
+	return enum("text", "base64")
+}
+
+// Schema constructors keep the tables above readable
+
+func str() *schema.Schema {
+	return &schema.Schema{Type: schema.Types{schema.TypeString}}
+}
+
+func dateTime() *schema.Schema {
+	return &schema.Schema{Type: schema.Types{schema.TypeString}, Format: "date-time"}
+}
+
+func integer() *schema.Schema {
+	return &schema.Schema{Type: schema.Types{schema.TypeInteger}}
+}
+
+func integerRange(minimum, maximum float64) *schema.Schema {
+	return &schema.Schema{Type: schema.Types{schema.TypeInteger}, Minimum: &minimum, Maximum: &maximum}
+}
+
+func boolean() *schema.Schema {
+	return &schema.Schema{Type: schema.Types{schema.TypeBoolean}}
+}
+
+func enum(values ...interface{}) *schema.Schema {
+	return &schema.Schema{Type: schema.Types{schema.TypeString}, Enum: values}
+}
+
+func array(items *schema.Schema) *schema.Schema {
+	return &schema.Schema{Type: schema.Types{schema.TypeArray}, Items: items}
+}
+
+func stringMap() *schema.Schema {
+	return &schema.Schema{Type: schema.Types{schema.TypeObject}, AdditionalProperties: str()}
+}
+
+func object(properties map[string]*schema.Schema, required ...string) *schema.Schema {
+	return &schema.Schema{Type: schema.Types{schema.TypeObject}, Properties: properties, Required: required}
+}
+
+// closed rejects properties that are not listed, used for request bodies to catch typos
+func closed(s *schema.Schema) *schema.Schema {
+	s.AdditionalProperties = schema.False()
+	return s
+}
+
+func ref(name string) *schema.Schema {
+	return &schema.Schema{Ref: "#/components/schemas/" + name}
+}
+
+func nullable(s *schema.Schema) *schema.Schema {
+	if s.Ref != "" {
+		return &schema.Schema{AnyOf: []*schema.Schema{s, {Type: schema.Types{schema.TypeNull}}}}
+	}
+	s.Type = append(s.Type, schema.TypeNull)
+	return s
+}
+
+func describe(s *schema.Schema, description string) *schema.Schema {
+	s.Description = description
+	return s
+}
+
+func merge(base, extra map[string]*schema.Schema) map[string]*schema.Schema {
+	for name, s := range extra {
+		base[name] = s
+	}
+	return base
+}
@@ -0,0 +1,112 @@
+package openapi
+
+import (
+	"github.com/PAFFx/job-poll-queue/schema"
+)
+
+// Version is the OpenAPI version of the served document
+const Version = "3.1.0"
+
+// Document is an OpenAPI 3.1 document
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]*PathItem  `json:"paths"`
+	Components *Components           `json:"components,omitempty"`
+	Security   []SecurityRequirement `json:"security,omitempty"` // Applies to operations without their own
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of one path, keyed by lower case HTTP method
+type PathItem map[string]*Operation
+
+// Operation describes one method on one path
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   Responses             `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+}
+
+// SecurityRequirement maps security scheme names to required scopes
+// An empty requirement means the operation can be called anonymously
+type SecurityRequirement map[string][]string
+
+// Public reports whether the operation can be called without credentials
+func (o *Operation) Public() bool {
+	for _, requirement := range o.Security {
+		if len(requirement) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Responses maps a status code, or "default", to its response
+type Responses map[string]*Response
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *schema.Schema `json:"schema"`
+}
+
+// Parameter locations
+const (
+	InPath   = "path"
+	InQuery  = "query"
+	InHeader = "header"
+)
+
+// RequestBody describes the accepted request bodies by media type
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// MediaType holds the schema of one media type, no schema means any content
+type MediaType struct {
+	Schema *schema.Schema `json:"schema,omitempty"`
+}
+
+// Response describes one response status
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]*Header   `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header describes a response header
+type Header struct {
+	Description string         `json:"description,omitempty"`
+	Schema      *schema.Schema `json:"schema"`
+}
+
+// Components holds the schemas referenced from operations and the security schemes
+type Components struct {
+	Schemas         map[string]*schema.Schema  `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how credentials are passed
+type SecurityScheme struct {
+	Type        string `json:"type"` // apiKey or http
+	Description string `json:"description,omitempty"`
+	Name        string `json:"name,omitempty"`   // Header name for apiKey schemes
+	In          string `json:"in,omitempty"`     // Location for apiKey schemes
+	Scheme      string `json:"scheme,omitempty"` // Authorization scheme for http schemes
+}
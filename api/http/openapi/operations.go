@@ -0,0 +1,723 @@
+package openapi
+
+import (
+	"strings"
+
+	"github.com/PAFFx/job-poll-queue/schema"
+)
+
+// operations documents the routes registered in api/http/server.go, keyed by method and fiber path
+// "ALL" documents a route registered for every method
+var operations = map[string]*Operation{
+	"GET /api/openapi.json": {
+		OperationID: "getOpenAPI",
+		Summary:     "This OpenAPI document",
+		Tags:        []string{"meta"},
+		Security:    []SecurityRequirement{{}},
+		Responses:   responses(Responses{"200": jsonResponse("OpenAPI 3.1 document", &schema.Schema{Type: schema.Types{schema.TypeObject}})}),
+	},
+
+	// Clients
+	"POST /api/submit": {
+		OperationID: "submitJob",
+		Summary:     "Submit a job and wait for its result",
+		Description: "The body is the job payload, of any content type. In transparent mode the response is exactly what the worker produced.",
+		Tags:        []string{"submit"},
+		Parameters: append(submitHeaders(),
+			query("mode", "transparent returns the worker's status code, headers and body as-is", enum("transparent"))),
+		RequestBody: anyBody("Job payload"),
+		Responses: responses(Responses{
+			"200": jsonResponse("The job result", ref("SubmitResult")),
+			"400": errorResponse("Invalid submission headers"),
+			"409": errorResponse("The job was deleted, cleared or purged before completing"),
+			"422": errorResponse("The payload does not match the queue's payload schema"),
+			"429": overloadResponse(),
+			"500": errorResponse("The worker failed the job, or it could not be processed"),
+		}),
+	},
+	"POST /api/submit/async": {
+		OperationID: "submitJobAsync",
+		Summary:     "Submit a job without waiting",
+		Tags:        []string{"submit"},
+		Parameters:  submitHeaders(),
+		RequestBody: anyBody("Job payload"),
+		Responses: responses(Responses{
+			"202": jsonResponse("The job was queued", ref("SubmitAccepted")),
+			"400": errorResponse("Invalid submission headers"),
+			"422": errorResponse("The payload does not match the queue's payload schema"),
+			"429": overloadResponse(),
+		}),
+	},
+	"GET /api/jobs/:id": {
+		OperationID: "getJobStatus",
+		Summary:     "Get the status of a job, with its result once completed",
+		Tags:        []string{"jobs"},
+		Parameters:  []Parameter{jobIDParam()},
+		Responses: responses(Responses{
+			"200": jsonResponse("Job status", ref("JobStatus")),
+			"404": errorResponse("Unknown job, or a job of another tenant"),
+		}),
+	},
+	"GET /api/jobs/:id/events": {
+		OperationID: "streamJobEvents",
+		Summary:     "Stream the lifecycle events of a job",
+		Description: "Starts with a job.snapshot event and ends once the job completes or is cancelled. " + streamDescription,
+		Tags:        []string{"jobs"},
+		Parameters:  append([]Parameter{jobIDParam()}, resumeParams()...),
+		Responses: responses(Responses{
+			"200": eventStreamResponse(),
+			"404": errorResponse("Unknown job"),
+		}),
+	},
+	"ALL /api/proxy/*": {
+		OperationID: "proxyRequest",
+		Summary:     "Queue the whole request as a job and reply with the worker's response",
+		Description: "Method, path below the prefix, query, headers and body are all passed on to the worker, except the job option headers of the submit route.",
+		Tags:        []string{"proxy"},
+		Parameters:  append([]Parameter{pathParam(wildcardParam, "Any path, it may contain slashes")}, submitHeaders()...),
+		Responses: responses(Responses{
+			"400":     errorResponse("Invalid job option header"),
+			"default": &Response{Description: "The worker's response, status code and headers included"},
+			"409":     errorResponse("The job was deleted, cleared or purged before completing"),
+			"422":     errorResponse("The request does not match the queue's payload schema"),
+			"429":     overloadResponse(),
+			"502":     errorResponse("The worker failed the job, or it could not be processed"),
+		}),
+	},
+
+	// Workers
+	"GET /api/worker/poll": {
+		OperationID: "pollJob",
+		Summary:     "Take the next job",
+		Description: "In raw format the body is the payload and the job ID, headers and request are sent as X-Job-* headers, and the lease token as X-Lease-Token. With wait, the call blocks until a job arrives; waiting workers are served in arrival order.",
+		Tags:        []string{"worker"},
+		Parameters: []Parameter{
+			query("format", "raw returns the payload bytes as the body", enum("json", "raw")),
+			query("encoding", "base64 encodes every payload, binary payloads are always base64 encoded", payloadEncoding()),
+			query("wait", "How long to wait for a job, in seconds or as a duration such as 500ms, capped by the server", str()),
+			query("worker_id", "Registered worker taking the job, jobs are taken anonymously without it", str()),
+			query("capabilities", "Capabilities of the worker such as gpu,region=eu-west, only jobs whose requirements they meet are returned", str()),
+		},
+		Responses: responses(Responses{
+			"200": jsonResponse("The job, now processing", ref("WorkerJob")),
+			"204": {Description: "No job became available within the wait, or the queue is paused"},
+			"400": errorResponse("Invalid wait, worker ID or capabilities"),
+			"403": errorResponse("The worker ID is used by a worker of another tenant"),
+		}),
+	},
+	"POST /api/worker/register": {
+		OperationID: "registerWorker",
+		Summary:     "Register a worker",
+		Description: "Records the worker's identity for the admin worker list. Registering again updates it. Polls then pass the ID as worker_id.",
+		Tags:        []string{"worker"},
+		RequestBody: jsonBody(closed(object(map[string]*schema.Schema{
+			"worker_id": str(),
+			"hostname":  str(),
+			"version":   str(),
+			"labels":    stringMap(),
+		}, "worker_id"))),
+		Responses: responses(Responses{
+			"200": jsonResponse("The registered worker", ref("Worker")),
+			"400": errorResponse("Missing worker ID or too many labels"),
+			"403": errorResponse("The worker ID is used by a worker of another tenant"),
+		}),
+	},
+	"POST /api/worker/complete/:id": {
+		OperationID: "completeJob",
+		Summary:     "Submit the result of a job",
+		Description: "The body is the result, of any content type. X-Result-Header-<Name> headers are returned to the submitter as <Name>.",
+		Tags:        []string{"worker"},
+		Parameters: []Parameter{
+			jobIDParam(),
+			leaseTokenHeader(),
+			header("X-Result-Status", "Status code returned to the submitter in transparent mode", integerRange(100, 599)),
+		},
+		RequestBody: anyBody("Job result"),
+		Responses: responses(Responses{
+			"200": jsonResponse("The job is completed", messageWithJobID()),
+			"400": errorResponse("Invalid result status"),
+			"404": errorResponse("Unknown job, or a job of another tenant"),
+			"409": errorResponse("The lease expired or the job was leased to another worker, or the job is already finished"),
+			"422": errorResponse("The result does not match the queue's result schema and the queue rejects invalid results"),
+		}),
+	},
+	"POST /api/worker/fail/:id": {
+		OperationID: "failJob",
+		Summary:     "Fail a job",
+		Description: "Finishes a job the worker could not process. The reason is stored as the job's error and returned to a waiting submitter.",
+		Tags:        []string{"worker"},
+		Parameters:  []Parameter{jobIDParam(), leaseTokenHeader()},
+		RequestBody: jsonBody(closed(object(map[string]*schema.Schema{
+			"error": describe(str(), "Why the job failed, at most 1024 characters"),
+		}, "error"))),
+		Responses: responses(Responses{
+			"200": jsonResponse("The job is failed", messageWithJobID()),
+			"400": errorResponse("Missing or too long error"),
+			"404": errorResponse("Unknown job, or a job of another tenant"),
+			"409": errorResponse("The lease expired or the job was leased to another worker, or the job is already finished"),
+		}),
+	},
+	"POST /api/worker/heartbeat/:id": {
+		OperationID: "heartbeatJob",
+		Summary:     "Extend the lease of a job",
+		Description: "Workers send heartbeats while processing a job, or it is requeued once its lease expires. With percent or message, the progress is recorded and shown to submitters.",
+		Tags:        []string{"worker"},
+		Parameters:  []Parameter{jobIDParam(), leaseTokenHeader()},
+		RequestBody: &RequestBody{Content: map[string]MediaType{"application/json": {Schema: closed(object(map[string]*schema.Schema{
+			"percent": integerRange(0, 100),
+			"message": str(),
+		}))}}},
+		Responses: responses(Responses{
+			"200": jsonResponse("The renewed lease", ref("Lease")),
+			"400": errorResponse("Invalid progress"),
+			"404": errorResponse("Unknown job, or a job of another tenant"),
+			"409": errorResponse("The lease expired or the job was leased to another worker, or the job is already finished"),
+		}),
+	},
+
+	// Admin
+	"GET /api/admin/stats": {
+		OperationID: "getQueueStats",
+		Summary:     "Queue statistics",
+		Description: "Tenant admins only count their own jobs and also get their quota and usage.",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{tenantParam()},
+		Responses:   responses(Responses{"200": jsonResponse("Job counts and paused state", ref("QueueStats"))}),
+	},
+	"DELETE /api/admin/clear": {
+		OperationID: "clearQueue",
+		Summary:     "Remove every job",
+		Description: "Submitters waiting on unfinished jobs get a cancellation error. Tenant admins only remove their own jobs.",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{query("dry_run", "Only report what would be removed", boolean()), tenantParam()},
+		Responses: responses(Responses{"200": jsonResponse("Removed jobs", object(map[string]*schema.Schema{
+			"message": str(),
+			"report":  ref("PurgeReport"),
+		}, "message", "report"))}),
+	},
+	"POST /api/admin/purge": {
+		OperationID: "purgeJobs",
+		Summary:     "Remove the jobs matching a filter",
+		Description: "Requires confirm unless dry_run is set. Submitters waiting on unfinished jobs get a cancellation error.",
+		Tags:        []string{"admin"},
+		RequestBody: jsonBody(closed(object(map[string]*schema.Schema{
+			"filter":     closed(jobFilter()),
+			"older_than": describe(str(), "Go duration such as 24h, matches jobs created before now minus it"),
+			"dry_run":    boolean(),
+			"confirm":    boolean(),
+		}))),
+		Responses: responses(Responses{
+			"200": jsonResponse("Removed jobs, or the jobs a dry run would remove", ref("PurgeReport")),
+			"400": errorResponse("Invalid filter or missing confirmation"),
+		}),
+	},
+	"POST /api/admin/pause": {
+		OperationID: "pauseQueue",
+		Summary:     "Stop handing jobs to workers",
+		Tags:        []string{"admin"},
+		Responses:   responses(Responses{"200": jsonResponse("The queue is paused", pausedState())}),
+	},
+	"POST /api/admin/resume": {
+		OperationID: "resumeQueue",
+		Summary:     "Hand jobs to workers again",
+		Tags:        []string{"admin"},
+		Responses:   responses(Responses{"200": jsonResponse("The queue is running", pausedState())}),
+	},
+	"GET /api/admin/next": {
+		OperationID: "peekJob",
+		Summary:     "Show the next job without taking it",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{tenantParam()},
+		Responses: responses(Responses{
+			"200": jsonResponse("The next job", ref("Job")),
+			"204": {Description: "No job is due"},
+		}),
+	},
+	"GET /api/admin/jobs": {
+		OperationID: "listJobs",
+		Summary:     "Browse jobs",
+		Description: "Jobs are ordered by creation time. Filter on captured headers with header.<Name>=<value> query parameters.",
+		Tags:        []string{"admin"},
+		Parameters: []Parameter{
+			query("status", "Comma separated statuses", array(jobStatus())),
+			query("from", "Created at or after", dateTime()),
+			query("to", "Created before", dateTime()),
+			query("id_prefix", "Job ID prefix", str()),
+			query("worker_id", "Worker holding or having completed the job", str()),
+			query("group", "Message group of the job", str()),
+			tenantParam(),
+			query("limit", "Page size", integerRange(1, 500)),
+			query("cursor", "next_cursor of the previous page", str()),
+		},
+		Responses: responses(Responses{
+			"200": jsonResponse("A page of jobs", ref("JobPage")),
+			"400": errorResponse("Invalid filter or cursor"),
+		}),
+	},
+	"POST /api/admin/jobs/bulk": {
+		OperationID: "bulkJobs",
+		Summary:     "Apply one action to every job matching a filter",
+		Tags:        []string{"admin"},
+		RequestBody: jsonBody(closed(object(merge(scheduleProperties(), map[string]*schema.Schema{
+			"action": enum("requeue", "delete", "move_front", "move_back", "reschedule"),
+			"filter": closed(jobFilter()),
+		}), "action"))),
+		Responses: responses(Responses{
+			"200": jsonResponse("Summary of the changes", ref("BulkResult")),
+			"400": errorResponse("Invalid action or schedule"),
+		}),
+	},
+	"GET /api/admin/jobs/:id": {
+		OperationID: "getJob",
+		Summary:     "Get the full detail of a job",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{jobIDParam()},
+		Responses: responses(Responses{
+			"200": jsonResponse("The job", ref("Job")),
+			"404": errorResponse("Unknown job"),
+		}),
+	},
+	"PATCH /api/admin/jobs/:id": {
+		OperationID: "rescheduleJob",
+		Summary:     "Change the priority or scheduled time of a pending job",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{jobIDParam()},
+		RequestBody: jsonBody(closed(object(scheduleProperties()))),
+		Responses:   jobActionResponses(jsonResponse("The updated job", ref("JobSummary"))),
+	},
+	"DELETE /api/admin/jobs/:id": {
+		OperationID: "deleteJob",
+		Summary:     "Remove a job",
+		Description: "A submitter waiting on the job gets a cancellation error.",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{jobIDParam()},
+		Responses:   jobActionResponses(jsonResponse("The job was removed", messageWithJobID())),
+	},
+	"POST /api/admin/jobs/:id/requeue": {
+		OperationID: "requeueJob",
+		Summary:     "Put a processing or completed job back in the queue",
+		Description: "The job goes back in its original place, ahead of the jobs submitted after it.",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{jobIDParam()},
+		Responses:   jobActionResponses(jsonResponse("The requeued job", ref("JobSummary"))),
+	},
+	"POST /api/admin/jobs/:id/move": {
+		OperationID: "moveJob",
+		Summary:     "Move a pending job to the front or back of the queue",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{jobIDParam()},
+		RequestBody: jsonBody(closed(object(map[string]*schema.Schema{
+			"position": enum("front", "back"),
+		}, "position"))),
+		Responses: jobActionResponses(jsonResponse("The job was moved", object(map[string]*schema.Schema{
+			"message":  str(),
+			"job_id":   str(),
+			"position": enum("front", "back"),
+		}))),
+	},
+	"GET /api/admin/events": {
+		OperationID: "streamEvents",
+		Summary:     "Stream the lifecycle events of all jobs",
+		Description: streamDescription,
+		Tags:        []string{"admin"},
+		Parameters: append([]Parameter{
+			query("type", "Comma separated event types", array(eventType())),
+			query("status", "Comma separated job statuses", array(jobStatus())),
+			query("job_id", "Only events of this job", str()),
+			tenantParam(),
+		}, resumeParams()...),
+		Responses: responses(Responses{"200": eventStreamResponse()}),
+	},
+	"GET /api/admin/schemas": {
+		OperationID: "getSchemas",
+		Summary:     "Get the payload and result schemas of the queue",
+		Tags:        []string{"admin"},
+		Responses:   responses(Responses{"200": jsonResponse("The schemas, absent ones are not enforced", ref("Schemas"))}),
+	},
+	"PUT /api/admin/schemas": {
+		OperationID: "setSchemas",
+		Summary:     "Replace the payload and result schemas",
+		Description: "Omitted schemas are removed. Only jobs submitted and results completed afterwards are checked.",
+		Tags:        []string{"admin"},
+		RequestBody: jsonBody(schemas()),
+		Responses: responses(Responses{
+			"200": jsonResponse("The new schemas", ref("Schemas")),
+			"400": errorResponse("A schema is invalid"),
+		}),
+	},
+	"DELETE /api/admin/schemas": {
+		OperationID: "deleteSchemas",
+		Summary:     "Stop validating payloads and results",
+		Tags:        []string{"admin"},
+		Responses:   responses(Responses{"200": jsonResponse("No schemas", ref("Schemas"))}),
+	},
+	"GET /api/admin/limits": {
+		OperationID: "getLimits",
+		Summary:     "Get the queue limits and current depth",
+		Tags:        []string{"admin"},
+		Responses:   responses(Responses{"200": jsonResponse("The limits", ref("Limits"))}),
+	},
+	"PUT /api/admin/limits": {
+		OperationID: "setLimits",
+		Summary:     "Replace the queue limits",
+		Description: "Jobs already queued are kept when the depth is lowered, only new submissions are rejected.",
+		Tags:        []string{"admin"},
+		RequestBody: jsonBody(object(map[string]*schema.Schema{
+			"max_depth": describe(integer(), "Maximum number of queued jobs, zero is unlimited"),
+		}, "max_depth")),
+		Responses: responses(Responses{
+			"200": jsonResponse("The new limits", ref("Limits")),
+			"400": errorResponse("A limit is negative"),
+		}),
+	},
+	"GET /api/admin/fairness": {
+		OperationID: "getFairness",
+		Summary:     "Get the fairness policy of the queue",
+		Tags:        []string{"admin"},
+		Responses:   responses(Responses{"200": jsonResponse("The fairness policy", ref("Fairness"))}),
+	},
+	"PUT /api/admin/fairness": {
+		OperationID: "setFairness",
+		Summary:     "Replace the fairness policy",
+		Description: "round_robin and weighted share the queue out between the values of the key, so one key with a large backlog cannot hold back the others. Within a key, jobs keep their priority and queue order.",
+		Tags:        []string{"admin"},
+		RequestBody: jsonBody(object(fairness())),
+		Responses: responses(Responses{
+			"200": jsonResponse("The new fairness policy", ref("Fairness")),
+			"400": errorResponse("Unknown policy or key, missing header or weight out of range"),
+		}),
+	},
+	"GET /api/admin/headers": {
+		OperationID: "getHeaderRules",
+		Summary:     "Get the header capture rules of the queue",
+		Description: "The queue's own rules with the HEADER_REDACT headers redacted too, or the rules set by the HEADER_* environment variables while it has none.",
+		Tags:        []string{"admin"},
+		Responses:   responses(Responses{"200": jsonResponse("The header rules", ref("HeaderRules"))}),
+	},
+	"PUT /api/admin/headers": {
+		OperationID: "setHeaderRules",
+		Summary:     "Replace the header capture rules",
+		Description: "The rules apply to headers captured with new jobs, jobs already queued keep theirs. Header names are case-insensitive.",
+		Tags:        []string{"admin"},
+		RequestBody: jsonBody(object(headerRules())),
+		Responses: responses(Responses{
+			"200": jsonResponse("The new header rules", ref("HeaderRules")),
+			"400": errorResponse("A rule has an empty header name"),
+		}),
+	},
+	"DELETE /api/admin/headers": {
+		OperationID: "deleteHeaderRules",
+		Summary:     "Go back to the default header capture rules",
+		Description: "The queue uses the rules set by the HEADER_* environment variables again.",
+		Tags:        []string{"admin"},
+		Responses:   responses(Responses{"200": jsonResponse("The default header rules", ref("HeaderRules"))}),
+	},
+	"GET /api/admin/keys": {
+		OperationID: "listKeys",
+		Summary:     "List API keys",
+		Tags:        []string{"admin"},
+		Responses: responses(Responses{"200": jsonResponse("API keys, without their secrets", object(map[string]*schema.Schema{
+			"keys": array(ref("APIKey")),
+		}, "keys"))}),
+	},
+	"POST /api/admin/keys": {
+		OperationID: "createKey",
+		Summary:     "Create an API key",
+		Description: "The secret is only returned in this response, keys are stored hashed.",
+		Tags:        []string{"admin"},
+		RequestBody: jsonBody(keySpec()),
+		Responses: responses(Responses{
+			"201": jsonResponse("The key with its secret", ref("APIKey")),
+			"400": errorResponse("Invalid key"),
+		}),
+	},
+	"DELETE /api/admin/keys/:id": {
+		OperationID: "revokeKey",
+		Summary:     "Revoke an API key",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{pathParam("id", "Key ID")},
+		Responses: responses(Responses{
+			"200": jsonResponse("The key was revoked", object(map[string]*schema.Schema{
+				"message": str(),
+				"id":      str(),
+			}, "message", "id")),
+			"404": errorResponse("Unknown key"),
+		}),
+	},
+	"GET /api/admin/tenants": {
+		OperationID: "listTenants",
+		Summary:     "List tenants with their own quota",
+		Tags:        []string{"admin"},
+		Responses: responses(Responses{"200": jsonResponse("Tenants and the default quota", object(map[string]*schema.Schema{
+			"tenants":       array(ref("Tenant")),
+			"default_quota": ref("Quota"),
+		}, "tenants", "default_quota"))}),
+	},
+	"GET /api/admin/tenants/:id": {
+		OperationID: "getTenant",
+		Summary:     "Get a tenant's quota and usage",
+		Description: "Tenant admins can only get their own tenant.",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{pathParam("id", "Tenant ID")},
+		Responses: responses(Responses{
+			"200": jsonResponse("The tenant", ref("Tenant")),
+			"404": errorResponse("Another tenant"),
+		}),
+	},
+	"PUT /api/admin/tenants/:id": {
+		OperationID: "setTenant",
+		Summary:     "Create a tenant or replace its quota",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{pathParam("id", "Tenant ID")},
+		RequestBody: jsonBody(closed(object(map[string]*schema.Schema{
+			"quota": closed(quota()),
+		}, "quota"))),
+		Responses: responses(Responses{
+			"200": jsonResponse("The tenant", ref("Tenant")),
+			"400": errorResponse("Invalid quota"),
+		}),
+	},
+	"DELETE /api/admin/tenants/:id": {
+		OperationID: "deleteTenant",
+		Summary:     "Remove a tenant's quota",
+		Description: "The tenant's jobs are kept and fall back to the default quota.",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{pathParam("id", "Tenant ID")},
+		Responses: responses(Responses{
+			"200": jsonResponse("The tenant was deleted", object(map[string]*schema.Schema{
+				"message": str(),
+				"id":      str(),
+			}, "message", "id")),
+			"404": errorResponse("Unknown tenant"),
+		}),
+	},
+	"GET /api/admin/workers": {
+		OperationID: "listWorkers",
+		Summary:     "List workers",
+		Description: "Workers seen since the server started, with the jobs they hold. Tenant admins only see their own tenant's workers.",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{tenantParam()},
+		Responses: responses(Responses{"200": jsonResponse("Workers", object(map[string]*schema.Schema{
+			"workers": array(ref("Worker")),
+		}, "workers"))}),
+	},
+	"GET /api/admin/workers/:id": {
+		OperationID: "getWorker",
+		Summary:     "Get a worker",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{workerIDParam()},
+		Responses:   workerResponses(jsonResponse("The worker", ref("Worker"))),
+	},
+	"POST /api/admin/workers/:id/drain": {
+		OperationID: "drainWorker",
+		Summary:     "Stop giving jobs to a worker",
+		Description: "The worker keeps the jobs it holds. Its polls find no job and its long polls and Connect streams wait until it is resumed.",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{workerIDParam()},
+		Responses:   workerResponses(jsonResponse("The drained worker", ref("Worker"))),
+	},
+	"POST /api/admin/workers/:id/resume": {
+		OperationID: "resumeWorker",
+		Summary:     "Give jobs to a drained worker again",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{workerIDParam()},
+		Responses:   workerResponses(jsonResponse("The resumed worker", ref("Worker"))),
+	},
+	"POST /api/admin/workers/:id/release": {
+		OperationID: "releaseWorkerJobs",
+		Summary:     "Put the jobs of a dead worker back in the queue",
+		Description: "Every job leased to the worker goes back in its original place, and the worker's lease tokens stop working. Global admins may release the jobs of workers no longer listed, such as after a restart.",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{workerIDParam()},
+		Responses: workerResponses(jsonResponse("The released jobs", object(map[string]*schema.Schema{
+			"worker_id": str(),
+			"released":  array(str()),
+		}, "worker_id", "released"))),
+	},
+	"GET /api/admin/webhooks": {
+		OperationID: "listWebhooks",
+		Summary:     "List webhook deliveries",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{query("status", "Only deliveries in this state", enum("pending", "succeeded", "failed"))},
+		Responses: responses(Responses{"200": jsonResponse("Deliveries", object(map[string]*schema.Schema{
+			"deliveries": array(ref("WebhookDelivery")),
+		}, "deliveries"))}),
+	},
+	"GET /api/admin/webhooks/:id": {
+		OperationID: "getWebhook",
+		Summary:     "Get a webhook delivery",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{pathParam("id", "Delivery ID")},
+		Responses: responses(Responses{
+			"200": jsonResponse("The delivery", ref("WebhookDelivery")),
+			"404": errorResponse("Unknown delivery"),
+		}),
+	},
+	"POST /api/admin/webhooks/:id/replay": {
+		OperationID: "replayWebhook",
+		Summary:     "Send a delivery again with a fresh set of attempts",
+		Tags:        []string{"admin"},
+		Parameters:  []Parameter{pathParam("id", "Delivery ID")},
+		Responses: responses(Responses{
+			"200": jsonResponse("The delivery, pending again", ref("WebhookDelivery")),
+			"404": errorResponse("Unknown delivery"),
+		}),
+	},
+}
+
+const streamDescription = "Server-Sent Events by default, a WebSocket stream of JSON text frames when the request is a WebSocket upgrade. " +
+	"Resuming from an event that is no longer retained starts with a stream.resync event instead of a replay."
+
+// operationFor returns the documented operation of a route
+// Undocumented routes get a generated operation so the document still covers every route
+func operationFor(method, routePath string) *Operation {
+	if op, ok := operations[method+" "+routePath]; ok {
+		return op
+	}
+	if op, ok := operations["ALL "+routePath]; ok {
+		perMethod := *op
+		perMethod.OperationID = op.OperationID + strings.ToUpper(method[:1]) + strings.ToLower(method[1:])
+		return &perMethod
+	}
+
+	op := &Operation{
+		Summary:   method + " " + documentPath(routePath),
+		Responses: responses(Responses{"default": &Response{Description: "Undocumented response"}}),
+	}
+	for _, segment := range splitPath(routePath) {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			op.Parameters = append(op.Parameters, pathParam(name, ""))
+		} else if segment == "*" {
+			op.Parameters = append(op.Parameters, pathParam(wildcardParam, ""))
+		}
+	}
+	return op
+}
+
+func submitHeaders() []Parameter {
+	return []Parameter{
+		header("X-Callback-Url", "Webhook notified when the job reaches a terminal state, an http or https URL on a host allowed by WEBHOOK_ALLOWED_HOSTS", &schema.Schema{Type: schema.Types{schema.TypeString}, Format: "uri"}),
+		header("X-Callback-Secret", "Secret signing the webhook deliveries", str()),
+		header("X-Job-Priority", "Higher priorities are handed to workers first", integer()),
+		header("X-Job-Scheduled-At", "The job is not handed to workers before this time", dateTime()),
+		header("X-Job-Group", "Message group, jobs of a group are processed one at a time in queue order", str()),
+		header("X-Job-Requires", "Capabilities a worker needs to take the job, such as gpu,region=eu-west", str()),
+		submitWaitHeader(),
+	}
+}
+
+func resumeParams() []Parameter {
+	return []Parameter{
+		header("Last-Event-ID", "Resume after this event", integerRange(0, 1<<53)),
+		query("last_event_id", "Resume after this event, for clients that cannot set headers", integerRange(0, 1<<53)),
+	}
+}
+
+func scheduleProperties() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"priority":     integer(),
+		"scheduled_at": describe(nullable(dateTime()), "null makes the job due immediately"),
+	}
+}
+
+func pausedState() *schema.Schema {
+	return object(map[string]*schema.Schema{
+		"message": str(),
+		"paused":  boolean(),
+	}, "message", "paused")
+}
+
+func messageWithJobID() *schema.Schema {
+	return object(map[string]*schema.Schema{
+		"message": str(),
+		"job_id":  str(),
+	}, "message", "job_id")
+}
+
+// tenantParam narrows admin views to one tenant, tenant admins are always limited to their own
+func tenantParam() Parameter {
+	return query("tenant", "Only jobs of this tenant, ignored for tenant admins", str())
+}
+
+// overloadResponse is returned when a submission is rate limited, the queue is full or the tenant is over a quota
+func overloadResponse() *Response {
+	response := errorResponse("Too many submissions from the caller, the queue is full, or the caller's tenant is over a quota")
+	response.Headers = map[string]*Header{
+		"Retry-After": {Description: "Seconds until another submission may succeed", Schema: integer()},
+	}
+	return response
+}
+
+func submitWaitHeader() Parameter {
+	return header("X-Submit-Wait", "Wait up to this duration, such as 30s, for room when the queue is full", str())
+}
+
+func leaseTokenHeader() Parameter {
+	return Parameter{Name: "X-Lease-Token", In: InHeader, Description: "Lease token received when polling the job", Required: true, Schema: str()}
+}
+
+func workerIDParam() Parameter {
+	return pathParam("id", "Worker ID")
+}
+
+func workerResponses(ok *Response) Responses {
+	return responses(Responses{
+		"200": ok,
+		"404": errorResponse("Unknown worker, or a worker of another tenant"),
+	})
+}
+
+func jobIDParam() Parameter {
+	return pathParam("id", "Job ID")
+}
+
+func pathParam(name, description string) Parameter {
+	return Parameter{Name: name, In: InPath, Description: description, Required: true, Schema: str()}
+}
+
+func query(name, description string, s *schema.Schema) Parameter {
+	return Parameter{Name: name, In: InQuery, Description: description, Schema: s}
+}
+
+func header(name, description string, s *schema.Schema) Parameter {
+	return Parameter{Name: name, In: InHeader, Description: description, Schema: s}
+}
+
+func jsonBody(s *schema.Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: s}}}
+}
+
+func anyBody(description string) *RequestBody {
+	return &RequestBody{Description: description, Content: map[string]MediaType{"*/*": {}}}
+}
+
+func jsonResponse(description string, s *schema.Schema) *Response {
+	return &Response{Description: description, Content: map[string]MediaType{"application/json": {Schema: s}}}
+}
+
+func errorResponse(description string) *Response {
+	return jsonResponse(description, ref("Error"))
+}
+
+func eventStreamResponse() *Response {
+	return &Response{
+		Description: "Event stream",
+		Content:     map[string]MediaType{"text/event-stream": {Schema: ref("Event")}},
+	}
+}
+
+func jobActionResponses(ok *Response) Responses {
+	return responses(Responses{
+		"200": ok,
+		"404": errorResponse("Unknown job"),
+		"409": errorResponse("The action does not apply to the job's current status"),
+	})
+}
+
+// responses adds the validation error every operation can return
+func responses(byStatus Responses) Responses {
+	if _, ok := byStatus["400"]; !ok {
+		byStatus["400"] = errorResponse("The request does not match this specification")
+	}
+	return byStatus
+}
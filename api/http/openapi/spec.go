@@ -0,0 +1,288 @@
+package openapi
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/schema"
+)
+
+// Spec is the OpenAPI document of the server and the routes it was built from
+type Spec struct {
+	doc    *Document
+	routes []route
+}
+
+// route links a registered fiber route to its documented operation
+type route struct {
+	method   string
+	segments []string // Path segments, ":name" for parameters and "*" for a trailing wildcard
+	op       *Operation
+}
+
+// NewSpec creates an empty specification, routes are added by Build
+func NewSpec(info Info) *Spec {
+	return &Spec{
+		doc: &Document{
+			OpenAPI:    Version,
+			Info:       info,
+			Paths:      make(map[string]*PathItem),
+			Components: &Components{Schemas: components, SecuritySchemes: securitySchemes},
+			Security:   []SecurityRequirement{{"apiKey": {}}, {"bearer": {}}, {"clientCertificate": {}}},
+		},
+	}
+}
+
+// Build documents every route registered on the app
+// Routes without a documented operation get a generated one listing their path parameters
+func (s *Spec) Build(routes []fiber.Route) {
+	hasGet := make(map[string]bool)
+	for _, r := range routes {
+		if r.Method == fiber.MethodGet {
+			hasGet[normalizePath(r.Path)] = true
+		}
+	}
+
+	for _, r := range routes {
+		path := normalizePath(r.Path)
+		if r.Method == fiber.MethodConnect {
+			continue
+		}
+
+		op := operationFor(r.Method, path)
+		if !op.Public() {
+			addAuthResponses(op)
+		}
+		s.routes = append(s.routes, route{method: r.Method, segments: splitPath(path), op: op})
+
+		// HEAD is served by GET handlers and not documented separately
+		if r.Method == fiber.MethodHead && hasGet[path] {
+			continue
+		}
+
+		docPath := documentPath(path)
+		item, ok := s.doc.Paths[docPath]
+		if !ok {
+			item = &PathItem{}
+			s.doc.Paths[docPath] = item
+		}
+		(*item)[strings.ToLower(r.Method)] = op
+	}
+}
+
+// addAuthResponses documents the errors of protected operations when authentication is enabled
+func addAuthResponses(op *Operation) {
+	if _, ok := op.Responses["401"]; !ok {
+		op.Responses["401"] = errorResponse("Missing or invalid API key or bearer token, when authentication is enabled")
+	}
+	if _, ok := op.Responses["403"]; !ok {
+		op.Responses["403"] = errorResponse("The credentials lack the role of the operation, or access to the queue")
+	}
+}
+
+// Document returns the OpenAPI document
+func (s *Spec) Document() *Document {
+	return s.doc
+}
+
+// Handler serves the OpenAPI document as JSON
+func (s *Spec) Handler(c *fiber.Ctx) error {
+	return c.JSON(s.doc)
+}
+
+// Validate rejects requests whose parameters or JSON body do not match their operation
+// Requests to unknown routes are passed on for the router to answer
+func (s *Spec) Validate(c *fiber.Ctx) error {
+	op, pathParams := s.match(c.Method(), c.Path())
+	if op == nil {
+		return c.Next()
+	}
+
+	var details []apierror.Detail
+	for _, param := range op.Parameters {
+		raw, ok := parameterValue(c, param, pathParams)
+		if !ok {
+			if param.Required {
+				details = append(details, apierror.Detail{In: param.In, Field: param.Name, Message: "is required"})
+			}
+			continue
+		}
+		for _, fieldErr := range param.Schema.Validate(coerce(raw, param.Schema)) {
+			details = append(details, apierror.Detail{In: param.In, Field: param.Name + fieldErr.Path, Message: fieldErr.Message})
+		}
+	}
+
+	if op.RequestBody != nil {
+		bodyDetails, err := validateBody(c, op.RequestBody)
+		if err != nil {
+			return err
+		}
+		details = append(details, bodyDetails...)
+	}
+
+	if len(details) > 0 {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed, "request does not match the API specification").
+			WithDetails(details...)
+	}
+	return c.Next()
+}
+
+// match finds the operation of a request and its path parameters
+func (s *Spec) match(method, path string) (*Operation, map[string]string) {
+	segments := splitPath(path)
+	for _, r := range s.routes {
+		if r.method != method {
+			continue
+		}
+		if params, ok := r.match(segments); ok {
+			return r.op, params
+		}
+	}
+	return nil, nil
+}
+
+func (r route) match(segments []string) (map[string]string, bool) {
+	params := make(map[string]string)
+	for i, segment := range r.segments {
+		if segment == "*" {
+			params[wildcardParam] = strings.Join(segments[i:], "/")
+			return params, true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			params[name] = segments[i]
+			continue
+		}
+		if !strings.EqualFold(segment, segments[i]) {
+			return nil, false
+		}
+	}
+	return params, len(segments) == len(r.segments)
+}
+
+// parameterValue returns a parameter's raw value, empty values count as missing
+func parameterValue(c *fiber.Ctx, param Parameter, pathParams map[string]string) (string, bool) {
+	var value string
+	switch param.In {
+	case InPath:
+		value = pathParams[param.Name]
+	case InQuery:
+		value = c.Query(param.Name)
+	case InHeader:
+		value = c.Get(param.Name)
+	}
+	return value, value != ""
+}
+
+// coerce converts a raw parameter to the JSON type its schema expects
+// Values that do not convert are kept as strings so validation reports the type mismatch
+func coerce(raw string, s *schema.Schema) interface{} {
+	switch {
+	case s == nil:
+		return raw
+	case s.Type.Has(schema.TypeArray):
+		items := make([]interface{}, 0)
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, coerce(item, s.Items))
+			}
+		}
+		return items
+	case s.Type.Has(schema.TypeInteger), s.Type.Has(schema.TypeNumber):
+		if value, err := strconv.ParseFloat(raw, 64); err == nil {
+			return value
+		}
+	case s.Type.Has(schema.TypeBoolean):
+		if value, err := strconv.ParseBool(raw); err == nil {
+			return value
+		}
+	}
+	return raw
+}
+
+// validateBody checks the request content type and validates JSON bodies against their schema
+func validateBody(c *fiber.Ctx, body *RequestBody) ([]apierror.Detail, error) {
+	data := c.Body()
+	if len(data) == 0 {
+		if body.Required {
+			return []apierror.Detail{{In: "body", Message: "request body is required"}}, nil
+		}
+		return nil, nil
+	}
+
+	media, ok := body.Content[mediaType(c)]
+	if !ok {
+		if media, ok = body.Content["*/*"]; !ok {
+			return nil, apierror.New(fiber.StatusUnsupportedMediaType, apierror.CodeUnsupportedMediaType,
+				"content type must be one of: "+strings.Join(mediaTypes(body), ", "))
+		}
+	}
+	if media.Schema == nil {
+		return nil, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidRequestBody, "request body is not valid JSON: "+err.Error())
+	}
+
+	var details []apierror.Detail
+	for _, fieldErr := range media.Schema.Validate(value) {
+		details = append(details, apierror.Detail{In: "body", Field: fieldErr.Path, Message: fieldErr.Message})
+	}
+	return details, nil
+}
+
+// mediaType returns the request content type without parameters
+func mediaType(c *fiber.Ctx) string {
+	value, _, _ := strings.Cut(string(c.Request().Header.ContentType()), ";")
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+func mediaTypes(body *RequestBody) []string {
+	types := make([]string, 0, len(body.Content))
+	for name := range body.Content {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// wildcardParam names the parameter documenting a trailing "*" in a route
+const wildcardParam = "path"
+
+// normalizePath removes the trailing slash fiber ignores when matching
+func normalizePath(path string) string {
+	if len(path) > 1 {
+		return strings.TrimRight(path, "/")
+	}
+	return path
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// documentPath converts a fiber route path to an OpenAPI path template
+func documentPath(path string) string {
+	segments := splitPath(path)
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			segments[i] = "{" + name + "}"
+		} else if segment == "*" {
+			segments[i] = "{" + wildcardParam + "}"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
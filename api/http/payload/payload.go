@@ -0,0 +1,69 @@
+package payload
+
+import (
+	"encoding/base64"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+// Encodings used when a payload is embedded in a JSON document
+const (
+	EncodingText   = "text"
+	EncodingBase64 = "base64"
+)
+
+// DefaultContentType is used when a payload has no declared content type
+const DefaultContentType = "application/octet-stream"
+
+// Encode converts raw payload bytes into a string that can be embedded in JSON.
+// Valid UTF-8 is returned as text unless base64 is forced, anything else is base64 encoded.
+func Encode(data []byte, forceBase64 bool) (string, string) {
+	if !forceBase64 && utf8.Valid(data) {
+		return string(data), EncodingText
+	}
+	return base64.StdEncoding.EncodeToString(data), EncodingBase64
+}
+
+// ContentTypeOrDefault returns the content type, falling back to DefaultContentType
+func ContentTypeOrDefault(contentType string) string {
+	if contentType == "" {
+		return DefaultContentType
+	}
+	return contentType
+}
+
+// hopHeaders are connection-level headers that must not be copied to a response
+var hopHeaders = map[string]bool{
+	"Connection":          true,
+	"Content-Length":      true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+}
+
+// WriteResult writes a job result exactly as the worker produced it:
+// status code, headers, content type and raw body
+func WriteResult(c *fiber.Ctx, job *queue.Message) error {
+	statusCode := job.ResultStatusCode
+	if statusCode == 0 {
+		statusCode = fiber.StatusOK
+	}
+
+	for key, value := range job.ResultHeaders {
+		if hopHeaders[http.CanonicalHeaderKey(key)] {
+			continue
+		}
+		c.Set(key, value)
+	}
+	c.Set(fiber.HeaderContentType, ContentTypeOrDefault(job.ResultContentType))
+
+	return c.Status(statusCode).Send(job.Result)
+}
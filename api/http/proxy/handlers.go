@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/api/http/payload"
+	"github.com/PAFFx/job-poll-queue/api/http/submit"
+	"github.com/PAFFx/job-poll-queue/queue"
+	"github.com/PAFFx/job-poll-queue/webhook"
+)
+
+type Handler struct {
+	jobQueue *queue.Queue
+	webhooks *webhook.Dispatcher
+	maxWait  time.Duration
+}
+
+// NewHandler creates a proxy handler, requests wait at most maxWait for room in a full queue
+// Callback URLs are checked against the webhook dispatcher's allowlist
+func NewHandler(jobQueue *queue.Queue, webhooks *webhook.Dispatcher, maxWait time.Duration) *Handler {
+	return &Handler{jobQueue: jobQueue, webhooks: webhooks, maxWait: maxWait}
+}
+
+func (h *Handler) RegisterRoutes(router fiber.Router) {
+	router.All("/*", h.ProxyHandler) // Any method, any path below the proxy prefix
+}
+
+// ProxyHandler captures the full client request as a job and replies with the worker's response
+// Job options are read from the same headers as on the submit route
+func (h *Handler) ProxyHandler(c *fiber.Ctx) error {
+	msg, err := submit.NewMessage(c, h.webhooks)
+	if err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidHeader, err.Error())
+	}
+	msg.Request = &queue.HTTPRequest{
+		Method: c.Method(),
+		Path:   "/" + c.Params("*"),
+		Query:  string(c.Request().URI().QueryString()),
+	}
+	wait, err := submit.ParseWait(c, h.maxWait)
+	if err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidHeader, err.Error())
+	}
+
+	// Submit job and wait for result
+	result, err := h.SubmitRequest(msg, wait)
+	if err != nil {
+		return submit.JobError(c, err, fiber.StatusBadGateway)
+	}
+
+	// Reply exactly as the worker responded
+	return payload.WriteResult(c, result)
+}
@@ -0,0 +1,24 @@
+package proxy
+
+import (
+	"time"
+
+	"github.com/PAFFx/job-poll-queue/api/http/submit"
+	"github.com/PAFFx/job-poll-queue/queue"
+	"github.com/google/uuid"
+)
+
+// SubmitRequest queues a proxied HTTP request and waits for the worker's response
+// A full queue is waited on for up to wait
+func (h *Handler) SubmitRequest(msg queue.Message, wait time.Duration) (*queue.Message, error) {
+	// Create a job
+	msg.ID = uuid.New().String()
+
+	// Add job to queue
+	if err := submit.Push(h.jobQueue, msg, wait); err != nil {
+		return nil, err
+	}
+
+	// Wait indefinitely for the result
+	return h.jobQueue.GetStatusManager().WaitForCompletionWithoutTimeout(msg.ID)
+}
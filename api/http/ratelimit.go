@@ -0,0 +1,24 @@
+package http
+
+import (
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/auth"
+	"github.com/gofiber/fiber/v2"
+)
+
+// limitSubmissions rejects submissions over the rate limit of the caller
+// Authenticated callers are limited per credential, anonymous ones per client IP
+func (s *Server) limitSubmissions(c *fiber.Ctx) error {
+	if wait := s.limiter.Allow(rateLimitKey(c)); wait > 0 {
+		return apierror.RateLimited(c, wait)
+	}
+	return c.Next()
+}
+
+// rateLimitKey names the bucket of the caller
+func rateLimitKey(c *fiber.Ctx) string {
+	if principal := auth.FromContext(c.UserContext()); principal != nil {
+		return principal.Method + ":" + principal.ID
+	}
+	return "ip:" + c.IP()
+}
@@ -1,10 +1,21 @@
 package http
 
 import (
+	"crypto/tls"
+	"net"
+	"time"
+
 	"github.com/PAFFx/job-poll-queue/api/http/admin"
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/api/http/jobs"
+	"github.com/PAFFx/job-poll-queue/api/http/openapi"
+	"github.com/PAFFx/job-poll-queue/api/http/proxy"
 	"github.com/PAFFx/job-poll-queue/api/http/submit"
 	"github.com/PAFFx/job-poll-queue/api/http/worker"
+	"github.com/PAFFx/job-poll-queue/auth"
 	"github.com/PAFFx/job-poll-queue/queue"
+	"github.com/PAFFx/job-poll-queue/ratelimit"
+	"github.com/PAFFx/job-poll-queue/webhook"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
@@ -14,33 +25,47 @@ import (
 type Server struct {
 	app      *fiber.App
 	jobQueue *queue.Queue
+	webhooks *webhook.Dispatcher
+	auth     *auth.Authenticator
+	spec     *openapi.Spec
+	limiter  *ratelimit.Limiter
+	limits   Limits
+}
+
+// Limits protects the queue from submitters sending more than it can take
+type Limits struct {
+	SubmitRate    ratelimit.Limit // Per API key, token subject or client IP
+	MaxSubmitWait time.Duration   // Longest a submission may wait for room in a full queue
+	MaxPollWait   time.Duration   // Longest a worker poll may wait for a job
 }
 
-// NewServer creates a new API server with the provided job queue
-func NewServer(jobQueue *queue.Queue) *Server {
+// NewServer creates a new API server with the provided job queue, webhook dispatcher, authenticator and limits
+func NewServer(jobQueue *queue.Queue, webhooks *webhook.Dispatcher, authenticator *auth.Authenticator, limits Limits) *Server {
 	app := fiber.New(fiber.Config{
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-			}
-			return c.Status(code).JSON(fiber.Map{
-				"error": err.Error(),
-			})
-		},
+		// Values from Params, Query and Get are stored in jobs, so they must not alias request buffers
+		Immutable:    true,
+		ErrorHandler: apierror.Handler,
 	})
 
-	// Add middlewares
-	app.Use(logger.New())
-	app.Use(recover.New())
-
 	server := &Server{
 		app:      app,
 		jobQueue: jobQueue,
+		webhooks: webhooks,
+		auth:     authenticator,
+		spec:     openapi.NewSpec(openapi.Info{Title: "Job Poll Queue", Version: "1.0.0"}),
+		limiter:  ratelimit.NewLimiter(limits.SubmitRate),
+		limits:   limits,
 	}
 
-	// Register routes
+	// Add middlewares, authenticated requests are validated against the OpenAPI document
+	app.Use(logger.New())
+	app.Use(recover.New())
+	app.Use(server.authenticate)
+	app.Use(server.spec.Validate)
+
+	// Register routes, then document them
 	server.registerRoutes()
+	server.spec.Build(app.GetRoutes(true))
 
 	return server
 }
@@ -48,17 +73,26 @@ func NewServer(jobQueue *queue.Queue) *Server {
 // registerRoutes sets up all API routes
 func (s *Server) registerRoutes() {
 	api := s.app.Group("/api")
+	api.Get("/openapi.json", s.spec.Handler)
 
 	adminGroup := api.Group("/admin")
-	adminHandler := admin.NewHandler(s.jobQueue)
+	adminHandler := admin.NewHandler(s.jobQueue, s.webhooks, s.auth.Keys())
 	adminHandler.RegisterRoutes(adminGroup)
 
-	submitGroup := api.Group("/submit")
-	submitHandler := submit.NewHandler(s.jobQueue)
+	submitGroup := api.Group("/submit", s.limitSubmissions)
+	submitHandler := submit.NewHandler(s.jobQueue, s.webhooks, s.limits.MaxSubmitWait)
 	submitHandler.RegisterRoutes(submitGroup)
 
+	jobsGroup := api.Group("/jobs")
+	jobsHandler := jobs.NewHandler(s.jobQueue)
+	jobsHandler.RegisterRoutes(jobsGroup)
+
+	proxyGroup := api.Group("/proxy", s.limitSubmissions)
+	proxyHandler := proxy.NewHandler(s.jobQueue, s.webhooks, s.limits.MaxSubmitWait)
+	proxyHandler.RegisterRoutes(proxyGroup)
+
 	workerGroup := api.Group("/worker")
-	workerHandler := worker.NewHandler(s.jobQueue)
+	workerHandler := worker.NewHandler(s.jobQueue, s.limits.MaxPollWait)
 	workerHandler.RegisterRoutes(workerGroup)
 }
 
@@ -66,3 +100,12 @@ func (s *Server) registerRoutes() {
 func (s *Server) Start(address string) error {
 	return s.app.Listen(address)
 }
+
+// StartTLS starts the API server on the given address, serving TLS with the configuration
+func (s *Server) StartTLS(address string, tlsConfig *tls.Config) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.app.Listener(tls.NewListener(listener, tlsConfig))
+}
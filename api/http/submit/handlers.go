@@ -2,56 +2,228 @@ package submit
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/api/http/payload"
+	"github.com/PAFFx/job-poll-queue/auth"
 	"github.com/PAFFx/job-poll-queue/queue"
+	"github.com/PAFFx/job-poll-queue/webhook"
 )
 
+const (
+	// CallbackURLHeader sets the webhook notified when the job finishes
+	CallbackURLHeader = "X-Callback-Url"
+	// CallbackSecretHeader sets the secret used to sign webhook deliveries
+	CallbackSecretHeader = "X-Callback-Secret"
+	// PriorityHeader sets the job priority, higher is popped first
+	PriorityHeader = "X-Job-Priority"
+	// ScheduledAtHeader delays the job until the given RFC 3339 time
+	ScheduledAtHeader = "X-Job-Scheduled-At"
+	// RequiresHeader lists the capabilities a worker needs to take the job, such as "gpu, region=eu-west"
+	RequiresHeader = "X-Job-Requires"
+	// GroupHeader sets the message group, jobs of a group are processed one at a time in submission order
+	GroupHeader = "X-Job-Group"
+	// WaitHeader sets how long a submission waits for room when the queue is full, as a duration such as 30s
+	WaitHeader = "X-Submit-Wait"
+)
+
+// maxGroupLength bounds the message group key
+const maxGroupLength = 256
+
 type Handler struct {
 	jobQueue *queue.Queue
+	webhooks *webhook.Dispatcher
+	maxWait  time.Duration
 }
 
-func NewHandler(jobQueue *queue.Queue) *Handler {
-	return &Handler{jobQueue: jobQueue}
+// NewHandler creates a submit handler, submissions wait at most maxWait for room in a full queue
+// Callback URLs are checked against the webhook dispatcher's allowlist
+func NewHandler(jobQueue *queue.Queue, webhooks *webhook.Dispatcher, maxWait time.Duration) *Handler {
+	return &Handler{jobQueue: jobQueue, webhooks: webhooks, maxWait: maxWait}
 }
 
 func (h *Handler) RegisterRoutes(router fiber.Router) {
-	router.Post("/", h.SubmitJobSyncHandler) // Only synchronous job submission
+	router.Post("/", h.SubmitJobSyncHandler)
+	router.Post("/async", h.SubmitJobAsyncHandler) // Fire-and-forget, usually with a callback
 }
 
 // SubmitJobSyncHandler handles synchronous job submission
 func (h *Handler) SubmitJobSyncHandler(c *fiber.Ctx) error {
-	// Get the raw request body as payload
-	rawBody := c.Body()
-
-	// Convert raw body to JSON string for the payload
-	payload := string(rawBody)
-
-	// Use standard headers from the request
-	headers := make(map[string]string)
-	c.Request().Header.VisitAll(func(key, value []byte) {
-		headers[string(key)] = string(value)
-	})
+	msg, err := NewMessage(c, h.webhooks)
+	if err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidHeader, err.Error())
+	}
+	wait, err := ParseWait(c, h.maxWait)
+	if err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidHeader, err.Error())
+	}
 
 	// Submit job and wait for result
-	result, err := h.SubmitJobSync(payload, headers)
+	result, err := h.SubmitJobSync(msg, wait)
 	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to process job: "+err.Error())
+		return JobError(c, err, fiber.StatusInternalServerError)
+	}
+
+	// Transparent mode returns exactly what the worker produced
+	if c.Query("mode") == "transparent" {
+		return payload.WriteResult(c, result)
 	}
 
 	// Parse the result payload as JSON if possible
 	var resultPayload interface{}
-	if err := json.Unmarshal([]byte(result.Result), &resultPayload); err != nil {
-		// If it's not valid JSON, use the raw string
-		resultPayload = result.Result
+	encoding := payload.EncodingText
+	if err := json.Unmarshal(result.Result, &resultPayload); err != nil {
+		// If it's not valid JSON, use the raw string (base64 for binary results)
+		resultPayload, encoding = payload.Encode(result.Result, false)
 	}
 
 	// Return the job result
 	return c.JSON(fiber.Map{
-		"job_id":       result.ID,
-		"payload":      resultPayload,
-		"created_at":   result.CreatedAt,
-		"completed_at": result.CompletedAt,
+		"job_id":           result.ID,
+		"payload":          resultPayload,
+		"payload_encoding": encoding,
+		"content_type":     result.ResultContentType,
+		"created_at":       result.CreatedAt,
+		"completed_at":     result.CompletedAt,
+	})
+}
+
+// SubmitJobAsyncHandler queues a job and returns immediately with its ID
+func (h *Handler) SubmitJobAsyncHandler(c *fiber.Ctx) error {
+	msg, err := NewMessage(c, h.webhooks)
+	if err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidHeader, err.Error())
+	}
+	wait, err := ParseWait(c, h.maxWait)
+	if err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidHeader, err.Error())
+	}
+
+	if err := h.SubmitJobAsync(&msg, wait); err != nil {
+		if apiErr := queueError(c, err); apiErr != nil {
+			return apiErr
+		}
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to queue job: "+err.Error())
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"job_id": msg.ID,
+		"status": queue.JobStatusPending,
+	})
+}
+
+// JobError converts the error of a job submitted and waited on into an API error
+// Jobs that failed are reported with failedStatus
+func JobError(c *fiber.Ctx, err error, failedStatus int) error {
+	if apiErr := queueError(c, err); apiErr != nil {
+		return apiErr
+	}
+	if errors.Is(err, queue.ErrJobCancelled) {
+		return apierror.New(fiber.StatusConflict, apierror.CodeJobCancelled, "Failed to process job: "+err.Error())
+	}
+	return apierror.New(failedStatus, apierror.CodeJobFailed, "Failed to process job: "+err.Error())
+}
+
+// queueError converts the errors of adding a job to the queue into API errors, nil for other errors
+func queueError(c *fiber.Ctx, err error) error {
+	var schemaErr *queue.SchemaError
+	if errors.As(err, &schemaErr) {
+		return apierror.SchemaViolation(apierror.CodeInvalidPayload, schemaErr)
+	}
+	var quotaErr *queue.QuotaError
+	if errors.As(err, &quotaErr) {
+		return apierror.QuotaExceeded(c, quotaErr)
+	}
+	var capacityErr *queue.CapacityError
+	if errors.As(err, &capacityErr) {
+		return apierror.QueueFull(c, capacityErr)
+	}
+	return nil
+}
+
+// NewMessage builds a job from the request body, headers and job options
+// Option headers such as callbacks, priority, group and requirements are parsed and not passed on to workers,
+// callback URLs must be allowed by webhooks
+func NewMessage(c *fiber.Ctx, webhooks *webhook.Dispatcher) (queue.Message, error) {
+	// Get the raw request body as payload (copied, fiber reuses the buffer)
+	principal := auth.FromContext(c.UserContext())
+	msg := queue.Message{
+		Payload:     append([]byte(nil), c.Body()...),
+		ContentType: string(c.Request().Header.ContentType()),
+		Headers:     make(map[string]string),
+		SubmittedBy: principal.Submitter(),
+		Tenant:      principal.TenantID(),
+	}
+
+	// Use standard headers from the request
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		msg.Headers[string(key)] = string(value)
 	})
+
+	// Callback settings are not passed on to workers
+	if callbackURL := c.Get(CallbackURLHeader); callbackURL != "" {
+		if err := webhooks.CheckURL(callbackURL); err != nil {
+			return msg, err
+		}
+		msg.Callback = &queue.Callback{
+			URL:    callbackURL,
+			Secret: c.Get(CallbackSecretHeader),
+		}
+	}
+	delete(msg.Headers, CallbackURLHeader)
+	delete(msg.Headers, CallbackSecretHeader)
+
+	// Scheduling settings
+	if priority := c.Get(PriorityHeader); priority != "" {
+		value, err := strconv.Atoi(priority)
+		if err != nil {
+			return msg, errors.New("invalid job priority: " + priority)
+		}
+		msg.Priority = value
+	}
+	if scheduledAt := c.Get(ScheduledAtHeader); scheduledAt != "" {
+		value, err := time.Parse(time.RFC3339, scheduledAt)
+		if err != nil {
+			return msg, errors.New("invalid scheduled time, expected RFC 3339: " + scheduledAt)
+		}
+		msg.ScheduledAt = &value
+	}
+	if requires := c.Get(RequiresHeader); requires != "" {
+		requirements, err := queue.ParseRequirements(requires)
+		if err != nil {
+			return msg, errors.New("invalid job requirements: " + err.Error())
+		}
+		msg.Requirements = requirements
+	}
+	msg.Group = c.Get(GroupHeader)
+	if len(msg.Group) > maxGroupLength {
+		return msg, fmt.Errorf("job group must not be longer than %d characters", maxGroupLength)
+	}
+	delete(msg.Headers, PriorityHeader)
+	delete(msg.Headers, ScheduledAtHeader)
+	delete(msg.Headers, RequiresHeader)
+	delete(msg.Headers, GroupHeader)
+	delete(msg.Headers, WaitHeader)
+
+	return msg, nil
+}
+
+// ParseWait returns how long the submission may wait for room, capped at maxWait
+// Without the header, or when maxWait is zero, a full queue rejects the job at once
+func ParseWait(c *fiber.Ctx, maxWait time.Duration) (time.Duration, error) {
+	value := c.Get(WaitHeader)
+	if value == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(value)
+	if err != nil || wait < 0 {
+		return 0, errors.New("invalid submit wait, expected a duration such as 30s: " + value)
+	}
+	return min(wait, maxWait), nil
 }
@@ -1,27 +1,40 @@
 package submit
 
 import (
+	"context"
+	"time"
+
 	"github.com/PAFFx/job-poll-queue/queue"
 	"github.com/google/uuid"
 )
 
 // SubmitJobSync adds a job to the queue and waits for its result
-func (h *Handler) SubmitJobSync(payload string, headers map[string]string) (*queue.Message, error) {
+// A full queue is waited on for up to wait before the job is rejected
+func (h *Handler) SubmitJobSync(msg queue.Message, wait time.Duration) (*queue.Message, error) {
 	// Create a job
-	jobID := uuid.New().String()
-
-	// Create message with provided payload and headers
-	msg := queue.Message{
-		ID:      jobID,
-		Payload: payload,
-		Headers: headers,
-	}
+	msg.ID = uuid.New().String()
 
 	// Add job to queue
-	if err := h.jobQueue.Push(msg); err != nil {
+	if err := Push(h.jobQueue, msg, wait); err != nil {
 		return nil, err
 	}
 
 	// Wait indefinitely for the result
-	return h.jobQueue.GetStatusManager().WaitForCompletionWithoutTimeout(jobID)
+	return h.jobQueue.GetStatusManager().WaitForCompletionWithoutTimeout(msg.ID)
+}
+
+// SubmitJobAsync adds a job to the queue without waiting for its result, msg.ID is set on return
+func (h *Handler) SubmitJobAsync(msg *queue.Message, wait time.Duration) error {
+	msg.ID = uuid.New().String()
+	return Push(h.jobQueue, *msg, wait)
+}
+
+// Push adds a job to the queue, waiting up to wait for room when the queue is full
+func Push(jobQueue *queue.Queue, msg queue.Message, wait time.Duration) error {
+	if wait <= 0 {
+		return jobQueue.Push(msg)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	return jobQueue.PushWait(ctx, msg)
 }
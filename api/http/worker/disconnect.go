@@ -0,0 +1,19 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// requestContext returns a context that ends when the client of the request disconnects or the server
+// shuts down. The request context alone only ends on shutdown. The returned function releases it
+// and must be called before the handler returns
+func requestContext(c *fiber.Ctx) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(c.Context())
+	stop := watchDisconnect(c.Context().Conn(), cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package worker
+
+import (
+	"context"
+	"net"
+)
+
+// watchDisconnect is not supported on this platform, waits only end on timeout or shutdown
+func watchDisconnect(conn net.Conn, disconnected context.CancelFunc) func() {
+	return func() {}
+}
@@ -0,0 +1,58 @@
+//go:build unix
+
+package worker
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// watchDisconnect calls disconnected when the peer closes the connection, without reading from it
+// The returned function stops watching and waits for the watcher to exit
+func watchDisconnect(conn net.Conn, disconnected context.CancelFunc) func() {
+	// TLS connections are watched on the connection underneath
+	tlsConn, isTLS := conn.(*tls.Conn)
+	if isTLS {
+		conn = tlsConn.NetConn()
+	}
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return func() {}
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		closed := false
+		buf := make([]byte, 1)
+		err := raw.Read(func(fd uintptr) bool {
+			// Peek, so a request the client sends next stays for the server to read
+			n, _, err := syscall.Recvfrom(int(fd), buf, syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+			if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) {
+				return false
+			}
+			// A waiting TLS client sends nothing but the close_notify alert ahead of closing. Encrypted, it looks
+			// like a next request, which only ends the wait early
+			closed = n == 0 || err != nil || isTLS
+			return true
+		})
+		if err == nil && closed {
+			disconnected()
+		}
+	}()
+
+	return func() {
+		// A past deadline wakes the watcher; the server sets its own deadlines before reading again
+		conn.SetReadDeadline(time.Unix(1, 0))
+		<-done
+		conn.SetReadDeadline(time.Time{})
+	}
+}
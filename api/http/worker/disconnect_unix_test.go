@@ -0,0 +1,123 @@
+//go:build unix
+
+package worker
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// selfSigned creates a certificate for the loopback address
+func selfSigned(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// connect returns both ends of a loopback connection, over TLS when useTLS is set
+func connect(t *testing.T, useTLS bool) (server net.Conn, client net.Conn) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Errorf("Accept: %v", err)
+		}
+		accepted <- conn
+	}()
+	client, err = net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	server = <-accepted
+	if server == nil {
+		t.FailNow()
+	}
+
+	if useTLS {
+		tlsServer := tls.Server(server, &tls.Config{Certificates: []tls.Certificate{selfSigned(t)}})
+		tlsClient := tls.Client(client, &tls.Config{InsecureSkipVerify: true})
+		handshake := make(chan error, 1)
+		go func() { handshake <- tlsServer.Handshake() }()
+		if err := tlsClient.Handshake(); err != nil {
+			t.Fatalf("client Handshake: %v", err)
+		}
+		if err := <-handshake; err != nil {
+			t.Fatalf("server Handshake: %v", err)
+		}
+		server, client = tlsServer, tlsClient
+	}
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return server, client
+}
+
+func TestWatchDisconnect(t *testing.T) {
+	tests := []struct {
+		name   string
+		useTLS bool
+	}{
+		{"tcp", false},
+		{"tls", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Run("client stays", func(t *testing.T) {
+				server, _ := connect(t, tt.useTLS)
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				stop := watchDisconnect(server, cancel)
+				time.Sleep(50 * time.Millisecond)
+				stop()
+				if ctx.Err() != nil {
+					t.Error("watcher reported a connected client as gone")
+				}
+			})
+
+			t.Run("client closes", func(t *testing.T) {
+				server, client := connect(t, tt.useTLS)
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				stop := watchDisconnect(server, cancel)
+				defer stop()
+				client.Close()
+				select {
+				case <-ctx.Done():
+				case <-time.After(5 * time.Second):
+					t.Error("watcher did not notice the client closing")
+				}
+			})
+		})
+	}
+}
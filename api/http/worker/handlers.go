@@ -1,27 +1,85 @@
 package worker
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/api/http/payload"
+	"github.com/PAFFx/job-poll-queue/auth"
 	"github.com/PAFFx/job-poll-queue/queue"
 	"github.com/gofiber/fiber/v2"
 )
 
+const (
+	// ResultStatusHeader carries the status code returned to the submitter
+	ResultStatusHeader = "X-Result-Status"
+	// ResultHeaderPrefix marks headers that are returned to the submitter
+	ResultHeaderPrefix = "X-Result-Header-"
+	// LeaseTokenHeader carries the lease token of a polled job, required to complete or fail it or send heartbeats
+	LeaseTokenHeader = "X-Lease-Token"
+)
+
 type Handler struct {
 	jobQueue *queue.Queue
+	maxWait  time.Duration
 }
 
-func NewHandler(jobQueue *queue.Queue) *Handler {
-	return &Handler{jobQueue: jobQueue}
+// NewHandler creates a worker handler, polls wait at most maxWait for a job
+func NewHandler(jobQueue *queue.Queue, maxWait time.Duration) *Handler {
+	return &Handler{jobQueue: jobQueue, maxWait: maxWait}
 }
 
 func (h *Handler) RegisterRoutes(router fiber.Router) {
+	router.Post("/register", h.RegisterHandler)
 	router.Get("/poll", h.RequestJobHandler)
 	router.Post("/complete/:id", h.CompleteJobHandler)
+	router.Post("/fail/:id", h.FailJobHandler)
+	router.Post("/heartbeat/:id", h.HeartbeatHandler)
+}
+
+// RegisterHandler records a worker's identity, polls then pass its ID as worker_id
+func (h *Handler) RegisterHandler(c *fiber.Ctx) error {
+	var info queue.WorkerInfo
+	if err := c.BodyParser(&info); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidRequestBody, "Invalid request body")
+	}
+
+	worker, err := h.jobQueue.RegisterWorker(info, auth.FromContext(c.UserContext()).TenantID())
+	if err != nil {
+		return workerError(err)
+	}
+	return c.JSON(worker)
 }
 
 func (h *Handler) RequestJobHandler(c *fiber.Ctx) error {
-	job, err := h.RequestJob()
+	wait, err := h.ParseWait(c.Query("wait"))
+	if err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+	}
+
+	workerID := c.Query("worker_id")
+	if workerID != "" {
+		if err := (queue.WorkerInfo{ID: workerID}).Validate(); err != nil {
+			return apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		}
+	}
+
+	capabilities, err := queue.ParseRequirements(c.Query("capabilities"))
 	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to poll for job")
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+	}
+
+	// A worker that hangs up stops waiting, so no job is leased to it
+	ctx, cancel := requestContext(c)
+	defer cancel()
+	job, err := h.RequestJob(ctx, workerID, auth.FromContext(c.UserContext()).TenantID(), capabilities, wait)
+	if errors.Is(err, queue.ErrWorkerTenant) {
+		return workerError(err)
+	} else if err != nil {
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to poll for job")
 	}
 
 	if job == nil {
@@ -30,26 +88,66 @@ func (h *Handler) RequestJobHandler(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(h.FormatJobResponse(job))
+	// Raw mode returns the payload bytes as the body and job metadata as headers
+	if c.Query("format") == "raw" {
+		headers, err := json.Marshal(job.Headers)
+		if err != nil {
+			return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to encode job headers")
+		}
+		c.Set("X-Job-ID", job.ID)
+		c.Set(LeaseTokenHeader, job.LeaseToken)
+		c.Set("X-Job-Headers", string(headers))
+		if job.Request != nil {
+			c.Set("X-Job-Method", job.Request.Method)
+			c.Set("X-Job-Path", job.Request.Path)
+			c.Set("X-Job-Query", job.Request.Query)
+		}
+		c.Set(fiber.HeaderContentType, payload.ContentTypeOrDefault(job.ContentType))
+		return c.Send(job.Payload)
+	}
+
+	return c.JSON(h.FormatJobResponse(job, c.Query("encoding") == payload.EncodingBase64))
 }
 
 func (h *Handler) CompleteJobHandler(c *fiber.Ctx) error {
 	jobID := c.Params("id")
 	if err := h.ValidateJobID(jobID); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
 	}
 
-	// Get the raw request body as the result
-	result := string(c.Body())
+	// Get the raw request body as the result (copied, fiber reuses the buffer)
+	result := append([]byte(nil), c.Body()...)
+	contentType := string(c.Request().Header.ContentType())
 
 	// If body is empty, set a default message
-	if result == "" {
-		result = "{}"
+	if len(result) == 0 {
+		result = []byte("{}")
+		contentType = fiber.MIMEApplicationJSON
 	}
 
+	// Workers choose the response status and headers returned to the submitter
+	statusCode, err := h.ParseResultStatus(c.Get(ResultStatusHeader))
+	if err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidHeader, err.Error())
+	}
+	resultHeaders := make(map[string]string)
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		if name, ok := strings.CutPrefix(string(key), ResultHeaderPrefix); ok && name != "" {
+			resultHeaders[name] = string(value)
+		}
+	})
+
 	// Complete the job with the provided payload
-	if err := h.CompleteJob(jobID, result); err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to complete job: "+err.Error())
+	var schemaErr *queue.SchemaError
+	if err := h.CompleteJob(jobID, c.Get(LeaseTokenHeader), auth.FromContext(c.UserContext()).TenantID(), queue.JobResult{
+		Payload:     result,
+		ContentType: contentType,
+		StatusCode:  statusCode,
+		Headers:     resultHeaders,
+	}); errors.As(err, &schemaErr) {
+		return apierror.SchemaViolation(apierror.CodeInvalidResult, schemaErr)
+	} else if err != nil {
+		return leaseError(err, "complete job")
 	}
 
 	return c.JSON(fiber.Map{
@@ -57,3 +155,47 @@ func (h *Handler) CompleteJobHandler(c *fiber.Ctx) error {
 		"job_id":  jobID,
 	})
 }
+
+// FailJobHandler finishes a job the worker could not process, the reason is returned to a waiting submitter
+func (h *Handler) FailJobHandler(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+	if err := h.ValidateJobID(jobID); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+	}
+	reason, err := h.ParseFailure(c.Body())
+	if err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+	}
+
+	if err := h.FailJob(jobID, c.Get(LeaseTokenHeader), auth.FromContext(c.UserContext()).TenantID(), reason); err != nil {
+		return leaseError(err, "fail job")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Job failed",
+		"job_id":  jobID,
+	})
+}
+
+// HeartbeatHandler renews the lease of a processing job, with optional progress in a JSON body
+func (h *Handler) HeartbeatHandler(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+	if err := h.ValidateJobID(jobID); err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+	}
+	progress, err := h.ParseProgress(c.Body())
+	if err != nil {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+	}
+
+	job, err := h.ExtendLease(jobID, c.Get(LeaseTokenHeader), auth.FromContext(c.UserContext()).TenantID(), progress)
+	if err != nil {
+		return leaseError(err, "extend lease")
+	}
+
+	return c.JSON(fiber.Map{
+		"job_id":           job.ID,
+		"lease_expires_at": job.LeaseExpiresAt,
+		"progress":         job.Progress,
+	})
+}
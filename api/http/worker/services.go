@@ -1,35 +1,186 @@
 package worker
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"strconv"
+	"time"
 
+	"github.com/PAFFx/job-poll-queue/api/http/apierror"
+	"github.com/PAFFx/job-poll-queue/api/http/payload"
 	"github.com/PAFFx/job-poll-queue/queue"
 	"github.com/gofiber/fiber/v2"
 )
 
-// RequestJob pops a job from the queue for worker processing
-func (h *Handler) RequestJob() (*queue.Message, error) {
-	job, err := h.jobQueue.Pop()
-	if err != nil {
-		return nil, err
+// RequestJob pops a job from the queue for worker processing, waiting up to wait for one
+// Workers scoped to a tenant only receive that tenant's jobs, and only jobs whose requirements their capabilities meet
+// The job is leased to workerID when it is set. The wait ends early when ctx ends
+func (h *Handler) RequestJob(ctx context.Context, workerID string, tenant string, capabilities queue.Requirements, wait time.Duration) (*queue.Message, error) {
+	filter := queue.JobFilter{Tenant: tenant, Capabilities: capabilities}
+	if wait <= 0 {
+		return h.jobQueue.Pop(workerID, filter)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	job, err := h.jobQueue.PopWait(waitCtx, workerID, filter)
+	if job != nil && ctx.Err() != nil {
+		// Handed over as the worker went away, the job goes back to the queue
+		return nil, h.jobQueue.Release(job.ID, job.LeaseToken)
+	}
+	return job, err
+}
+
+// workerError converts a worker registration error into an API error
+func workerError(err error) error {
+	if errors.Is(err, queue.ErrWorkerTenant) {
+		return apierror.New(fiber.StatusForbidden, apierror.CodePermissionDenied, err.Error())
 	}
+	return apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+}
 
-	return job, nil
+// ParseWait parses how long a poll may wait for a job, in seconds or as a duration such as 500ms
+// The wait is capped at the handler's maximum, an empty value does not wait
+func (h *Handler) ParseWait(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(value)
+	if seconds, convErr := strconv.Atoi(value); convErr == nil {
+		wait, err = time.Duration(seconds)*time.Second, nil
+	}
+	if err != nil || wait < 0 {
+		return 0, errors.New("invalid wait, expected seconds or a duration such as 30s: " + value)
+	}
+	return min(wait, h.maxWait), nil
 }
 
-// CompleteJob marks a job as completed with the given payload
-func (h *Handler) CompleteJob(jobID string, payload string) error {
-	// Just mark the job as completed with the provided payload
+// CompleteJob marks a job leased with leaseToken as completed with the given payload
+// Results are checked against the queue's result schema, if any
+// Workers scoped to a tenant get ErrJobNotFound for jobs of other tenants
+func (h *Handler) CompleteJob(jobID string, leaseToken string, tenant string, result queue.JobResult) error {
+	if err := h.checkTenant(jobID, tenant); err != nil {
+		return err
+	}
+
 	// Let the payload itself contain any error information if needed
-	return h.jobQueue.GetStatusManager().SubmitResult(jobID, payload, nil)
+	return h.jobQueue.Complete(jobID, leaseToken, result)
+}
+
+// FailJob marks a job leased with leaseToken as failed with the given reason
+// Workers scoped to a tenant get ErrJobNotFound for jobs of other tenants
+func (h *Handler) FailJob(jobID string, leaseToken string, tenant string, reason string) error {
+	if err := h.checkTenant(jobID, tenant); err != nil {
+		return err
+	}
+	return h.jobQueue.Fail(jobID, leaseToken, reason)
+}
+
+// ExtendLease renews the lease identified by leaseToken and records the progress when it is not nil
+// Workers scoped to a tenant get ErrJobNotFound for jobs of other tenants
+func (h *Handler) ExtendLease(jobID string, leaseToken string, tenant string, progress *queue.Progress) (*queue.Message, error) {
+	if err := h.checkTenant(jobID, tenant); err != nil {
+		return nil, err
+	}
+	return h.jobQueue.ExtendLease(jobID, leaseToken, progress)
+}
+
+// checkTenant returns ErrJobNotFound when a worker scoped to a tenant reports on another tenant's job
+func (h *Handler) checkTenant(jobID string, tenant string) error {
+	if tenant == "" {
+		return nil
+	}
+	job, err := h.jobQueue.GetStatusManager().GetJobStatus(jobID)
+	if err != nil {
+		return err
+	}
+	if job.Tenant != tenant {
+		return queue.ErrJobNotFound
+	}
+	return nil
+}
+
+// ParseFailure reads the reason of a failed job from a JSON body such as {"error": "..."}
+func (h *Handler) ParseFailure(body []byte) (string, error) {
+	var failure struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &failure); err != nil {
+		return "", errors.New("invalid failure body: " + err.Error())
+	}
+	return failure.Error, queue.ValidateFailure(failure.Error)
+}
+
+// ParseProgress reads the optional progress of a heartbeat, nil when neither percent nor message is set
+func (h *Handler) ParseProgress(body []byte) (*queue.Progress, error) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+	var report struct {
+		Percent *int   `json:"percent"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, errors.New("invalid heartbeat body: " + err.Error())
+	}
+	if report.Percent == nil && report.Message == "" {
+		return nil, nil
+	}
+
+	progress := &queue.Progress{Message: report.Message}
+	if report.Percent != nil {
+		progress.Percent = *report.Percent
+	}
+	return progress, progress.Validate()
+}
+
+// ParseResultStatus parses the response status code requested by a worker
+// An empty value means the default status code
+func (h *Handler) ParseResultStatus(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil || code < 100 || code > 599 {
+		return 0, errors.New("invalid result status code: " + value)
+	}
+	return code, nil
 }
 
 // FormatJobResponse formats a job for response to workers
-func (h *Handler) FormatJobResponse(job *queue.Message) fiber.Map {
-	return fiber.Map{
-		"job_id":  job.ID,
-		"payload": job.Payload,
-		"headers": job.Headers,
+// Binary payloads (or all payloads when forceBase64 is set) are base64 encoded
+func (h *Handler) FormatJobResponse(job *queue.Message, forceBase64 bool) fiber.Map {
+	body, encoding := payload.Encode(job.Payload, forceBase64)
+	response := fiber.Map{
+		"job_id":           job.ID,
+		"payload":          body,
+		"payload_encoding": encoding,
+		"content_type":     job.ContentType,
+		"headers":          job.Headers,
+		"lease_token":      job.LeaseToken,
+	}
+	if job.Request != nil {
+		response["request"] = job.Request
+	}
+	return response
+}
+
+// leaseError converts the errors of calls made under a lease into API errors
+func leaseError(err error, action string) error {
+	switch {
+	case errors.Is(err, queue.ErrJobNotFound):
+		return apierror.New(fiber.StatusNotFound, apierror.CodeJobNotFound, err.Error())
+	case errors.Is(err, queue.ErrLeaseTokenRequired):
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeValidationFailed, "the "+LeaseTokenHeader+" header is required")
+	case errors.Is(err, queue.ErrLeaseExpired):
+		return apierror.New(fiber.StatusConflict, apierror.CodeLeaseExpired, err.Error())
+	case errors.Is(err, queue.ErrLeaseMismatch):
+		return apierror.New(fiber.StatusConflict, apierror.CodeLeaseMismatch, err.Error())
+	case errors.Is(err, queue.ErrInvalidJobState):
+		return apierror.New(fiber.StatusConflict, apierror.CodeInvalidJobState, "job is already finished")
+	default:
+		return apierror.New(fiber.StatusInternalServerError, apierror.CodeInternal, "Failed to "+action+": "+err.Error())
 	}
 }
 
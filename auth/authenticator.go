@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"crypto/x509"
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrMissingCredentials is returned when a protected request carries no API key, token or client certificate
+	ErrMissingCredentials = errors.New("missing API key, bearer token or client certificate")
+	// ErrInvalidCredentials is returned for unknown or revoked API keys
+	ErrInvalidCredentials = errors.New("invalid API key")
+	// ErrPermissionDenied is returned when the caller lacks the role or queue access
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// Config controls authentication
+type Config struct {
+	Enabled  bool
+	AdminKey string       // Bootstrap key with the admin role, it is not stored
+	JWT      *JWTVerifier // Accepts bearer JWTs when set
+	// Accepts verified TLS client certificates when set
+	Certificates *CertificateMapper
+}
+
+// Credentials are what a request presented to authenticate
+type Credentials struct {
+	Token       string            // API key or bearer token
+	Certificate *x509.Certificate // Verified TLS client certificate
+}
+
+// Authenticator checks the credentials of HTTP and gRPC requests
+type Authenticator struct {
+	config Config
+	keys   *Keyring
+}
+
+// NewAuthenticator creates an authenticator backed by the keyring
+func NewAuthenticator(config Config, keys *Keyring) *Authenticator {
+	return &Authenticator{config: config, keys: keys}
+}
+
+// Enabled reports whether requests must be authenticated
+func (a *Authenticator) Enabled() bool {
+	return a.config.Enabled
+}
+
+// Keys returns the keyring
+func (a *Authenticator) Keys() *Keyring {
+	return a.keys
+}
+
+// Authenticate resolves the principal of the credentials
+// A token takes precedence over the client certificate
+func (a *Authenticator) Authenticate(credentials Credentials) (*Principal, error) {
+	if credentials.Token != "" {
+		return a.authenticateToken(credentials.Token)
+	}
+	if credentials.Certificate != nil && a.config.Certificates != nil {
+		return a.config.Certificates.Principal(credentials.Certificate)
+	}
+	return nil, ErrMissingCredentials
+}
+
+// Identify resolves the principal of the credentials without enforcing anything
+// It is used when authentication is disabled, nil means the caller is anonymous
+func (a *Authenticator) Identify(credentials Credentials) *Principal {
+	principal, err := a.Authenticate(credentials)
+	if err != nil {
+		return nil
+	}
+	return principal
+}
+
+func (a *Authenticator) authenticateToken(token string) (*Principal, error) {
+	if a.config.AdminKey != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.config.AdminKey)) == 1 {
+		return &Principal{ID: "bootstrap", Name: "bootstrap admin key", Method: MethodAPIKey, Roles: []Role{RoleAdmin}}, nil
+	}
+	if a.config.JWT != nil && LooksLikeJWT(token) {
+		return a.config.JWT.Verify(token)
+	}
+
+	key, ok := a.keys.lookup(token)
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return &Principal{ID: key.ID, Name: key.Name, Method: MethodAPIKey, Roles: key.Roles, Queues: key.Queues, Tenant: key.Tenant}, nil
+}
+
+// Authorize authenticates the credentials and checks they grant the role on the queue
+func (a *Authenticator) Authorize(credentials Credentials, role Role, queueName string) (*Principal, error) {
+	principal, err := a.Authenticate(credentials)
+	if err != nil {
+		return nil, err
+	}
+	if !principal.Allows(role, queueName) {
+		return principal, ErrPermissionDenied
+	}
+	return principal, nil
+}
+
+// Credential extracts the API key or token from an Authorization header ("Bearer <key>")
+// or, when there is none, from an X-API-Key header
+func Credential(authorization, apiKey string) string {
+	if scheme, token, ok := strings.Cut(strings.TrimSpace(authorization), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return strings.TrimSpace(apiKey)
+}
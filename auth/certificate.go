@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ErrUnmappedCertificate is returned for verified client certificates missing from the client map
+var ErrUnmappedCertificate = errors.New("client certificate is not mapped to a worker")
+
+// MethodCertificate authenticates with a verified TLS client certificate
+const MethodCertificate = "mtls"
+
+// IdentitySource selects the certificate field used as the client identity
+type IdentitySource string
+
+const (
+	IdentityAuto  IdentitySource = "auto" // First URI SAN (such as a SPIFFE ID), then first DNS SAN, then common name
+	IdentityURI   IdentitySource = "uri"
+	IdentityDNS   IdentitySource = "dns"
+	IdentityEmail IdentitySource = "email"
+	IdentityCN    IdentitySource = "cn"
+)
+
+// ClientMapping maps a certificate identity to a worker
+type ClientMapping struct {
+	Identity string   `json:"identity"`
+	WorkerID string   `json:"worker_id,omitempty"` // Defaults to the identity
+	Roles    []Role   `json:"roles,omitempty"`     // Defaults to worker
+	Queues   []string `json:"queues,omitempty"`    // Every queue when omitted
+	Tenant   string   `json:"tenant,omitempty"`
+}
+
+// CertificateMapper turns verified client certificates into principals
+// Without a map file every verified certificate is a worker named after its identity
+type CertificateMapper struct {
+	source  IdentitySource
+	mapFile string
+	clients map[string]ClientMapping // By identity, nil without a map file
+	mutex   sync.RWMutex
+}
+
+// NewCertificateMapper creates a mapper and loads the optional map file
+func NewCertificateMapper(source IdentitySource, mapFile string) (*CertificateMapper, error) {
+	if source == "" {
+		source = IdentityAuto
+	}
+	switch source {
+	case IdentityAuto, IdentityURI, IdentityDNS, IdentityEmail, IdentityCN:
+	default:
+		return nil, fmt.Errorf("unknown certificate identity source %q", source)
+	}
+
+	m := &CertificateMapper{source: source, mapFile: mapFile}
+	if err := m.Load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MapFile returns the path of the client map, empty when there is none
+func (m *CertificateMapper) MapFile() string {
+	return m.mapFile
+}
+
+// Load reads the client map again, the previous map is kept when it fails
+func (m *CertificateMapper) Load() error {
+	if m.mapFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(m.mapFile)
+	if err != nil {
+		return fmt.Errorf("failed to read client map: %w", err)
+	}
+	var file struct {
+		Clients []ClientMapping `json:"clients"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse client map: %w", err)
+	}
+
+	clients := make(map[string]ClientMapping, len(file.Clients))
+	for _, client := range file.Clients {
+		if client.Identity == "" {
+			return errors.New("client map entries need an identity")
+		}
+		for _, role := range client.Roles {
+			if !role.Valid() {
+				return fmt.Errorf("client %q has unknown role %q", client.Identity, role)
+			}
+		}
+		// An empty list means every queue, as when it is omitted
+		if len(client.Queues) == 0 {
+			client.Queues = nil
+		}
+		clients[client.Identity] = client
+	}
+
+	m.mutex.Lock()
+	m.clients = clients
+	m.mutex.Unlock()
+	return nil
+}
+
+// Principal returns the principal of a verified client certificate
+func (m *CertificateMapper) Principal(cert *x509.Certificate) (*Principal, error) {
+	identity := m.identity(cert)
+	if identity == "" {
+		return nil, ErrUnmappedCertificate
+	}
+
+	m.mutex.RLock()
+	clients := m.clients
+	m.mutex.RUnlock()
+
+	mapping := ClientMapping{Identity: identity}
+	if clients != nil {
+		var ok bool
+		if mapping, ok = clients[identity]; !ok {
+			return nil, ErrUnmappedCertificate
+		}
+	}
+
+	principal := &Principal{
+		ID:     mapping.WorkerID,
+		Name:   identity,
+		Method: MethodCertificate,
+		Roles:  mapping.Roles,
+		Queues: mapping.Queues,
+		Tenant: mapping.Tenant,
+	}
+	if principal.ID == "" {
+		principal.ID = identity
+	}
+	if len(principal.Roles) == 0 {
+		principal.Roles = []Role{RoleWorker}
+	}
+	return principal, nil
+}
+
+// identity extracts the configured identity field of a certificate
+func (m *CertificateMapper) identity(cert *x509.Certificate) string {
+	uri := ""
+	if len(cert.URIs) > 0 {
+		uri = cert.URIs[0].String()
+	}
+	dns := ""
+	if len(cert.DNSNames) > 0 {
+		dns = cert.DNSNames[0]
+	}
+
+	switch m.source {
+	case IdentityURI:
+		return uri
+	case IdentityDNS:
+		return dns
+	case IdentityEmail:
+		if len(cert.EmailAddresses) > 0 {
+			return cert.EmailAddresses[0]
+		}
+		return ""
+	case IdentityCN:
+		return cert.Subject.CommonName
+	}
+	for _, candidate := range []string{uri, dns, cert.Subject.CommonName} {
+		if candidate != "" {
+			return candidate
+		}
+	}
+	return ""
+}
@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minForcedRefresh limits reloads triggered by tokens signed with unknown key IDs
+const minForcedRefresh = 30 * time.Second
+
+// maxJWKSSize bounds the size of a fetched key set
+const maxJWKSSize = 1 << 20
+
+// JWKS is a JSON Web Key Set loaded from a file or URL and refreshed periodically
+type JWKS struct {
+	source      string // File path, or http(s) URL
+	refresh     time.Duration
+	client      *http.Client
+	keys        map[string]jwk // By key ID
+	loadedAt    time.Time
+	mutex       sync.RWMutex
+	reloadMutex sync.Mutex
+}
+
+// jwk is a parsed public key with the algorithm it is restricted to, if any
+type jwk struct {
+	key crypto.PublicKey
+	alg string
+}
+
+// rawJWK holds the members of a JSON Web Key used for public signature keys
+type rawJWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewJWKS loads a key set from a file path or an http(s) URL
+func NewJWKS(source string, refresh time.Duration) (*JWKS, error) {
+	j := &JWKS{
+		source:  source,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+	if err := j.Load(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// DiscoverJWKS finds the key set URL of an OpenID Connect issuer and loads it
+func DiscoverJWKS(issuer string, refresh time.Duration) (*JWKS, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	data, err := fetch(client, strings.TrimRight(issuer, "/")+"/.well-known/openid-configuration")
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OpenID configuration: %w", err)
+	}
+
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.Unmarshal(data, &discovery); err != nil || discovery.JWKSURI == "" {
+		return nil, errors.New("OpenID configuration has no jwks_uri")
+	}
+	return NewJWKS(discovery.JWKSURI, refresh)
+}
+
+// Load reads the key set again, the previous keys are kept when it fails
+func (j *JWKS) Load() error {
+	var data []byte
+	var err error
+	if strings.HasPrefix(j.source, "http://") || strings.HasPrefix(j.source, "https://") {
+		data, err = fetch(j.client, j.source)
+	} else {
+		data, err = os.ReadFile(j.source)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load JWKS from %s: %w", j.source, err)
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse JWKS from %s: %w", j.source, err)
+	}
+
+	j.mutex.Lock()
+	j.keys = keys
+	j.loadedAt = time.Now()
+	j.mutex.Unlock()
+	return nil
+}
+
+// Run reloads the key set periodically, it never returns
+func (j *JWKS) Run() {
+	if j.refresh <= 0 {
+		return
+	}
+	ticker := time.NewTicker(j.refresh)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := j.Load(); err != nil {
+			log.Printf("Failed to refresh JWKS: %v", err)
+		}
+	}
+}
+
+// key returns the public key with the given ID
+// Unknown IDs trigger a reload so rotated keys are picked up before the next refresh
+func (j *JWKS) key(kid string) (jwk, bool) {
+	if key, ok := j.lookup(kid); ok {
+		return key, true
+	}
+
+	j.reloadMutex.Lock()
+	defer j.reloadMutex.Unlock()
+	if key, ok := j.lookup(kid); ok {
+		return key, true
+	}
+	j.mutex.RLock()
+	stale := time.Since(j.loadedAt) >= minForcedRefresh
+	j.mutex.RUnlock()
+	if !stale {
+		return jwk{}, false
+	}
+	if err := j.Load(); err != nil {
+		log.Printf("Failed to refresh JWKS: %v", err)
+		return jwk{}, false
+	}
+	return j.lookup(kid)
+}
+
+func (j *JWKS) lookup(kid string) (jwk, bool) {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+
+	if key, ok := j.keys[kid]; ok {
+		return key, true
+	}
+	// Tokens without a key ID can use a set holding a single key
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+	return jwk{}, false
+}
+
+func fetch(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+}
+
+// parseJWKS reads the signature keys of a key set, keys of other types or uses are skipped
+func parseJWKS(data []byte) (map[string]jwk, error) {
+	var set struct {
+		Keys []rawJWK `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]jwk)
+	for _, raw := range set.Keys {
+		if raw.Use != "" && raw.Use != "sig" {
+			continue
+		}
+		key, err := raw.publicKey()
+		if err != nil {
+			log.Printf("Skipping JWK %q: %v", raw.Kid, err)
+			continue
+		}
+		keys[raw.Kid] = jwk{key: key, alg: raw.Alg}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no usable signature keys")
+	}
+	return keys, nil
+}
+
+func (r rawJWK) publicKey() (crypto.PublicKey, error) {
+	switch r.Kty {
+	case "RSA":
+		n, err := decodeBigInt(r.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(r.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		if n.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must have at least 2048 bits")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		var check ecdh.Curve
+		switch r.Crv {
+		case "P-256":
+			curve, check = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, check = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, check = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", r.Crv)
+		}
+		x, err := decodeBigInt(r.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(r.Y)
+		if err != nil {
+			return nil, err
+		}
+		// Parsing the uncompressed encoding checks the point is on the curve
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x.Bytes()) > size || len(y.Bytes()) > size {
+			return nil, errors.New("invalid EC point")
+		}
+		point := make([]byte, 1+2*size)
+		point[0] = 4
+		x.FillBytes(point[1 : 1+size])
+		y.FillBytes(point[1+size:])
+		if _, err := check.NewPublicKey(point); err != nil {
+			return nil, errors.New("invalid EC point")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if r.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", r.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(r.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", r.Kty)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned for bearer tokens that fail verification
+var ErrInvalidToken = errors.New("invalid bearer token")
+
+// maxNumericDate bounds time claims, in seconds, to keep conversions exact
+const maxNumericDate = 1 << 40
+
+// JWTConfig controls how bearer tokens are verified and mapped to principals
+type JWTConfig struct {
+	Issuer      string          // Required iss claim, not checked when empty
+	Audience    string          // Required entry of the aud claim, not checked when empty
+	RolesClaim  string          // Claim holding role names, dots select nested claims
+	TenantClaim string          // Claim holding the tenant
+	QueuesClaim string          // Claim holding the allowed queues, every queue when empty
+	RoleMap     map[string]Role // Claim values granting a role, role names map to themselves
+	Leeway      time.Duration   // Allowed clock skew for exp, nbf and iat
+}
+
+// JWTVerifier verifies signed JWTs against a key set
+type JWTVerifier struct {
+	config JWTConfig
+	keys   *JWKS
+}
+
+// NewJWTVerifier creates a verifier using the key set
+func NewJWTVerifier(config JWTConfig, keys *JWKS) *JWTVerifier {
+	return &JWTVerifier{config: config, keys: keys}
+}
+
+// jwtHeader is the JOSE header of a token
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// ParseRoleMap reads "value=role" entries mapping claim values to roles
+func ParseRoleMap(entries []string) (map[string]Role, error) {
+	roles := make(map[string]Role)
+	for _, entry := range entries {
+		value, role, ok := strings.Cut(entry, "=")
+		value = strings.TrimSpace(value)
+		if !ok || value == "" || !Role(strings.TrimSpace(role)).Valid() {
+			return nil, fmt.Errorf("invalid role mapping %q, expected value=submit|worker|admin", entry)
+		}
+		roles[value] = Role(strings.TrimSpace(role))
+	}
+	return roles, nil
+}
+
+// LooksLikeJWT reports whether a credential has the three parts of a compact JWS
+func LooksLikeJWT(credential string) bool {
+	return strings.Count(credential, ".") == 2
+}
+
+// Verify checks the signature and registered claims of a token and returns its principal
+func (v *JWTVerifier) Verify(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalidToken("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, invalidToken("malformed header")
+	}
+	key, ok := v.keys.key(header.Kid)
+	if !ok {
+		return nil, invalidToken("unknown signing key")
+	}
+	if key.alg != "" && key.alg != header.Alg {
+		return nil, invalidToken("algorithm does not match the signing key")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalidToken("malformed signature")
+	}
+	if err := verifySignature(header.Alg, key.key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, invalidToken(err.Error())
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, invalidToken("malformed claims")
+	}
+	if err := v.checkClaims(claims, time.Now()); err != nil {
+		return nil, invalidToken(err.Error())
+	}
+	return v.principal(claims), nil
+}
+
+// checkClaims validates the time, issuer and audience claims
+func (v *JWTVerifier) checkClaims(claims map[string]interface{}, now time.Time) error {
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return errors.New("token has no expiry")
+	}
+	if now.After(exp.Add(v.config.Leeway)) {
+		return errors.New("token expired")
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(v.config.Leeway).Before(nbf) {
+		return errors.New("token not valid yet")
+	}
+	if iat, ok := numericDate(claims["iat"]); ok && now.Add(v.config.Leeway).Before(iat) {
+		return errors.New("token issued in the future")
+	}
+
+	if v.config.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.config.Issuer {
+			return errors.New("unexpected issuer")
+		}
+	}
+	if v.config.Audience != "" && !containsString(stringList(claims["aud"]), v.config.Audience) {
+		return errors.New("unexpected audience")
+	}
+	return nil
+}
+
+// principal maps the claims of a verified token
+func (v *JWTVerifier) principal(claims map[string]interface{}) *Principal {
+	subject, _ := claims["sub"].(string)
+	principal := &Principal{ID: subject, Name: subject, Method: MethodJWT}
+	for _, claim := range []string{"name", "preferred_username", "email"} {
+		if name, ok := claims[claim].(string); ok && name != "" {
+			principal.Name = name
+			break
+		}
+	}
+
+	for _, value := range stringList(claimValue(claims, v.config.RolesClaim)) {
+		role, ok := v.config.RoleMap[value]
+		if !ok {
+			role = Role(value)
+		}
+		if role.Valid() && !principal.hasRole(role) {
+			principal.Roles = append(principal.Roles, role)
+		}
+	}
+	if tenant, ok := claimValue(claims, v.config.TenantClaim).(string); ok {
+		principal.Tenant = tenant
+	}
+	if v.config.QueuesClaim != "" {
+		principal.Queues = stringList(claimValue(claims, v.config.QueuesClaim))
+		if principal.Queues == nil {
+			// A configured but missing claim grants no queue rather than every queue
+			principal.Queues = []string{}
+		}
+	}
+	return principal
+}
+
+// verifySignature checks a JWS signature, only asymmetric algorithms are accepted
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	case "EdDSA":
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(edKey, signed, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	hasher := hash.New()
+	hasher.Write(signed)
+	digest := hasher.Sum(nil)
+
+	switch alg[0] {
+	case 'R':
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(rsaKey, hash, digest, signature) != nil {
+			return errors.New("invalid signature")
+		}
+	case 'P':
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPSS(rsaKey, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) != nil {
+			return errors.New("invalid signature")
+		}
+	case 'E':
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		// JWS encodes ECDSA signatures as the fixed size concatenation of r and s
+		if !ok || len(signature) == 0 || len(signature)%2 != 0 {
+			return errors.New("invalid signature")
+		}
+		half := len(signature) / 2
+		if half != (ecKey.Curve.Params().BitSize+7)/8 {
+			return errors.New("invalid signature")
+		}
+		r := new(big.Int).SetBytes(signature[:half])
+		s := new(big.Int).SetBytes(signature[half:])
+		if !ecdsa.Verify(ecKey, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+	}
+	return nil
+}
+
+func invalidToken(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidToken, reason)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// claimValue returns a claim by name, dots select nested claims as in "realm_access.roles"
+func claimValue(claims map[string]interface{}, name string) interface{} {
+	if name == "" {
+		return nil
+	}
+	if value, ok := claims[name]; ok {
+		return value
+	}
+	var current interface{} = claims
+	for _, part := range strings.Split(name, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = object[part]
+	}
+	return current
+}
+
+// stringList reads a claim holding a string array, or a space separated string as in "scope"
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func numericDate(value interface{}) (time.Time, bool) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := number.Float64()
+	if err != nil || math.Abs(seconds) > maxNumericDate {
+		return time.Time{}, false
+	}
+	whole := math.Floor(seconds)
+	return time.Unix(int64(whole), int64((seconds-whole)*float64(time.Second))), true
+}
+
+func containsString(values []string, want string) bool {
+	for _, value := range values {
+		if value == want {
+			return true
+		}
+	}
+	return false
+}
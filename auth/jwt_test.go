@@ -0,0 +1,296 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testKeys are the private keys behind the key set of newTestJWKS
+type testKeys struct {
+	ed ed25519.PrivateKey // Key ID "ed", restricted to EdDSA
+	ec *ecdsa.PrivateKey  // Key ID "ec", for ES256
+}
+
+// newTestJWKS generates signing keys and loads their public keys from a key set file
+func newTestJWKS(t *testing.T) (*JWKS, testKeys) {
+	t.Helper()
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate Ed25519 key: %v", err)
+	}
+	ecPrivate, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate EC key: %v", err)
+	}
+
+	encode := base64.RawURLEncoding.EncodeToString
+	set := map[string][]rawJWK{"keys": {
+		{Kty: "OKP", Kid: "ed", Alg: "EdDSA", Crv: "Ed25519", X: encode(edPublic)},
+		{Kty: "EC", Kid: "ec", Use: "sig", Crv: "P-256", X: encode(ecPrivate.X.FillBytes(make([]byte, 32))), Y: encode(ecPrivate.Y.FillBytes(make([]byte, 32)))},
+		{Kty: "EC", Kid: "enc", Use: "enc", Crv: "P-256"},
+	}}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("marshal key set: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write key set: %v", err)
+	}
+
+	keys, err := NewJWKS(path, 0)
+	if err != nil {
+		t.Fatalf("NewJWKS: %v", err)
+	}
+	return keys, testKeys{ed: edPrivate, ec: ecPrivate}
+}
+
+// signToken builds a compact JWS over the claims
+func signToken(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(jwtHeader{Alg: alg, Kid: kid, Typ: "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal claims: %v", err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(signed))
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// validClaims returns claims accepted by a verifier for issuer "https://issuer" and audience "queue"
+func validClaims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"sub": "user-1",
+		"iss": "https://issuer",
+		"aud": "queue",
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+}
+
+func TestVerifyClaims(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		config  JWTConfig
+		change  func(claims map[string]interface{})
+		wantErr string
+	}{
+		{"valid", JWTConfig{}, func(map[string]interface{}) {}, ""},
+		{"missing expiry", JWTConfig{}, func(c map[string]interface{}) { delete(c, "exp") }, "token has no expiry"},
+		{"expiry not a number", JWTConfig{}, func(c map[string]interface{}) { c["exp"] = "tomorrow" }, "token has no expiry"},
+		{"expired", JWTConfig{}, func(c map[string]interface{}) { c["exp"] = now.Add(-time.Minute).Unix() }, "token expired"},
+		{"expired within leeway", JWTConfig{Leeway: 2 * time.Minute}, func(c map[string]interface{}) { c["exp"] = now.Add(-time.Minute).Unix() }, ""},
+		{"fractional expiry", JWTConfig{}, func(c map[string]interface{}) { c["exp"] = float64(now.Add(time.Hour).Unix()) + 0.5 }, ""},
+		{"not valid yet", JWTConfig{}, func(c map[string]interface{}) { c["nbf"] = now.Add(time.Minute).Unix() }, "token not valid yet"},
+		{"not valid yet within leeway", JWTConfig{Leeway: 2 * time.Minute}, func(c map[string]interface{}) { c["nbf"] = now.Add(time.Minute).Unix() }, ""},
+		{"issued in the future", JWTConfig{}, func(c map[string]interface{}) { c["iat"] = now.Add(time.Minute).Unix() }, "token issued in the future"},
+		{"expected issuer", JWTConfig{Issuer: "https://issuer"}, func(map[string]interface{}) {}, ""},
+		{"other issuer", JWTConfig{Issuer: "https://other"}, func(map[string]interface{}) {}, "unexpected issuer"},
+		{"missing issuer", JWTConfig{Issuer: "https://issuer"}, func(c map[string]interface{}) { delete(c, "iss") }, "unexpected issuer"},
+		{"expected audience", JWTConfig{Audience: "queue"}, func(map[string]interface{}) {}, ""},
+		{"audience in a list", JWTConfig{Audience: "queue"}, func(c map[string]interface{}) { c["aud"] = []string{"other", "queue"} }, ""},
+		{"other audience", JWTConfig{Audience: "queue"}, func(c map[string]interface{}) { c["aud"] = []string{"other"} }, "unexpected audience"},
+		{"missing audience", JWTConfig{Audience: "queue"}, func(c map[string]interface{}) { delete(c, "aud") }, "unexpected audience"},
+	}
+	keys, signers := newTestJWKS(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.change(claims)
+			token := signToken(t, "EdDSA", "ed", signers.ed, claims)
+
+			_, err := NewJWTVerifier(tt.config, keys).Verify(token)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidToken) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Verify = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	keys, signers := newTestJWKS(t)
+	valid := signToken(t, "EdDSA", "ed", signers.ed, validClaims())
+	parts := strings.Split(valid, ".")
+	otherPayload := signToken(t, "EdDSA", "ed", signers.ed, map[string]interface{}{"sub": "admin"})
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"EdDSA", valid, ""},
+		{"ES256", signToken(t, "ES256", "ec", signers.ec, validClaims()), ""},
+		{"malformed", "not-a-token", "malformed token"},
+		{"unknown key", signToken(t, "EdDSA", "missing", signers.ed, validClaims()), "unknown signing key"},
+		{"key for encryption", signToken(t, "ES256", "enc", signers.ec, validClaims()), "unknown signing key"},
+		{"algorithm of another key", signToken(t, "ES256", "ed", signers.ec, validClaims()), "algorithm does not match"},
+		{"signed by another key", signToken(t, "ES256", "ec", mustGenerateEC(t), validClaims()), "invalid signature"},
+		{"payload swapped", parts[0] + "." + strings.Split(otherPayload, ".")[1] + "." + parts[2], "invalid signature"},
+		{"unsigned", parts[0] + "." + parts[1] + ".", "invalid signature"},
+		{"symmetric algorithm", strings.Replace(valid, parts[0], encodeHeader(t, jwtHeader{Alg: "HS256", Kid: "ec"}), 1), "unsupported algorithm"},
+		{"none algorithm", encodeHeader(t, jwtHeader{Alg: "none", Kid: "ec"}) + "." + parts[1] + ".", "unsupported algorithm"},
+	}
+	verifier := NewJWTVerifier(JWTConfig{}, keys)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verifier.Verify(tt.token)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidToken) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Verify = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJWTPrincipal(t *testing.T) {
+	tests := []struct {
+		name   string
+		config JWTConfig
+		claims map[string]interface{}
+		want   Principal
+	}{
+		{
+			name:   "subject only",
+			claims: map[string]interface{}{"sub": "user-1"},
+			want:   Principal{ID: "user-1", Name: "user-1", Method: MethodJWT},
+		},
+		{
+			name:   "display name",
+			claims: map[string]interface{}{"sub": "user-1", "preferred_username": "ada", "email": "ada@example.com"},
+			want:   Principal{ID: "user-1", Name: "ada", Method: MethodJWT},
+		},
+		{
+			name:   "role names from a list",
+			config: JWTConfig{RolesClaim: "roles"},
+			claims: map[string]interface{}{"sub": "u", "roles": []string{"worker", "owner", "worker"}},
+			want:   Principal{ID: "u", Name: "u", Method: MethodJWT, Roles: []Role{RoleWorker}},
+		},
+		{
+			name:   "mapped roles from a nested claim",
+			config: JWTConfig{RolesClaim: "realm_access.roles", RoleMap: map[string]Role{"queue-admins": RoleAdmin}},
+			claims: map[string]interface{}{"sub": "u", "realm_access": map[string]interface{}{"roles": []string{"queue-admins", "submit"}}},
+			want:   Principal{ID: "u", Name: "u", Method: MethodJWT, Roles: []Role{RoleAdmin, RoleSubmit}},
+		},
+		{
+			name:   "roles from a scope string",
+			config: JWTConfig{RolesClaim: "scope", RoleMap: map[string]Role{"jobs:write": RoleSubmit}},
+			claims: map[string]interface{}{"sub": "u", "scope": "openid jobs:write"},
+			want:   Principal{ID: "u", Name: "u", Method: MethodJWT, Roles: []Role{RoleSubmit}},
+		},
+		{
+			name:   "tenant and queues",
+			config: JWTConfig{TenantClaim: "org", QueuesClaim: "queues"},
+			claims: map[string]interface{}{"sub": "u", "org": "acme", "queues": []string{"images"}},
+			want:   Principal{ID: "u", Name: "u", Method: MethodJWT, Tenant: "acme", Queues: []string{"images"}},
+		},
+		{
+			name:   "missing queues claim grants no queue",
+			config: JWTConfig{QueuesClaim: "queues"},
+			claims: map[string]interface{}{"sub": "u"},
+			want:   Principal{ID: "u", Name: "u", Method: MethodJWT, Queues: []string{}},
+		},
+	}
+	keys, signers := newTestJWKS(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			delete(claims, "aud")
+			for name, value := range tt.claims {
+				claims[name] = value
+			}
+
+			got, err := NewJWTVerifier(tt.config, keys).Verify(signToken(t, "EdDSA", "ed", signers.ed, claims))
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if got.ID != tt.want.ID || got.Name != tt.want.Name || got.Method != tt.want.Method || got.Tenant != tt.want.Tenant ||
+				!slices.Equal(got.Roles, tt.want.Roles) || !slices.Equal(got.Queues, tt.want.Queues) || (got.Queues == nil) != (tt.want.Queues == nil) {
+				t.Errorf("principal = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRoleMap(t *testing.T) {
+	tests := []struct {
+		entries []string
+		want    map[string]Role
+		wantErr bool
+	}{
+		{nil, map[string]Role{}, false},
+		{[]string{"queue-admins=admin", " jobs:write = submit "}, map[string]Role{"queue-admins": RoleAdmin, "jobs:write": RoleSubmit}, false},
+		{[]string{"queue-admins"}, nil, true},
+		{[]string{"=admin"}, nil, true},
+		{[]string{"queue-admins=owner"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRoleMap(tt.entries)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRoleMap(%q) error = %v, want error %v", tt.entries, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !maps.Equal(got, tt.want) {
+			t.Errorf("ParseRoleMap(%q) = %v, want %v", tt.entries, got, tt.want)
+		}
+	}
+}
+
+func mustGenerateEC(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate EC key: %v", err)
+	}
+	return key
+}
+
+func encodeHeader(t *testing.T, header jwtHeader) string {
+	t.Helper()
+	data, err := json.Marshal(header)
+	if err != nil {
+		t.Fatalf("marshal header: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PAFFx/job-poll-queue/queue"
+	"github.com/google/uuid"
+)
+
+// keysStateKey names the persisted API keys in storage
+const keysStateKey = "api_keys"
+
+// keyPrefix starts every generated API key so they are easy to recognise in logs and secret scanners
+const keyPrefix = "jpq_"
+
+// ErrKeyNotFound is returned for unknown key IDs
+var ErrKeyNotFound = errors.New("API key not found")
+
+// Key is a stored API key, only the SHA-256 hash of the secret is kept
+type Key struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"` // First characters of the secret, to tell keys apart
+	Hash      string    `json:"hash"`
+	Roles     []Role    `json:"roles"`
+	Queues    []string  `json:"queues,omitempty"` // Empty means every queue
+	Tenant    string    `json:"tenant,omitempty"` // Scopes the key to one tenant's jobs
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// KeySpec describes a key to create
+type KeySpec struct {
+	Name   string   `json:"name"`
+	Roles  []Role   `json:"roles"`
+	Queues []string `json:"queues,omitempty"`
+	Tenant string   `json:"tenant,omitempty"`
+}
+
+// Keyring holds the API keys and persists them through the queue storage
+type Keyring struct {
+	storage *queue.Storage
+	keys    map[string]*Key // By ID
+	byHash  map[string]*Key
+	mutex   sync.RWMutex
+}
+
+// NewKeyring creates a keyring and loads persisted keys from storage
+func NewKeyring(storage *queue.Storage) (*Keyring, error) {
+	k := &Keyring{
+		storage: storage,
+		keys:    make(map[string]*Key),
+		byHash:  make(map[string]*Key),
+	}
+
+	var keys []*Key
+	if _, err := storage.LoadState(keysStateKey, &keys); err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		k.keys[key.ID] = key
+		k.byHash[key.Hash] = key
+	}
+
+	return k, nil
+}
+
+// Create generates a new key and returns it with its secret, the secret cannot be retrieved later
+func (k *Keyring) Create(spec KeySpec) (Key, string, error) {
+	if err := spec.Validate(); err != nil {
+		return Key{}, "", err
+	}
+
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return Key{}, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	secret := keyPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	// An empty list means every queue, as when it is omitted
+	if len(spec.Queues) == 0 {
+		spec.Queues = nil
+	}
+
+	key := &Key{
+		ID:        uuid.New().String(),
+		Name:      spec.Name,
+		Prefix:    secret[:len(keyPrefix)+6],
+		Hash:      hashSecret(secret),
+		Roles:     spec.Roles,
+		Queues:    spec.Queues,
+		Tenant:    spec.Tenant,
+		CreatedAt: time.Now(),
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.keys[key.ID] = key
+	k.byHash[key.Hash] = key
+	if err := k.saveLocked(); err != nil {
+		delete(k.keys, key.ID)
+		delete(k.byHash, key.Hash)
+		return Key{}, "", err
+	}
+
+	return *key, secret, nil
+}
+
+// List returns every key, oldest first
+func (k *Keyring) List() []Key {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	keys := make([]Key, 0, len(k.keys))
+	for _, key := range k.keys {
+		keys = append(keys, *key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys
+}
+
+// Revoke deletes a key, requests using it are rejected from then on
+func (k *Keyring) Revoke(id string) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	key, ok := k.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	delete(k.keys, id)
+	delete(k.byHash, key.Hash)
+	return k.saveLocked()
+}
+
+// Len returns the number of stored keys
+func (k *Keyring) Len() int {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return len(k.keys)
+}
+
+// lookup finds the key with the given secret
+func (k *Keyring) lookup(secret string) (Key, bool) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	key, ok := k.byHash[hashSecret(secret)]
+	if !ok {
+		return Key{}, false
+	}
+	return *key, true
+}
+
+func (k *Keyring) saveLocked() error {
+	keys := make([]*Key, 0, len(k.keys))
+	for _, key := range k.keys {
+		keys = append(keys, key)
+	}
+	return k.storage.SaveState(keysStateKey, keys)
+}
+
+// Validate checks the key has a name and known roles
+func (s KeySpec) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return errors.New("name is required")
+	}
+	if len(s.Roles) == 0 {
+		return errors.New("at least one role is required")
+	}
+	for _, role := range s.Roles {
+		if !role.Valid() {
+			return fmt.Errorf("unknown role %q", role)
+		}
+	}
+	for _, name := range s.Queues {
+		if name == "" {
+			return errors.New("queue names must not be empty")
+		}
+	}
+	return nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
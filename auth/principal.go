@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+// Role grants access to one group of endpoints
+type Role string
+
+const (
+	RoleSubmit Role = "submit" // Submit jobs and read their status
+	RoleWorker Role = "worker" // Take jobs and report results
+	RoleAdmin  Role = "admin"  // Manage the queue, includes every other role
+)
+
+// Valid reports whether the role is known
+func (r Role) Valid() bool {
+	switch r {
+	case RoleSubmit, RoleWorker, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// Authentication methods
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	ID     string   `json:"id"` // Key ID, or the subject of a token
+	Name   string   `json:"name"`
+	Method string   `json:"method"`
+	Roles  []Role   `json:"roles"`
+	Queues []string `json:"queues,omitempty"` // Nil means every queue
+	Tenant string   `json:"tenant,omitempty"`
+}
+
+// Submitter returns the principal as recorded on the jobs it submits, nil for anonymous requests
+func (p *Principal) Submitter() *queue.Submitter {
+	if p == nil {
+		return nil
+	}
+	return &queue.Submitter{ID: p.ID, Name: p.Name, Method: p.Method, Tenant: p.Tenant}
+}
+
+// TenantID returns the tenant the principal is scoped to, empty for global and anonymous callers
+func (p *Principal) TenantID() string {
+	if p == nil {
+		return ""
+	}
+	return p.Tenant
+}
+
+// Allows reports whether the principal may act with the role on the queue
+func (p *Principal) Allows(role Role, queueName string) bool {
+	if !p.hasRole(role) && !p.hasRole(RoleAdmin) {
+		return false
+	}
+	if p.Queues == nil {
+		return true
+	}
+	for _, name := range p.Queues {
+		if name == queueName {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) hasRole(role Role) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// NewContext returns a context carrying the principal
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal of the context, nil when the request was not authenticated
+func FromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
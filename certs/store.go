@@ -0,0 +1,154 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// ClientAuth controls how client certificates are handled
+type ClientAuth string
+
+const (
+	ClientAuthNone    ClientAuth = "none"    // Client certificates are not requested
+	ClientAuthVerify  ClientAuth = "verify"  // Verified when presented, optional otherwise
+	ClientAuthRequire ClientAuth = "require" // Every connection needs a verified certificate
+)
+
+// Config locates the TLS material of the servers
+type Config struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string // CA bundle used to verify client certificates, no mTLS when empty
+	ClientAuth   ClientAuth
+	MinVersion   uint16
+}
+
+// Store holds the server certificate and client CA pool, reloading them when the files change
+type Store struct {
+	config Config
+	cert   *tls.Certificate
+	pool   *x509.CertPool
+	mutex  sync.RWMutex
+}
+
+// NewStore loads the certificate, key and client CA bundle
+func NewStore(config Config) (*Store, error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		return nil, errors.New("both a certificate and a key file are required")
+	}
+	if config.ClientAuth == "" {
+		config.ClientAuth = ClientAuthVerify
+	}
+	switch config.ClientAuth {
+	case ClientAuthNone, ClientAuthVerify, ClientAuthRequire:
+	default:
+		return nil, fmt.Errorf("unknown client auth mode %q", config.ClientAuth)
+	}
+	if config.ClientAuth == ClientAuthRequire && config.ClientCAFile == "" {
+		return nil, errors.New("requiring client certificates needs a client CA file")
+	}
+
+	s := &Store{config: config}
+	if err := s.Load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Load reads the files again, the previous material is kept when it fails
+func (s *Store) Load() error {
+	cert, err := tls.LoadX509KeyPair(s.config.CertFile, s.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if s.config.ClientCAFile != "" {
+		data, err := os.ReadFile(s.config.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return errors.New("client CA file holds no PEM certificates")
+		}
+	}
+
+	s.mutex.Lock()
+	s.cert = &cert
+	s.pool = pool
+	s.mutex.Unlock()
+	return nil
+}
+
+// Run checks the files for changes at the interval and reloads them, it never returns
+func (s *Store) Run(interval time.Duration) {
+	Watch(s.files(), interval, func() error {
+		if err := s.Load(); err != nil {
+			return err
+		}
+		log.Printf("Reloaded TLS certificates")
+		return nil
+	}, func(err error) {
+		log.Printf("Failed to reload TLS certificates, keeping the previous ones: %v", err)
+	})
+}
+
+// ServerConfig returns a TLS configuration that always serves the latest certificate and client CAs
+// nextProtos are the ALPN protocols of the server, "h2" for gRPC and "http/1.1" for the HTTP API
+func (s *Store) ServerConfig(nextProtos ...string) *tls.Config {
+	base := &tls.Config{MinVersion: s.minVersion()}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		s.mutex.RLock()
+		defer s.mutex.RUnlock()
+
+		config := &tls.Config{
+			MinVersion:   base.MinVersion,
+			Certificates: []tls.Certificate{*s.cert},
+			NextProtos:   nextProtos,
+		}
+		if s.pool != nil {
+			config.ClientCAs = s.pool
+			switch s.config.ClientAuth {
+			case ClientAuthVerify:
+				config.ClientAuth = tls.VerifyClientCertIfGiven
+			case ClientAuthRequire:
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+		}
+		return config, nil
+	}
+	return base
+}
+
+func (s *Store) minVersion() uint16 {
+	if s.config.MinVersion == 0 {
+		return tls.VersionTLS12
+	}
+	return s.config.MinVersion
+}
+
+func (s *Store) files() []string {
+	files := []string{s.config.CertFile, s.config.KeyFile}
+	if s.config.ClientCAFile != "" {
+		files = append(files, s.config.ClientCAFile)
+	}
+	return files
+}
+
+// ParseMinVersion reads a TLS version such as "1.2" or "1.3"
+func ParseMinVersion(value string) (uint16, error) {
+	switch value {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version %q, expected 1.2 or 1.3", value)
+}
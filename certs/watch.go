@@ -0,0 +1,58 @@
+package certs
+
+import (
+	"os"
+	"time"
+)
+
+// fileStamp identifies a version of a file by its size and modification time
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// stampFiles records the current version of each file, missing files are left out
+func stampFiles(paths []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(paths))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+		}
+	}
+	return stamps
+}
+
+func sameStamps(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, stamp := range a {
+		other, ok := b[path]
+		if !ok || other.size != stamp.size || !other.modTime.Equal(stamp.modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// Watch calls reload whenever one of the files changes, checking at the interval, it never returns
+// Errors returned by reload are passed to onError and the change is retried at the next check
+func Watch(paths []string, interval time.Duration, reload func() error, onError func(error)) {
+	if interval <= 0 {
+		return
+	}
+	stamps := stampFiles(paths)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		current := stampFiles(paths)
+		if sameStamps(stamps, current) {
+			continue
+		}
+		if err := reload(); err != nil {
+			onError(err)
+			continue
+		}
+		stamps = current
+	}
+}
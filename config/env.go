@@ -1,12 +1,74 @@
 package config
 
 import (
+	"time"
+
 	"github.com/Netflix/go-env"
 )
 
 type EnvVariables struct {
 	Port     string `env:"PORT,default=3000"`
 	GrpcPort string `env:"GRPC_PORT,default=50051"`
+
+	// Header capture rules, lists are separated by '|'
+	HeaderAllow  []string `env:"HEADER_ALLOW"`
+	HeaderDeny   []string `env:"HEADER_DENY"`
+	HeaderRename []string `env:"HEADER_RENAME"` // From=To entries
+	HeaderRedact []string `env:"HEADER_REDACT,default=Authorization|Proxy-Authorization|Cookie|X-API-Key"`
+
+	// API key authentication
+	AuthEnabled  bool   `env:"AUTH_ENABLED,default=false"`
+	AuthAdminKey string `env:"AUTH_ADMIN_KEY"` // Bootstrap admin key, used to create the first stored keys
+
+	// JWT bearer authentication, enabled by JWT_JWKS or, through OpenID discovery, by JWT_ISSUER
+	JWTJWKS        string        `env:"JWT_JWKS"` // File path or http(s) URL of the key set
+	JWTJWKSRefresh time.Duration `env:"JWT_JWKS_REFRESH,default=5m"`
+	JWTIssuer      string        `env:"JWT_ISSUER"`
+	JWTAudience    string        `env:"JWT_AUDIENCE"`
+	JWTRolesClaim  string        `env:"JWT_ROLES_CLAIM,default=roles"`
+	JWTRoleMap     []string      `env:"JWT_ROLE_MAP"` // value=role entries
+	JWTTenantClaim string        `env:"JWT_TENANT_CLAIM,default=tenant"`
+	JWTQueuesClaim string        `env:"JWT_QUEUES_CLAIM"`
+	JWTLeeway      time.Duration `env:"JWT_LEEWAY,default=1m"`
+
+	// TLS for both servers, enabled by TLS_CERT_FILE and TLS_KEY_FILE
+	TLSCertFile       string        `env:"TLS_CERT_FILE"`
+	TLSKeyFile        string        `env:"TLS_KEY_FILE"`
+	TLSMinVersion     string        `env:"TLS_MIN_VERSION,default=1.2"`
+	TLSReloadInterval time.Duration `env:"TLS_RELOAD_INTERVAL,default=30s"` // How often files are checked for changes
+
+	// Mutual TLS, enabled by TLS_CLIENT_CA_FILE
+	TLSClientCAFile   string `env:"TLS_CLIENT_CA_FILE"`
+	TLSClientAuth     string `env:"TLS_CLIENT_AUTH,default=verify"`   // none, verify or require
+	TLSClientIdentity string `env:"TLS_CLIENT_IDENTITY,default=auto"` // auto, uri, dns, email or cn
+	TLSClientMapFile  string `env:"TLS_CLIENT_MAP_FILE"`
+
+	// Default quota of tenants without their own, zero is unlimited
+	TenantMaxPendingJobs int     `env:"TENANT_MAX_PENDING_JOBS,default=0"`
+	TenantMaxStoredBytes int64   `env:"TENANT_MAX_STORED_BYTES,default=0"`
+	TenantSubmitRate     float64 `env:"TENANT_SUBMIT_RATE,default=0"` // Jobs per second
+	TenantSubmitBurst    int     `env:"TENANT_SUBMIT_BURST,default=0"`
+
+	// Overload protection, zero rates and depths are unlimited
+	QueueMaxDepth  int           `env:"QUEUE_MAX_DEPTH,default=0"` // Overrides the limit set through the admin API
+	RateLimit      float64       `env:"RATE_LIMIT,default=0"`      // Submissions per second per API key, token or client IP
+	RateLimitBurst int           `env:"RATE_LIMIT_BURST,default=0"`
+	SubmitMaxWait  time.Duration `env:"SUBMIT_MAX_WAIT,default=60s"` // Longest X-Submit-Wait honoured
+
+	// Worker settings
+	PollMaxWait        time.Duration `env:"POLL_MAX_WAIT,default=60s"`       // Longest wait a worker may ask for when polling
+	HeartbeatTimeout   time.Duration `env:"HEARTBEAT_TIMEOUT,default=30s"`   // Connect streams silent for longer are closed
+	LeaseTimeout       time.Duration `env:"LEASE_TIMEOUT,default=5m"`        // Jobs without a heartbeat for longer are requeued, zero never expires
+	WorkerOfflineAfter time.Duration `env:"WORKER_OFFLINE_AFTER,default=1m"` // Idle workers not seen for longer are listed offline
+
+	// Webhook delivery settings
+	WebhookMaxAttempts    int           `env:"WEBHOOK_MAX_ATTEMPTS,default=8"`
+	WebhookTimeout        time.Duration `env:"WEBHOOK_TIMEOUT,default=10s"`
+	WebhookInitialBackoff time.Duration `env:"WEBHOOK_INITIAL_BACKOFF,default=1s"`
+	WebhookMaxBackoff     time.Duration `env:"WEBHOOK_MAX_BACKOFF,default=5m"`
+	WebhookKeepSucceeded  int           `env:"WEBHOOK_KEEP_SUCCEEDED,default=1000"` // Succeeded deliveries kept, zero keeps all
+	WebhookKeepFailed     int           `env:"WEBHOOK_KEEP_FAILED,default=1000"`    // Failed deliveries kept, zero keeps all
+	WebhookAllowedHosts   []string      `env:"WEBHOOK_ALLOWED_HOSTS"`               // Callback hosts separated by '|', any host when empty
 }
 
 func GetEnvVariables() (*EnvVariables, error) {
@@ -1,15 +1,23 @@
 package main
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"path/filepath"
 	"sync"
+	"time"
 
 	grpcServer "github.com/PAFFx/job-poll-queue/api/grpc"
+	grpcWorker "github.com/PAFFx/job-poll-queue/api/grpc/worker"
 	"github.com/PAFFx/job-poll-queue/api/http"
+	"github.com/PAFFx/job-poll-queue/auth"
+	"github.com/PAFFx/job-poll-queue/certs"
 	"github.com/PAFFx/job-poll-queue/config"
 	"github.com/PAFFx/job-poll-queue/queue"
+	"github.com/PAFFx/job-poll-queue/ratelimit"
+	"github.com/PAFFx/job-poll-queue/webhook"
 )
 
 func main() {
@@ -28,11 +36,103 @@ func main() {
 		log.Fatalf("Failed to get environment variables: %v", err)
 	}
 
+	// Apply header capture rules to submitted jobs, unless an admin set rules for the queue
+	jobQueue.SetDefaultHeaderRules(queue.NewHeaderRules(
+		envVars.HeaderAllow,
+		envVars.HeaderDeny,
+		envVars.HeaderRename,
+		envVars.HeaderRedact,
+	))
+
+	// Limit tenants without a quota of their own
+	defaultQuota := queue.Quota{
+		MaxPendingJobs: envVars.TenantMaxPendingJobs,
+		MaxStoredBytes: envVars.TenantMaxStoredBytes,
+		SubmitRate:     envVars.TenantSubmitRate,
+		SubmitBurst:    envVars.TenantSubmitBurst,
+	}
+	if err := defaultQuota.Validate(); err != nil {
+		log.Fatalf("Invalid default tenant quota: %v", err)
+	}
+	jobQueue.SetDefaultQuota(defaultQuota)
+
+	// Bound the queue and the rate of each client
+	if envVars.QueueMaxDepth > 0 {
+		if err := jobQueue.SetMaxDepth(envVars.QueueMaxDepth); err != nil {
+			log.Fatalf("Invalid queue max depth: %v", err)
+		}
+	}
+	// Requeue jobs whose worker stopped sending heartbeats
+	if envVars.LeaseTimeout < 0 {
+		log.Fatalf("Lease timeout must not be negative")
+	}
+	jobQueue.SetLeaseTimeout(envVars.LeaseTimeout)
+	go jobQueue.RunLeaseExpiry(time.Second)
+	jobQueue.SetWorkerTimeout(envVars.WorkerOfflineAfter)
+
+	submitRate := ratelimit.Limit{Rate: envVars.RateLimit, Burst: envVars.RateLimitBurst}
+	if envVars.RateLimit < 0 || envVars.RateLimitBurst < 0 {
+		log.Fatalf("Rate limits must not be negative")
+	}
+
+	// Deliver webhook callbacks when jobs finish
+	webhooks, err := webhook.NewDispatcher(jobQueue.GetStorage(), jobQueue.GetCallbackSecrets(), webhook.Config{
+		MaxAttempts:    envVars.WebhookMaxAttempts,
+		Timeout:        envVars.WebhookTimeout,
+		InitialBackoff: envVars.WebhookInitialBackoff,
+		MaxBackoff:     envVars.WebhookMaxBackoff,
+		KeepSucceeded:  envVars.WebhookKeepSucceeded,
+		KeepFailed:     envVars.WebhookKeepFailed,
+		AllowedHosts:   envVars.WebhookAllowedHosts,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create webhook dispatcher: %v", err)
+	}
+	jobQueue.GetStatusManager().OnTerminal(webhooks.Enqueue)
+	go webhooks.Run()
+
+	// Check API keys on both servers
+	keys, err := auth.NewKeyring(jobQueue.GetStorage())
+	if err != nil {
+		log.Fatalf("Failed to load API keys: %v", err)
+	}
+	jwtVerifier, err := newJWTVerifier(envVars)
+	if err != nil {
+		log.Fatalf("Failed to set up JWT authentication: %v", err)
+	}
+
+	// Serve TLS on both servers, with client certificates identifying workers
+	tlsConfig, certificates, err := newTLSConfig(envVars)
+	if err != nil {
+		log.Fatalf("Failed to set up TLS: %v", err)
+	}
+
+	authenticator := auth.NewAuthenticator(auth.Config{
+		Enabled:      envVars.AuthEnabled,
+		AdminKey:     envVars.AuthAdminKey,
+		JWT:          jwtVerifier,
+		Certificates: certificates,
+	}, keys)
+	if envVars.AuthEnabled && envVars.AuthAdminKey == "" && jwtVerifier == nil && keys.Len() == 0 {
+		log.Printf("Authentication is enabled but no API keys exist, set AUTH_ADMIN_KEY to create some")
+	}
+
 	// Create the HTTP API server
-	httpServer := http.NewServer(jobQueue)
+	httpServer := http.NewServer(jobQueue, webhooks, authenticator, http.Limits{
+		SubmitRate:    submitRate,
+		MaxSubmitWait: envVars.SubmitMaxWait,
+		MaxPollWait:   envVars.PollMaxWait,
+	})
 
 	// Create the gRPC server
-	grpcSrv := grpcServer.NewServer(jobQueue, envVars.GrpcPort)
+	grpcSrv := grpcServer.NewServer(jobQueue, authenticator, grpcServer.Config{
+		Port: envVars.GrpcPort,
+		TLS:  tlsConfig.grpc,
+		Worker: grpcWorker.Config{
+			MaxPollWait:      envVars.PollMaxWait,
+			HeartbeatTimeout: envVars.HeartbeatTimeout,
+		},
+	})
 
 	// Start both servers in goroutines
 	var wg sync.WaitGroup
@@ -41,8 +141,16 @@ func main() {
 	// Start HTTP server
 	go func() {
 		defer wg.Done()
-		log.Printf("Starting HTTP API server on port %s", envVars.Port)
-		if err := httpServer.Start(fmt.Sprintf(":%s", envVars.Port)); err != nil {
+		address := fmt.Sprintf(":%s", envVars.Port)
+		var err error
+		if tlsConfig.http != nil {
+			log.Printf("Starting HTTPS API server on port %s", envVars.Port)
+			err = httpServer.StartTLS(address, tlsConfig.http)
+		} else {
+			log.Printf("Starting HTTP API server on port %s", envVars.Port)
+			err = httpServer.Start(address)
+		}
+		if err != nil {
 			log.Fatalf("Failed to start HTTP server: %v", err)
 		}
 	}()
@@ -58,3 +166,87 @@ func main() {
 	// Wait for both servers
 	wg.Wait()
 }
+
+// newJWTVerifier loads the key set used to verify bearer JWTs, nil when JWT authentication is not configured
+func newJWTVerifier(envVars *config.EnvVariables) (*auth.JWTVerifier, error) {
+	if envVars.JWTJWKS == "" && envVars.JWTIssuer == "" {
+		return nil, nil
+	}
+
+	roleMap, err := auth.ParseRoleMap(envVars.JWTRoleMap)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks *auth.JWKS
+	if envVars.JWTJWKS != "" {
+		jwks, err = auth.NewJWKS(envVars.JWTJWKS, envVars.JWTJWKSRefresh)
+	} else {
+		jwks, err = auth.DiscoverJWKS(envVars.JWTIssuer, envVars.JWTJWKSRefresh)
+	}
+	if err != nil {
+		return nil, err
+	}
+	go jwks.Run()
+
+	return auth.NewJWTVerifier(auth.JWTConfig{
+		Issuer:      envVars.JWTIssuer,
+		Audience:    envVars.JWTAudience,
+		RolesClaim:  envVars.JWTRolesClaim,
+		TenantClaim: envVars.JWTTenantClaim,
+		QueuesClaim: envVars.JWTQueuesClaim,
+		RoleMap:     roleMap,
+		Leeway:      envVars.JWTLeeway,
+	}, jwks), nil
+}
+
+// serverTLS holds the TLS configuration of each server, nil fields mean plain text
+type serverTLS struct {
+	http *tls.Config
+	grpc *tls.Config
+}
+
+// newTLSConfig loads the server certificates and the client certificate mapping
+// Files are watched and reloaded when they change
+func newTLSConfig(envVars *config.EnvVariables) (serverTLS, *auth.CertificateMapper, error) {
+	if envVars.TLSCertFile == "" && envVars.TLSKeyFile == "" {
+		if envVars.TLSClientCAFile != "" {
+			return serverTLS{}, nil, errors.New("TLS_CLIENT_CA_FILE needs TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		return serverTLS{}, nil, nil
+	}
+
+	minVersion, err := certs.ParseMinVersion(envVars.TLSMinVersion)
+	if err != nil {
+		return serverTLS{}, nil, err
+	}
+	store, err := certs.NewStore(certs.Config{
+		CertFile:     envVars.TLSCertFile,
+		KeyFile:      envVars.TLSKeyFile,
+		ClientCAFile: envVars.TLSClientCAFile,
+		ClientAuth:   certs.ClientAuth(envVars.TLSClientAuth),
+		MinVersion:   minVersion,
+	})
+	if err != nil {
+		return serverTLS{}, nil, err
+	}
+	go store.Run(envVars.TLSReloadInterval)
+
+	var mapper *auth.CertificateMapper
+	if envVars.TLSClientCAFile != "" {
+		mapper, err = auth.NewCertificateMapper(auth.IdentitySource(envVars.TLSClientIdentity), envVars.TLSClientMapFile)
+		if err != nil {
+			return serverTLS{}, nil, err
+		}
+		if mapper.MapFile() != "" {
+			go certs.Watch([]string{mapper.MapFile()}, envVars.TLSReloadInterval, mapper.Load, func(err error) {
+				log.Printf("Failed to reload the client map, keeping the previous one: %v", err)
+			})
+		}
+	}
+
+	return serverTLS{
+		http: store.ServerConfig("http/1.1"),
+		grpc: store.ServerConfig("h2"),
+	}, mapper, nil
+}
@@ -21,9 +21,16 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
-// JobRequest is an empty request to get a job from the queue
+// JobRequest asks for the next job from the queue
 type JobRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Milliseconds to wait for a job when none is available, zero returns at once
+	WaitMs uint32 `protobuf:"varint,1,opt,name=wait_ms,json=waitMs,proto3" json:"wait_ms,omitempty"`
+	// Identity of the worker, jobs are taken anonymously when it is not set
+	Worker *WorkerInfo `protobuf:"bytes,2,opt,name=worker,proto3" json:"worker,omitempty"`
+	// Capabilities of the worker, only jobs whose requirements they meet are returned
+	// An empty value advertises the capability without a value
+	Capabilities  map[string]string `protobuf:"bytes,3,rep,name=capabilities,proto3" json:"capabilities,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -58,22 +65,124 @@ func (*JobRequest) Descriptor() ([]byte, []int) {
 	return file_proto_worker_worker_proto_rawDescGZIP(), []int{0}
 }
 
+func (x *JobRequest) GetWaitMs() uint32 {
+	if x != nil {
+		return x.WaitMs
+	}
+	return 0
+}
+
+func (x *JobRequest) GetWorker() *WorkerInfo {
+	if x != nil {
+		return x.Worker
+	}
+	return nil
+}
+
+func (x *JobRequest) GetCapabilities() map[string]string {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+// WorkerInfo registers a worker with the queue, it is listed by the admin API
+type WorkerInfo struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Stable worker identifier
+	WorkerId string `protobuf:"bytes,1,opt,name=worker_id,json=workerId,proto3" json:"worker_id,omitempty"`
+	// Host the worker runs on
+	Hostname string `protobuf:"bytes,2,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	// Version of the worker software
+	Version string `protobuf:"bytes,3,opt,name=version,proto3" json:"version,omitempty"`
+	// Free-form labels, such as region or pool
+	Labels        map[string]string `protobuf:"bytes,4,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WorkerInfo) Reset() {
+	*x = WorkerInfo{}
+	mi := &file_proto_worker_worker_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WorkerInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WorkerInfo) ProtoMessage() {}
+
+func (x *WorkerInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_worker_worker_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WorkerInfo.ProtoReflect.Descriptor instead.
+func (*WorkerInfo) Descriptor() ([]byte, []int) {
+	return file_proto_worker_worker_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *WorkerInfo) GetWorkerId() string {
+	if x != nil {
+		return x.WorkerId
+	}
+	return ""
+}
+
+func (x *WorkerInfo) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *WorkerInfo) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *WorkerInfo) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
 // Job represents a job to be processed by a worker
 type Job struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Unique job identifier
 	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	// Job payload (may contain any serialized data)
+	// Job payload as text (only set when the payload is valid UTF-8)
 	Payload string `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
 	// Headers associated with the job
-	Headers       map[string]string `protobuf:"bytes,3,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Headers map[string]string `protobuf:"bytes,3,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Raw job payload bytes (may contain any serialized data)
+	PayloadBytes []byte `protobuf:"bytes,4,opt,name=payload_bytes,json=payloadBytes,proto3" json:"payload_bytes,omitempty"`
+	// Content type of the payload as provided by the submitter
+	ContentType string `protobuf:"bytes,5,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	// Original HTTP request for jobs submitted through the proxy route
+	Request *HttpRequest `protobuf:"bytes,6,opt,name=request,proto3" json:"request,omitempty"`
+	// Lease token, required to complete or fail the job or extend its lease
+	LeaseToken    string `protobuf:"bytes,7,opt,name=lease_token,json=leaseToken,proto3" json:"lease_token,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Job) Reset() {
 	*x = Job{}
-	mi := &file_proto_worker_worker_proto_msgTypes[1]
+	mi := &file_proto_worker_worker_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -85,7 +194,7 @@ func (x *Job) String() string {
 func (*Job) ProtoMessage() {}
 
 func (x *Job) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_worker_worker_proto_msgTypes[1]
+	mi := &file_proto_worker_worker_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -98,7 +207,7 @@ func (x *Job) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Job.ProtoReflect.Descriptor instead.
 func (*Job) Descriptor() ([]byte, []int) {
-	return file_proto_worker_worker_proto_rawDescGZIP(), []int{1}
+	return file_proto_worker_worker_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *Job) GetId() string {
@@ -122,20 +231,122 @@ func (x *Job) GetHeaders() map[string]string {
 	return nil
 }
 
+func (x *Job) GetPayloadBytes() []byte {
+	if x != nil {
+		return x.PayloadBytes
+	}
+	return nil
+}
+
+func (x *Job) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *Job) GetRequest() *HttpRequest {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+func (x *Job) GetLeaseToken() string {
+	if x != nil {
+		return x.LeaseToken
+	}
+	return ""
+}
+
+// HttpRequest describes the HTTP call a client made through the proxy route
+type HttpRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// HTTP method (GET, POST, ...)
+	Method string `protobuf:"bytes,1,opt,name=method,proto3" json:"method,omitempty"`
+	// Request path relative to the proxy prefix
+	Path string `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	// Raw query string without the leading '?'
+	Query         string `protobuf:"bytes,3,opt,name=query,proto3" json:"query,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HttpRequest) Reset() {
+	*x = HttpRequest{}
+	mi := &file_proto_worker_worker_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HttpRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HttpRequest) ProtoMessage() {}
+
+func (x *HttpRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_worker_worker_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HttpRequest.ProtoReflect.Descriptor instead.
+func (*HttpRequest) Descriptor() ([]byte, []int) {
+	return file_proto_worker_worker_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *HttpRequest) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *HttpRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *HttpRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
 // JobResult contains the result of job processing
 type JobResult struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Job ID being completed
 	JobId string `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
-	// Result payload from worker processing
-	Payload       string `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	// Result payload from worker processing, as text
+	Payload string `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	// Raw result payload bytes, takes precedence over payload when set
+	PayloadBytes []byte `protobuf:"bytes,3,opt,name=payload_bytes,json=payloadBytes,proto3" json:"payload_bytes,omitempty"`
+	// Content type of the result payload
+	ContentType string `protobuf:"bytes,4,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	// HTTP status code to return to the submitter (defaults to 200)
+	StatusCode int32 `protobuf:"varint,5,opt,name=status_code,json=statusCode,proto3" json:"status_code,omitempty"`
+	// Response headers to return to the submitter
+	Headers map[string]string `protobuf:"bytes,6,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Lease token received with the job, optional on Connect streams
+	LeaseToken    string `protobuf:"bytes,7,opt,name=lease_token,json=leaseToken,proto3" json:"lease_token,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *JobResult) Reset() {
 	*x = JobResult{}
-	mi := &file_proto_worker_worker_proto_msgTypes[2]
+	mi := &file_proto_worker_worker_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -147,7 +358,7 @@ func (x *JobResult) String() string {
 func (*JobResult) ProtoMessage() {}
 
 func (x *JobResult) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_worker_worker_proto_msgTypes[2]
+	mi := &file_proto_worker_worker_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -160,7 +371,7 @@ func (x *JobResult) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use JobResult.ProtoReflect.Descriptor instead.
 func (*JobResult) Descriptor() ([]byte, []int) {
-	return file_proto_worker_worker_proto_rawDescGZIP(), []int{2}
+	return file_proto_worker_worker_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *JobResult) GetJobId() string {
@@ -177,6 +388,41 @@ func (x *JobResult) GetPayload() string {
 	return ""
 }
 
+func (x *JobResult) GetPayloadBytes() []byte {
+	if x != nil {
+		return x.PayloadBytes
+	}
+	return nil
+}
+
+func (x *JobResult) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *JobResult) GetStatusCode() int32 {
+	if x != nil {
+		return x.StatusCode
+	}
+	return 0
+}
+
+func (x *JobResult) GetHeaders() map[string]string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *JobResult) GetLeaseToken() string {
+	if x != nil {
+		return x.LeaseToken
+	}
+	return ""
+}
+
 // CompleteResponse is the response to a job completion
 type CompleteResponse struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
@@ -188,7 +434,7 @@ type CompleteResponse struct {
 
 func (x *CompleteResponse) Reset() {
 	*x = CompleteResponse{}
-	mi := &file_proto_worker_worker_proto_msgTypes[3]
+	mi := &file_proto_worker_worker_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -200,7 +446,7 @@ func (x *CompleteResponse) String() string {
 func (*CompleteResponse) ProtoMessage() {}
 
 func (x *CompleteResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_worker_worker_proto_msgTypes[3]
+	mi := &file_proto_worker_worker_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -213,7 +459,7 @@ func (x *CompleteResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CompleteResponse.ProtoReflect.Descriptor instead.
 func (*CompleteResponse) Descriptor() ([]byte, []int) {
-	return file_proto_worker_worker_proto_rawDescGZIP(), []int{3}
+	return file_proto_worker_worker_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *CompleteResponse) GetSuccess() bool {
@@ -223,29 +469,686 @@ func (x *CompleteResponse) GetSuccess() bool {
 	return false
 }
 
-var File_proto_worker_worker_proto protoreflect.FileDescriptor
+// FailRequest reports a job the worker could not process
+type FailRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Job ID being failed
+	JobId string `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	// Lease token received with the job, optional on Connect streams
+	LeaseToken string `protobuf:"bytes,2,opt,name=lease_token,json=leaseToken,proto3" json:"lease_token,omitempty"`
+	// Why the job failed, at most 1024 characters
+	Error         string `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
 
-const file_proto_worker_worker_proto_rawDesc = "" +
-	"\n" +
-	"\x19proto/worker/worker.proto\x12\x06worker\"\f\n" +
-	"\n" +
-	"JobRequest\"\x9f\x01\n" +
-	"\x03Job\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
-	"\apayload\x18\x02 \x01(\tR\apayload\x122\n" +
-	"\aheaders\x18\x03 \x03(\v2\x18.worker.Job.HeadersEntryR\aheaders\x1a:\n" +
-	"\fHeadersEntry\x12\x10\n" +
-	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"<\n" +
-	"\tJobResult\x12\x15\n" +
-	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x18\n" +
-	"\apayload\x18\x02 \x01(\tR\apayload\",\n" +
-	"\x10CompleteResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess2z\n" +
+func (x *FailRequest) Reset() {
+	*x = FailRequest{}
+	mi := &file_proto_worker_worker_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FailRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FailRequest) ProtoMessage() {}
+
+func (x *FailRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_worker_worker_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FailRequest.ProtoReflect.Descriptor instead.
+func (*FailRequest) Descriptor() ([]byte, []int) {
+	return file_proto_worker_worker_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *FailRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *FailRequest) GetLeaseToken() string {
+	if x != nil {
+		return x.LeaseToken
+	}
+	return ""
+}
+
+func (x *FailRequest) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// WorkerMessage is sent by workers on a Connect stream, exactly one field is set
+type WorkerMessage struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// First message of the stream
+	Hello *Hello `protobuf:"bytes,1,opt,name=hello,proto3" json:"hello,omitempty"`
+	// Keeps the session alive while no other message is sent
+	Heartbeat *Heartbeat `protobuf:"bytes,2,opt,name=heartbeat,proto3" json:"heartbeat,omitempty"`
+	// Progress of a job held by the session
+	Progress *Progress `protobuf:"bytes,3,opt,name=progress,proto3" json:"progress,omitempty"`
+	// Result of a job, acknowledged with an Ack
+	Result *JobResult `protobuf:"bytes,4,opt,name=result,proto3" json:"result,omitempty"`
+	// Failure of a job, acknowledged with an Ack
+	Failure       *FailRequest `protobuf:"bytes,5,opt,name=failure,proto3" json:"failure,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WorkerMessage) Reset() {
+	*x = WorkerMessage{}
+	mi := &file_proto_worker_worker_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WorkerMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WorkerMessage) ProtoMessage() {}
+
+func (x *WorkerMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_worker_worker_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WorkerMessage.ProtoReflect.Descriptor instead.
+func (*WorkerMessage) Descriptor() ([]byte, []int) {
+	return file_proto_worker_worker_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WorkerMessage) GetHello() *Hello {
+	if x != nil {
+		return x.Hello
+	}
+	return nil
+}
+
+func (x *WorkerMessage) GetHeartbeat() *Heartbeat {
+	if x != nil {
+		return x.Heartbeat
+	}
+	return nil
+}
+
+func (x *WorkerMessage) GetProgress() *Progress {
+	if x != nil {
+		return x.Progress
+	}
+	return nil
+}
+
+func (x *WorkerMessage) GetResult() *JobResult {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *WorkerMessage) GetFailure() *FailRequest {
+	if x != nil {
+		return x.Failure
+	}
+	return nil
+}
+
+// Hello declares the worker at the start of a Connect stream
+type Hello struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Identifies the worker in logs and admin views
+	WorkerId string `protobuf:"bytes,1,opt,name=worker_id,json=workerId,proto3" json:"worker_id,omitempty"`
+	// Number of jobs the worker processes at once (defaults to 1)
+	Concurrency uint32 `protobuf:"varint,2,opt,name=concurrency,proto3" json:"concurrency,omitempty"`
+	// Host the worker runs on
+	Hostname string `protobuf:"bytes,3,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	// Version of the worker software
+	Version string `protobuf:"bytes,4,opt,name=version,proto3" json:"version,omitempty"`
+	// Free-form labels, such as region or pool
+	Labels map[string]string `protobuf:"bytes,5,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Capabilities of the worker, only jobs whose requirements they meet are pushed
+	Capabilities  map[string]string `protobuf:"bytes,6,rep,name=capabilities,proto3" json:"capabilities,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Hello) Reset() {
+	*x = Hello{}
+	mi := &file_proto_worker_worker_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Hello) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Hello) ProtoMessage() {}
+
+func (x *Hello) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_worker_worker_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Hello.ProtoReflect.Descriptor instead.
+func (*Hello) Descriptor() ([]byte, []int) {
+	return file_proto_worker_worker_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Hello) GetWorkerId() string {
+	if x != nil {
+		return x.WorkerId
+	}
+	return ""
+}
+
+func (x *Hello) GetConcurrency() uint32 {
+	if x != nil {
+		return x.Concurrency
+	}
+	return 0
+}
+
+func (x *Hello) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *Hello) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *Hello) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Hello) GetCapabilities() map[string]string {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+// Heartbeat tells the server the worker is alive
+type Heartbeat struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Heartbeat) Reset() {
+	*x = Heartbeat{}
+	mi := &file_proto_worker_worker_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Heartbeat) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Heartbeat) ProtoMessage() {}
+
+func (x *Heartbeat) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_worker_worker_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Heartbeat.ProtoReflect.Descriptor instead.
+func (*Heartbeat) Descriptor() ([]byte, []int) {
+	return file_proto_worker_worker_proto_rawDescGZIP(), []int{9}
+}
+
+// Progress reports how far a job has come
+type Progress struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Job being processed
+	JobId string `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	// Percentage done, from 0 to 100
+	Percent uint32 `protobuf:"varint,2,opt,name=percent,proto3" json:"percent,omitempty"`
+	// Free-form status message
+	Message       string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Progress) Reset() {
+	*x = Progress{}
+	mi := &file_proto_worker_worker_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Progress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Progress) ProtoMessage() {}
+
+func (x *Progress) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_worker_worker_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Progress.ProtoReflect.Descriptor instead.
+func (*Progress) Descriptor() ([]byte, []int) {
+	return file_proto_worker_worker_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Progress) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *Progress) GetPercent() uint32 {
+	if x != nil {
+		return x.Percent
+	}
+	return 0
+}
+
+func (x *Progress) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// ServerMessage is sent by the server on a Connect stream, exactly one field is set
+type ServerMessage struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// A job for the worker, now processing
+	Job *Job `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"`
+	// Outcome of a result, or of a progress report that was rejected
+	Ack           *Ack `protobuf:"bytes,2,opt,name=ack,proto3" json:"ack,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerMessage) Reset() {
+	*x = ServerMessage{}
+	mi := &file_proto_worker_worker_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerMessage) ProtoMessage() {}
+
+func (x *ServerMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_worker_worker_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerMessage.ProtoReflect.Descriptor instead.
+func (*ServerMessage) Descriptor() ([]byte, []int) {
+	return file_proto_worker_worker_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ServerMessage) GetJob() *Job {
+	if x != nil {
+		return x.Job
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetAck() *Ack {
+	if x != nil {
+		return x.Ack
+	}
+	return nil
+}
+
+// Ack tells the worker whether a report was recorded
+type Ack struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Job the report was about
+	JobId string `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	// Whether the report was recorded
+	Success bool `protobuf:"varint,2,opt,name=success,proto3" json:"success,omitempty"`
+	// Why the report was rejected
+	Error         string `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Ack) Reset() {
+	*x = Ack{}
+	mi := &file_proto_worker_worker_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Ack) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Ack) ProtoMessage() {}
+
+func (x *Ack) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_worker_worker_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Ack.ProtoReflect.Descriptor instead.
+func (*Ack) Descriptor() ([]byte, []int) {
+	return file_proto_worker_worker_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Ack) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *Ack) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *Ack) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// LeaseRequest is a heartbeat for a job being processed
+type LeaseRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Job ID being processed
+	JobId string `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	// Progress percentage from 0 to 100, recorded with the message when either is set
+	Percent uint32 `protobuf:"varint,2,opt,name=percent,proto3" json:"percent,omitempty"`
+	// Free-form status message
+	Message string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	// Lease token received with the job
+	LeaseToken    string `protobuf:"bytes,4,opt,name=lease_token,json=leaseToken,proto3" json:"lease_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LeaseRequest) Reset() {
+	*x = LeaseRequest{}
+	mi := &file_proto_worker_worker_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LeaseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeaseRequest) ProtoMessage() {}
+
+func (x *LeaseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_worker_worker_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeaseRequest.ProtoReflect.Descriptor instead.
+func (*LeaseRequest) Descriptor() ([]byte, []int) {
+	return file_proto_worker_worker_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *LeaseRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *LeaseRequest) GetPercent() uint32 {
+	if x != nil {
+		return x.Percent
+	}
+	return 0
+}
+
+func (x *LeaseRequest) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *LeaseRequest) GetLeaseToken() string {
+	if x != nil {
+		return x.LeaseToken
+	}
+	return ""
+}
+
+// LeaseResponse carries the renewed lease
+type LeaseResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Unix milliseconds when the lease expires without another heartbeat, zero never expires
+	LeaseExpiresAt int64 `protobuf:"varint,1,opt,name=lease_expires_at,json=leaseExpiresAt,proto3" json:"lease_expires_at,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *LeaseResponse) Reset() {
+	*x = LeaseResponse{}
+	mi := &file_proto_worker_worker_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LeaseResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeaseResponse) ProtoMessage() {}
+
+func (x *LeaseResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_worker_worker_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeaseResponse.ProtoReflect.Descriptor instead.
+func (*LeaseResponse) Descriptor() ([]byte, []int) {
+	return file_proto_worker_worker_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *LeaseResponse) GetLeaseExpiresAt() int64 {
+	if x != nil {
+		return x.LeaseExpiresAt
+	}
+	return 0
+}
+
+var File_proto_worker_worker_proto protoreflect.FileDescriptor
+
+const file_proto_worker_worker_proto_rawDesc = "" +
+	"\n" +
+	"\x19proto/worker/worker.proto\x12\x06worker\"\xdc\x01\n" +
+	"\n" +
+	"JobRequest\x12\x17\n" +
+	"\await_ms\x18\x01 \x01(\rR\x06waitMs\x12*\n" +
+	"\x06worker\x18\x02 \x01(\v2\x12.worker.WorkerInfoR\x06worker\x12H\n" +
+	"\fcapabilities\x18\x03 \x03(\v2$.worker.JobRequest.CapabilitiesEntryR\fcapabilities\x1a?\n" +
+	"\x11CapabilitiesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xd2\x01\n" +
+	"\n" +
+	"WorkerInfo\x12\x1b\n" +
+	"\tworker_id\x18\x01 \x01(\tR\bworkerId\x12\x1a\n" +
+	"\bhostname\x18\x02 \x01(\tR\bhostname\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\tR\aversion\x126\n" +
+	"\x06labels\x18\x04 \x03(\v2\x1e.worker.WorkerInfo.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xb7\x02\n" +
+	"\x03Job\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
+	"\apayload\x18\x02 \x01(\tR\apayload\x122\n" +
+	"\aheaders\x18\x03 \x03(\v2\x18.worker.Job.HeadersEntryR\aheaders\x12#\n" +
+	"\rpayload_bytes\x18\x04 \x01(\fR\fpayloadBytes\x12!\n" +
+	"\fcontent_type\x18\x05 \x01(\tR\vcontentType\x12-\n" +
+	"\arequest\x18\x06 \x01(\v2\x13.worker.HttpRequestR\arequest\x12\x1f\n" +
+	"\vlease_token\x18\a \x01(\tR\n" +
+	"leaseToken\x1a:\n" +
+	"\fHeadersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"O\n" +
+	"\vHttpRequest\x12\x16\n" +
+	"\x06method\x18\x01 \x01(\tR\x06method\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\x12\x14\n" +
+	"\x05query\x18\x03 \x01(\tR\x05query\"\xbc\x02\n" +
+	"\tJobResult\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x18\n" +
+	"\apayload\x18\x02 \x01(\tR\apayload\x12#\n" +
+	"\rpayload_bytes\x18\x03 \x01(\fR\fpayloadBytes\x12!\n" +
+	"\fcontent_type\x18\x04 \x01(\tR\vcontentType\x12\x1f\n" +
+	"\vstatus_code\x18\x05 \x01(\x05R\n" +
+	"statusCode\x128\n" +
+	"\aheaders\x18\x06 \x03(\v2\x1e.worker.JobResult.HeadersEntryR\aheaders\x12\x1f\n" +
+	"\vlease_token\x18\a \x01(\tR\n" +
+	"leaseToken\x1a:\n" +
+	"\fHeadersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\",\n" +
+	"\x10CompleteResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\"[\n" +
+	"\vFailRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x1f\n" +
+	"\vlease_token\x18\x02 \x01(\tR\n" +
+	"leaseToken\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\"\xed\x01\n" +
+	"\rWorkerMessage\x12#\n" +
+	"\x05hello\x18\x01 \x01(\v2\r.worker.HelloR\x05hello\x12/\n" +
+	"\theartbeat\x18\x02 \x01(\v2\x11.worker.HeartbeatR\theartbeat\x12,\n" +
+	"\bprogress\x18\x03 \x01(\v2\x10.worker.ProgressR\bprogress\x12)\n" +
+	"\x06result\x18\x04 \x01(\v2\x11.worker.JobResultR\x06result\x12-\n" +
+	"\afailure\x18\x05 \x01(\v2\x13.worker.FailRequestR\afailure\"\xf0\x02\n" +
+	"\x05Hello\x12\x1b\n" +
+	"\tworker_id\x18\x01 \x01(\tR\bworkerId\x12 \n" +
+	"\vconcurrency\x18\x02 \x01(\rR\vconcurrency\x12\x1a\n" +
+	"\bhostname\x18\x03 \x01(\tR\bhostname\x12\x18\n" +
+	"\aversion\x18\x04 \x01(\tR\aversion\x121\n" +
+	"\x06labels\x18\x05 \x03(\v2\x19.worker.Hello.LabelsEntryR\x06labels\x12C\n" +
+	"\fcapabilities\x18\x06 \x03(\v2\x1f.worker.Hello.CapabilitiesEntryR\fcapabilities\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a?\n" +
+	"\x11CapabilitiesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\v\n" +
+	"\tHeartbeat\"U\n" +
+	"\bProgress\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x18\n" +
+	"\apercent\x18\x02 \x01(\rR\apercent\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"M\n" +
+	"\rServerMessage\x12\x1d\n" +
+	"\x03job\x18\x01 \x01(\v2\v.worker.JobR\x03job\x12\x1d\n" +
+	"\x03ack\x18\x02 \x01(\v2\v.worker.AckR\x03ack\"L\n" +
+	"\x03Ack\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x18\n" +
+	"\asuccess\x18\x02 \x01(\bR\asuccess\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\"z\n" +
+	"\fLeaseRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x18\n" +
+	"\apercent\x18\x02 \x01(\rR\apercent\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x12\x1f\n" +
+	"\vlease_token\x18\x04 \x01(\tR\n" +
+	"leaseToken\"9\n" +
+	"\rLeaseResponse\x12(\n" +
+	"\x10lease_expires_at\x18\x01 \x01(\x03R\x0eleaseExpiresAt2\xad\x02\n" +
 	"\rWorkerService\x12-\n" +
 	"\n" +
 	"RequestJob\x12\x12.worker.JobRequest\x1a\v.worker.Job\x12:\n" +
-	"\vCompleteJob\x12\x11.worker.JobResult\x1a\x18.worker.CompleteResponseB.Z,github.com/PAFFx/job-poll-queue/proto/workerb\x06proto3"
+	"\vCompleteJob\x12\x11.worker.JobResult\x1a\x18.worker.CompleteResponse\x128\n" +
+	"\aFailJob\x12\x13.worker.FailRequest\x1a\x18.worker.CompleteResponse\x12:\n" +
+	"\vExtendLease\x12\x14.worker.LeaseRequest\x1a\x15.worker.LeaseResponse\x12;\n" +
+	"\aConnect\x12\x15.worker.WorkerMessage\x1a\x15.worker.ServerMessage(\x010\x01B.Z,github.com/PAFFx/job-poll-queue/proto/workerb\x06proto3"
 
 var (
 	file_proto_worker_worker_proto_rawDescOnce sync.Once
@@ -259,25 +1162,61 @@ func file_proto_worker_worker_proto_rawDescGZIP() []byte {
 	return file_proto_worker_worker_proto_rawDescData
 }
 
-var file_proto_worker_worker_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_proto_worker_worker_proto_msgTypes = make([]protoimpl.MessageInfo, 21)
 var file_proto_worker_worker_proto_goTypes = []any{
 	(*JobRequest)(nil),       // 0: worker.JobRequest
-	(*Job)(nil),              // 1: worker.Job
-	(*JobResult)(nil),        // 2: worker.JobResult
-	(*CompleteResponse)(nil), // 3: worker.CompleteResponse
-	nil,                      // 4: worker.Job.HeadersEntry
+	(*WorkerInfo)(nil),       // 1: worker.WorkerInfo
+	(*Job)(nil),              // 2: worker.Job
+	(*HttpRequest)(nil),      // 3: worker.HttpRequest
+	(*JobResult)(nil),        // 4: worker.JobResult
+	(*CompleteResponse)(nil), // 5: worker.CompleteResponse
+	(*FailRequest)(nil),      // 6: worker.FailRequest
+	(*WorkerMessage)(nil),    // 7: worker.WorkerMessage
+	(*Hello)(nil),            // 8: worker.Hello
+	(*Heartbeat)(nil),        // 9: worker.Heartbeat
+	(*Progress)(nil),         // 10: worker.Progress
+	(*ServerMessage)(nil),    // 11: worker.ServerMessage
+	(*Ack)(nil),              // 12: worker.Ack
+	(*LeaseRequest)(nil),     // 13: worker.LeaseRequest
+	(*LeaseResponse)(nil),    // 14: worker.LeaseResponse
+	nil,                      // 15: worker.JobRequest.CapabilitiesEntry
+	nil,                      // 16: worker.WorkerInfo.LabelsEntry
+	nil,                      // 17: worker.Job.HeadersEntry
+	nil,                      // 18: worker.JobResult.HeadersEntry
+	nil,                      // 19: worker.Hello.LabelsEntry
+	nil,                      // 20: worker.Hello.CapabilitiesEntry
 }
 var file_proto_worker_worker_proto_depIdxs = []int32{
-	4, // 0: worker.Job.headers:type_name -> worker.Job.HeadersEntry
-	0, // 1: worker.WorkerService.RequestJob:input_type -> worker.JobRequest
-	2, // 2: worker.WorkerService.CompleteJob:input_type -> worker.JobResult
-	1, // 3: worker.WorkerService.RequestJob:output_type -> worker.Job
-	3, // 4: worker.WorkerService.CompleteJob:output_type -> worker.CompleteResponse
-	3, // [3:5] is the sub-list for method output_type
-	1, // [1:3] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	1,  // 0: worker.JobRequest.worker:type_name -> worker.WorkerInfo
+	15, // 1: worker.JobRequest.capabilities:type_name -> worker.JobRequest.CapabilitiesEntry
+	16, // 2: worker.WorkerInfo.labels:type_name -> worker.WorkerInfo.LabelsEntry
+	17, // 3: worker.Job.headers:type_name -> worker.Job.HeadersEntry
+	3,  // 4: worker.Job.request:type_name -> worker.HttpRequest
+	18, // 5: worker.JobResult.headers:type_name -> worker.JobResult.HeadersEntry
+	8,  // 6: worker.WorkerMessage.hello:type_name -> worker.Hello
+	9,  // 7: worker.WorkerMessage.heartbeat:type_name -> worker.Heartbeat
+	10, // 8: worker.WorkerMessage.progress:type_name -> worker.Progress
+	4,  // 9: worker.WorkerMessage.result:type_name -> worker.JobResult
+	6,  // 10: worker.WorkerMessage.failure:type_name -> worker.FailRequest
+	19, // 11: worker.Hello.labels:type_name -> worker.Hello.LabelsEntry
+	20, // 12: worker.Hello.capabilities:type_name -> worker.Hello.CapabilitiesEntry
+	2,  // 13: worker.ServerMessage.job:type_name -> worker.Job
+	12, // 14: worker.ServerMessage.ack:type_name -> worker.Ack
+	0,  // 15: worker.WorkerService.RequestJob:input_type -> worker.JobRequest
+	4,  // 16: worker.WorkerService.CompleteJob:input_type -> worker.JobResult
+	6,  // 17: worker.WorkerService.FailJob:input_type -> worker.FailRequest
+	13, // 18: worker.WorkerService.ExtendLease:input_type -> worker.LeaseRequest
+	7,  // 19: worker.WorkerService.Connect:input_type -> worker.WorkerMessage
+	2,  // 20: worker.WorkerService.RequestJob:output_type -> worker.Job
+	5,  // 21: worker.WorkerService.CompleteJob:output_type -> worker.CompleteResponse
+	5,  // 22: worker.WorkerService.FailJob:output_type -> worker.CompleteResponse
+	14, // 23: worker.WorkerService.ExtendLease:output_type -> worker.LeaseResponse
+	11, // 24: worker.WorkerService.Connect:output_type -> worker.ServerMessage
+	20, // [20:25] is the sub-list for method output_type
+	15, // [15:20] is the sub-list for method input_type
+	15, // [15:15] is the sub-list for extension type_name
+	15, // [15:15] is the sub-list for extension extendee
+	0,  // [0:15] is the sub-list for field type_name
 }
 
 func init() { file_proto_worker_worker_proto_init() }
@@ -291,7 +1230,7 @@ func file_proto_worker_worker_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_worker_worker_proto_rawDesc), len(file_proto_worker_worker_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   5,
+			NumMessages:   21,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -21,6 +21,9 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	WorkerService_RequestJob_FullMethodName  = "/worker.WorkerService/RequestJob"
 	WorkerService_CompleteJob_FullMethodName = "/worker.WorkerService/CompleteJob"
+	WorkerService_FailJob_FullMethodName     = "/worker.WorkerService/FailJob"
+	WorkerService_ExtendLease_FullMethodName = "/worker.WorkerService/ExtendLease"
+	WorkerService_Connect_FullMethodName     = "/worker.WorkerService/Connect"
 )
 
 // WorkerServiceClient is the client API for WorkerService service.
@@ -33,6 +36,13 @@ type WorkerServiceClient interface {
 	RequestJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error)
 	// CompleteJob allows workers to report job completion with results
 	CompleteJob(ctx context.Context, in *JobResult, opts ...grpc.CallOption) (*CompleteResponse, error)
+	// FailJob finishes a job the worker could not process, the error is returned to a waiting submitter
+	FailJob(ctx context.Context, in *FailRequest, opts ...grpc.CallOption) (*CompleteResponse, error)
+	// ExtendLease renews the lease of a job being processed, optionally reporting its progress
+	ExtendLease(ctx context.Context, in *LeaseRequest, opts ...grpc.CallOption) (*LeaseResponse, error)
+	// Connect opens a session: the worker sends a hello, then heartbeats, progress and results,
+	// and the server pushes jobs whenever the worker has free capacity
+	Connect(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[WorkerMessage, ServerMessage], error)
 }
 
 type workerServiceClient struct {
@@ -63,6 +73,39 @@ func (c *workerServiceClient) CompleteJob(ctx context.Context, in *JobResult, op
 	return out, nil
 }
 
+func (c *workerServiceClient) FailJob(ctx context.Context, in *FailRequest, opts ...grpc.CallOption) (*CompleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CompleteResponse)
+	err := c.cc.Invoke(ctx, WorkerService_FailJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *workerServiceClient) ExtendLease(ctx context.Context, in *LeaseRequest, opts ...grpc.CallOption) (*LeaseResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LeaseResponse)
+	err := c.cc.Invoke(ctx, WorkerService_ExtendLease_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *workerServiceClient) Connect(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[WorkerMessage, ServerMessage], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &WorkerService_ServiceDesc.Streams[0], WorkerService_Connect_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WorkerMessage, ServerMessage]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WorkerService_ConnectClient = grpc.BidiStreamingClient[WorkerMessage, ServerMessage]
+
 // WorkerServiceServer is the server API for WorkerService service.
 // All implementations must embed UnimplementedWorkerServiceServer
 // for forward compatibility.
@@ -73,6 +116,13 @@ type WorkerServiceServer interface {
 	RequestJob(context.Context, *JobRequest) (*Job, error)
 	// CompleteJob allows workers to report job completion with results
 	CompleteJob(context.Context, *JobResult) (*CompleteResponse, error)
+	// FailJob finishes a job the worker could not process, the error is returned to a waiting submitter
+	FailJob(context.Context, *FailRequest) (*CompleteResponse, error)
+	// ExtendLease renews the lease of a job being processed, optionally reporting its progress
+	ExtendLease(context.Context, *LeaseRequest) (*LeaseResponse, error)
+	// Connect opens a session: the worker sends a hello, then heartbeats, progress and results,
+	// and the server pushes jobs whenever the worker has free capacity
+	Connect(grpc.BidiStreamingServer[WorkerMessage, ServerMessage]) error
 	mustEmbedUnimplementedWorkerServiceServer()
 }
 
@@ -89,6 +139,15 @@ func (UnimplementedWorkerServiceServer) RequestJob(context.Context, *JobRequest)
 func (UnimplementedWorkerServiceServer) CompleteJob(context.Context, *JobResult) (*CompleteResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CompleteJob not implemented")
 }
+func (UnimplementedWorkerServiceServer) FailJob(context.Context, *FailRequest) (*CompleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FailJob not implemented")
+}
+func (UnimplementedWorkerServiceServer) ExtendLease(context.Context, *LeaseRequest) (*LeaseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExtendLease not implemented")
+}
+func (UnimplementedWorkerServiceServer) Connect(grpc.BidiStreamingServer[WorkerMessage, ServerMessage]) error {
+	return status.Errorf(codes.Unimplemented, "method Connect not implemented")
+}
 func (UnimplementedWorkerServiceServer) mustEmbedUnimplementedWorkerServiceServer() {}
 func (UnimplementedWorkerServiceServer) testEmbeddedByValue()                       {}
 
@@ -146,6 +205,49 @@ func _WorkerService_CompleteJob_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _WorkerService_FailJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FailRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServiceServer).FailJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WorkerService_FailJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServiceServer).FailJob(ctx, req.(*FailRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WorkerService_ExtendLease_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServiceServer).ExtendLease(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WorkerService_ExtendLease_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServiceServer).ExtendLease(ctx, req.(*LeaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WorkerService_Connect_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(WorkerServiceServer).Connect(&grpc.GenericServerStream[WorkerMessage, ServerMessage]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WorkerService_ConnectServer = grpc.BidiStreamingServer[WorkerMessage, ServerMessage]
+
 // WorkerService_ServiceDesc is the grpc.ServiceDesc for WorkerService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -161,7 +263,22 @@ var WorkerService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CompleteJob",
 			Handler:    _WorkerService_CompleteJob_Handler,
 		},
+		{
+			MethodName: "FailJob",
+			Handler:    _WorkerService_FailJob_Handler,
+		},
+		{
+			MethodName: "ExtendLease",
+			Handler:    _WorkerService_ExtendLease_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Connect",
+			Handler:       _WorkerService_Connect_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/worker/worker.proto",
 }
@@ -0,0 +1,81 @@
+package queue
+
+import (
+	"sync"
+)
+
+// callbackSecretsKey names the persisted callback secrets in storage
+const callbackSecretsKey = "callback-secrets"
+
+// CallbackSecrets keeps the secrets signing webhook deliveries by job ID
+// They are stored apart from the job records, in a file only the server user can read
+type CallbackSecrets struct {
+	storage *Storage
+	secrets map[string]string
+	mutex   sync.Mutex
+}
+
+// NewCallbackSecrets creates a secret store and loads the secrets persisted in storage
+func NewCallbackSecrets(storage *Storage) (*CallbackSecrets, error) {
+	s := &CallbackSecrets{storage: storage, secrets: make(map[string]string)}
+	if _, err := storage.LoadState(callbackSecretsKey, &s.secrets); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Put stores the secret of a job's callback
+func (s *CallbackSecrets) Put(jobID string, secret string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	previous, existed := s.secrets[jobID]
+	s.secrets[jobID] = secret
+	if err := s.storage.savePrivateState(callbackSecretsKey, s.secrets); err != nil {
+		if existed {
+			s.secrets[jobID] = previous
+		} else {
+			delete(s.secrets, jobID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Get returns the secret of a job's callback
+func (s *CallbackSecrets) Get(jobID string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	secret, ok := s.secrets[jobID]
+	return secret, ok
+}
+
+// Delete forgets the secret of a job's callback, once nothing will be signed with it
+func (s *CallbackSecrets) Delete(jobID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.secrets[jobID]; !ok {
+		return nil
+	}
+	delete(s.secrets, jobID)
+	return s.storage.savePrivateState(callbackSecretsKey, s.secrets)
+}
+
+// keepCallbackSecret moves the secret of a job's callback into the store
+// The job only records that its deliveries are signed
+func (q *Queue) keepCallbackSecret(msg *Message) error {
+	if msg.Callback == nil || msg.Callback.Secret == "" {
+		return nil
+	}
+	if err := q.secrets.Put(msg.ID, msg.Callback.Secret); err != nil {
+		return err
+	}
+	msg.Callback = &Callback{URL: msg.Callback.URL, Signed: true}
+	return nil
+}
+
+// GetCallbackSecrets returns the store of the secrets signing webhook deliveries
+func (q *Queue) GetCallbackSecrets() *CallbackSecrets {
+	return q.secrets
+}
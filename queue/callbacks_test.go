@@ -0,0 +1,44 @@
+package queue
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPushKeepsCallbackSecretOutOfJobRecords(t *testing.T) {
+	dir := t.TempDir()
+	q, err := NewQueue("test", dir)
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+	pushJobs(t, q,
+		Message{ID: "signed", Payload: []byte("x"), Callback: &Callback{URL: "http://example.com", Secret: "s3cret"}},
+		Message{ID: "unsigned", Payload: []byte("x"), Callback: &Callback{URL: "http://example.com"}},
+	)
+
+	for _, name := range []string{"test.json", "test-jobstatus.json"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || bytes.Contains(data, []byte("s3cret")) {
+			t.Errorf("%s = %s, %v, want it without the secret", name, data, err)
+		}
+	}
+	info, err := os.Stat(filepath.Join(dir, "test-callback-secrets.json"))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("secret file = %v, %v, want it readable by the owner only", info, err)
+	}
+
+	// The secret is found again after a restart, by job ID
+	restarted, err := NewQueue("test", dir)
+	if err != nil {
+		t.Fatalf("NewQueue after restart: %v", err)
+	}
+	for id, want := range map[string]string{"signed": "s3cret", "unsigned": ""} {
+		job, _ := restarted.GetStatusManager().GetJobStatus(id)
+		secret, _ := restarted.GetCallbackSecrets().Get(id)
+		if secret != want || job.Callback.Signed != (want != "") || job.Callback.Secret != "" {
+			t.Errorf("%s: secret %q, callback %+v, want secret %q", id, secret, job.Callback, want)
+		}
+	}
+}
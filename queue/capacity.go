@@ -0,0 +1,92 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrQueueFull is wrapped by every CapacityError
+var ErrQueueFull = errors.New("queue is full")
+
+// CapacityError reports a job rejected because the queue holds its maximum number of pending jobs
+type CapacityError struct {
+	Depth    int
+	MaxDepth int
+}
+
+func (e *CapacityError) Error() string {
+	return fmt.Sprintf("queue is full, %d of %d pending jobs", e.Depth, e.MaxDepth)
+}
+
+func (e *CapacityError) Unwrap() error {
+	return ErrQueueFull
+}
+
+// SetMaxDepth limits the number of pending jobs and persists the limit, zero is unlimited
+func (q *Queue) SetMaxDepth(maxDepth int) error {
+	if maxDepth < 0 {
+		return errors.New("max_depth must not be negative")
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	previous := q.settings.MaxDepth
+	q.settings.MaxDepth = maxDepth
+	if err := q.storage.SaveState(settingsKey, q.settings); err != nil {
+		q.settings.MaxDepth = previous
+		return err
+	}
+
+	// A higher limit may make room for blocked submitters
+	q.notifyRoomLocked()
+	return nil
+}
+
+// GetMaxDepth returns the maximum number of pending jobs, zero is unlimited
+func (q *Queue) GetMaxDepth() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.settings.MaxDepth
+}
+
+// Depth returns the number of jobs waiting in the queue
+func (q *Queue) Depth() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.messages)
+}
+
+// PushWait adds a message like Push, but waits for room while the queue is full
+// When the context ends first, the last *CapacityError is returned; other errors are returned at once
+func (q *Queue) PushWait(ctx context.Context, msg Message) error {
+	for {
+		room, err := q.push(msg)
+		var capacityErr *CapacityError
+		if !errors.As(err, &capacityErr) {
+			return err
+		}
+
+		select {
+		case <-room:
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// checkCapacityLocked rejects a job when the queue is full, must be called with the lock held
+func (q *Queue) checkCapacityLocked() error {
+	if q.settings.MaxDepth > 0 && len(q.messages) >= q.settings.MaxDepth {
+		return &CapacityError{Depth: len(q.messages), MaxDepth: q.settings.MaxDepth}
+	}
+	return nil
+}
+
+// notifyRoomLocked wakes submitters waiting for room, must be called with the lock held
+// after jobs leave the queue or the limit changes
+func (q *Queue) notifyRoomLocked() {
+	close(q.room)
+	q.room = make(chan struct{})
+}
@@ -0,0 +1,185 @@
+package queue
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies a job lifecycle event
+type EventType string
+
+const (
+	EventJobQueued    EventType = "job.queued"    // Job was pushed to the queue
+	EventJobStarted   EventType = "job.started"   // Job was popped by a worker
+	EventJobStatus    EventType = "job.status"    // Job status changed
+	EventJobProgress  EventType = "job.progress"  // Worker reported progress
+	EventJobCompleted EventType = "job.completed" // Job result was submitted
+	EventJobCancelled EventType = "job.cancelled" // Job was deleted, cleared or purged before completing
+)
+
+// Event describes a change in a job's lifecycle
+type Event struct {
+	ID       uint64    `json:"id"`
+	Type     EventType `json:"type"`
+	JobID    string    `json:"job_id"`
+	Status   JobStatus `json:"status"`
+	Tenant   string    `json:"tenant,omitempty"`
+	Progress *Progress `json:"progress,omitempty"` // Set on job.progress events
+	Time     time.Time `json:"time"`
+}
+
+// EventFilter selects the events a subscriber receives, empty fields match everything
+type EventFilter struct {
+	JobID    string
+	Types    []EventType
+	Statuses []JobStatus
+	Tenant   string
+}
+
+// Matches reports whether an event passes the filter
+func (f EventFilter) Matches(event Event) bool {
+	if f.JobID != "" && f.JobID != event.JobID {
+		return false
+	}
+	if len(f.Types) > 0 && !containsValue(f.Types, event.Type) {
+		return false
+	}
+	if len(f.Statuses) > 0 && !containsValue(f.Statuses, event.Status) {
+		return false
+	}
+	if f.Tenant != "" && f.Tenant != event.Tenant {
+		return false
+	}
+	return true
+}
+
+// subscriberBuffer is the number of events buffered per subscriber
+// Subscribers that fall further behind are dropped and must resume from their last event ID
+const subscriberBuffer = 256
+
+// Subscription delivers matching events until it is closed
+type Subscription struct {
+	C      <-chan Event
+	Resync bool // The events after the requested ID are no longer retained, none were replayed
+	events chan Event
+	filter EventFilter
+	bus    *EventBus
+	closed bool
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.bus.unsubscribe(s)
+}
+
+// EventBus fans out job lifecycle events and keeps a bounded history for resuming
+type EventBus struct {
+	nextID      uint64
+	history     []Event
+	historySize int
+	subscribers map[*Subscription]struct{}
+	mutex       sync.Mutex
+}
+
+// NewEventBus creates an event bus keeping the last historySize events
+func NewEventBus(historySize int) *EventBus {
+	return &EventBus{
+		nextID:      1,
+		historySize: historySize,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns an ID to the event and delivers it to all matching subscribers
+func (b *EventBus) Publish(eventType EventType, job *Message) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	event := Event{
+		ID:     b.nextID,
+		Type:   eventType,
+		JobID:  job.ID,
+		Status: job.Status,
+		Tenant: job.Tenant,
+		Time:   time.Now(),
+	}
+	if eventType == EventJobProgress {
+		event.Progress = job.Progress
+	}
+	b.nextID++
+
+	b.history = append(b.history, event)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for sub := range b.subscribers {
+		if !sub.filter.Matches(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			// Subscriber is too slow, drop it so it can resume
+			b.closeLocked(sub)
+		}
+	}
+}
+
+// Subscribe returns a subscription for matching events
+// Events after lastEventID are replayed first; when some of them are no longer in the history,
+// or the ID was never assigned (such as after a restart), nothing is replayed and Resync is set
+func (b *EventBus) Subscribe(filter EventFilter, lastEventID uint64) *Subscription {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var replay []Event
+	resync := false
+	if lastEventID > 0 {
+		oldest := b.nextID
+		if len(b.history) > 0 {
+			oldest = b.history[0].ID
+		}
+		resync = lastEventID+1 < oldest || lastEventID >= b.nextID
+		for _, event := range b.history {
+			if !resync && event.ID > lastEventID && filter.Matches(event) {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	// The buffer holds the whole replay on top of the usual room for live events
+	events := make(chan Event, len(replay)+subscriberBuffer)
+	for _, event := range replay {
+		events <- event
+	}
+	sub := &Subscription{C: events, Resync: resync, events: events, filter: filter, bus: b}
+
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (b *EventBus) unsubscribe(sub *Subscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.closeLocked(sub)
+}
+
+// closeLocked removes a subscriber and closes its channel, must be called with the lock held
+func (b *EventBus) closeLocked(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(b.subscribers, sub)
+	close(sub.events)
+}
+
+func containsValue[T comparable](list []T, value T) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
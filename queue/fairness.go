@@ -0,0 +1,189 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FairnessPolicy decides whose jobs are handed out next
+type FairnessPolicy string
+
+const (
+	// FairnessFIFO hands out jobs in queue order, the default
+	FairnessFIFO FairnessPolicy = "fifo"
+	// FairnessRoundRobin takes turns between the keys with due jobs
+	FairnessRoundRobin FairnessPolicy = "round_robin"
+	// FairnessWeighted takes turns in proportion to the weight of each key
+	FairnessWeighted FairnessPolicy = "weighted"
+)
+
+// FairnessKey is the job attribute jobs are shared out by
+type FairnessKey string
+
+const (
+	FairnessKeyTenant FairnessKey = "tenant"
+	FairnessKeyGroup  FairnessKey = "group"
+	FairnessKeyHeader FairnessKey = "header" // Value of a captured header, see Fairness.Header
+)
+
+// maxFairnessWeight bounds the weight of a key
+const maxFairnessWeight = 1000
+
+// Fairness shares out the queue between the values of a key, so one key with a large backlog
+// cannot hold back the others. Within a key, jobs keep their priority and queue order
+type Fairness struct {
+	Policy  FairnessPolicy `json:"policy,omitempty"`  // Defaults to fifo
+	Key     FairnessKey    `json:"key,omitempty"`     // Required unless the policy is fifo
+	Header  string         `json:"header,omitempty"`  // Header name, for the header key
+	Weights map[string]int `json:"weights,omitempty"` // Share of each key value with the weighted policy, 1 when absent
+}
+
+// Validate checks the policy, key and weights
+func (f Fairness) Validate() error {
+	switch f.Policy {
+	case "", FairnessFIFO:
+		return nil
+	case FairnessRoundRobin, FairnessWeighted:
+	default:
+		return errors.New("policy must be fifo, round_robin or weighted")
+	}
+
+	switch f.Key {
+	case FairnessKeyTenant, FairnessKeyGroup:
+	case FairnessKeyHeader:
+		if f.Header == "" {
+			return errors.New("header is required with the header key")
+		}
+	default:
+		return errors.New("key must be tenant, group or header")
+	}
+
+	for value, weight := range f.Weights {
+		if weight < 1 || weight > maxFairnessWeight {
+			return fmt.Errorf("weight of %q must be between 1 and %d", value, maxFairnessWeight)
+		}
+	}
+	return nil
+}
+
+// enabled reports whether jobs are shared out by key rather than handed out in queue order
+func (f Fairness) enabled() bool {
+	return f.Policy == FairnessRoundRobin || f.Policy == FairnessWeighted
+}
+
+// keyOf returns the value of the fairness key of a job, jobs without one share the empty key
+// In a fifo queue every job has the empty key
+func (f Fairness) keyOf(msg *Message) string {
+	if !f.enabled() {
+		return ""
+	}
+	switch f.Key {
+	case FairnessKeyTenant:
+		return msg.Tenant
+	case FairnessKeyGroup:
+		return msg.Group
+	case FairnessKeyHeader:
+		return headerValue(msg.Headers, f.Header)
+	}
+	return ""
+}
+
+// weight returns the share of a key value
+func (f Fairness) weight(key string) float64 {
+	if weight, ok := f.Weights[key]; ok && f.Policy == FairnessWeighted {
+		return float64(weight)
+	}
+	return 1
+}
+
+// SetFairness replaces the fairness policy of the queue and persists it, turns start over
+func (q *Queue) SetFairness(fairness Fairness) error {
+	if err := fairness.Validate(); err != nil {
+		return err
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	previous := q.settings.Fairness
+	q.settings.Fairness = fairness
+	if err := q.storage.SaveState(settingsKey, q.settings); err != nil {
+		q.settings.Fairness = previous
+		return err
+	}
+	q.passes = make(map[string]float64)
+	q.virtualPass = 0
+	return q.dispatchLocked()
+}
+
+// GetFairness returns the fairness policy of the queue, with the default policy spelled out
+func (q *Queue) GetFairness() Fairness {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	fairness := q.settings.Fairness
+	if fairness.Policy == "" {
+		fairness.Policy = FairnessFIFO
+	}
+	return fairness
+}
+
+// Backlog counts the queued jobs of a tenant, or of every tenant when it is empty, by fairness key
+// It is nil while the queue is fifo
+func (q *Queue) Backlog(tenant string) map[string]int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	fairness := q.settings.Fairness
+	if !fairness.enabled() {
+		return nil
+	}
+	backlog := make(map[string]int)
+	for i := range q.messages {
+		if msg := &q.messages[i]; tenant == "" || msg.Tenant == tenant {
+			backlog[fairness.keyOf(msg)]++
+		}
+	}
+	return backlog
+}
+
+// Turns are taken by stride scheduling: each key has a pass that grows by 1/weight with every job
+// it is handed, and the key with the lowest pass goes next. Keys that were idle start at the pass
+// of the last key served, so they cannot claim the turns they missed.
+// passOf returns the pass of a key, must be called with the lock held
+func (q *Queue) passOf(key string) float64 {
+	return max(q.passes[key], q.virtualPass)
+}
+
+// pickLocked returns the index of the next job among the best job of each key, -1 when there is none
+// Ties go to the job queued first; must be called with the lock held
+func (q *Queue) pickLocked(heads map[string]int) int {
+	best, bestPass := -1, 0.0
+	for key, index := range heads {
+		pass := q.passOf(key)
+		if best < 0 || pass < bestPass || (pass == bestPass && index < best) {
+			best, bestPass = index, pass
+		}
+	}
+	return best
+}
+
+// chargeLocked gives a key's turn to a job being handed out, must be called with the lock held
+func (q *Queue) chargeLocked(msg *Message) {
+	fairness := q.settings.Fairness
+	if !fairness.enabled() {
+		return
+	}
+
+	key := fairness.keyOf(msg)
+	pass := q.passOf(key)
+	q.virtualPass = pass
+	q.passes[key] = pass + 1/fairness.weight(key)
+
+	// Keys at or behind the last pass served are the same as unseen ones
+	for other, otherPass := range q.passes {
+		if otherPass <= q.virtualPass {
+			delete(q.passes, other)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package queue
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFairnessValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		fairness Fairness
+		wantErr  bool
+	}{
+		{"default", Fairness{}, false},
+		{"fifo ignores the key", Fairness{Policy: FairnessFIFO, Key: "anything"}, false},
+		{"round robin by tenant", Fairness{Policy: FairnessRoundRobin, Key: FairnessKeyTenant}, false},
+		{"weighted by header", Fairness{Policy: FairnessWeighted, Key: FairnessKeyHeader, Header: "X-Customer", Weights: map[string]int{"acme": 3}}, false},
+		{"unknown policy", Fairness{Policy: "lottery", Key: FairnessKeyTenant}, true},
+		{"missing key", Fairness{Policy: FairnessRoundRobin}, true},
+		{"unknown key", Fairness{Policy: FairnessRoundRobin, Key: "worker"}, true},
+		{"header key without header", Fairness{Policy: FairnessRoundRobin, Key: FairnessKeyHeader}, true},
+		{"zero weight", Fairness{Policy: FairnessWeighted, Key: FairnessKeyGroup, Weights: map[string]int{"a": 0}}, true},
+		{"weight too large", Fairness{Policy: FairnessWeighted, Key: FairnessKeyGroup, Weights: map[string]int{"a": maxFairnessWeight + 1}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fairness.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPopOrder(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+
+	tests := []struct {
+		name string
+		jobs []Message
+		want string
+	}{
+		{"queue order", []Message{{ID: "a"}, {ID: "b"}, {ID: "c"}}, "a,b,c"},
+		{"priority first", []Message{{ID: "low"}, {ID: "high", Priority: 5}, {ID: "mid", Priority: 1}}, "high,mid,low"},
+		{"equal priorities keep queue order", []Message{{ID: "a", Priority: 1}, {ID: "b"}, {ID: "c", Priority: 1}}, "a,c,b"},
+		{"negative priority last", []Message{{ID: "later", Priority: -1}, {ID: "a"}}, "a,later"},
+		{"scheduled jobs wait until due", []Message{{ID: "future", Priority: 9, ScheduledAt: &future}, {ID: "due", ScheduledAt: &past}, {ID: "a"}}, "due,a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestQueue(t)
+			pushJobs(t, q, tt.jobs...)
+			if got := drain(t, q, len(tt.jobs)); got != tt.want {
+				t.Errorf("popped %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFairShares(t *testing.T) {
+	tests := []struct {
+		name     string
+		fairness Fairness
+		jobs     string // Fairness key of each queued job, in order
+		pops     int
+		want     string // Fairness keys of the jobs handed out
+	}{
+		{
+			name:     "fifo ignores keys",
+			fairness: Fairness{Policy: FairnessFIFO},
+			jobs:     "aaaabbc",
+			pops:     7,
+			want:     "aaaabbc",
+		},
+		{
+			name:     "round robin by tenant",
+			fairness: Fairness{Policy: FairnessRoundRobin, Key: FairnessKeyTenant},
+			jobs:     "aaaabbc",
+			pops:     7,
+			want:     "abcabaa",
+		},
+		{
+			name:     "round robin by group",
+			fairness: Fairness{Policy: FairnessRoundRobin, Key: FairnessKeyGroup},
+			jobs:     "aab",
+			pops:     3,
+			want:     "ab", // Jobs of a group still wait for the one being processed
+		},
+		{
+			name:     "round robin by header",
+			fairness: Fairness{Policy: FairnessRoundRobin, Key: FairnessKeyHeader, Header: "x-customer"},
+			jobs:     "aaabbb",
+			pops:     6,
+			want:     "ababab",
+		},
+		{
+			name:     "weighted three to one",
+			fairness: Fairness{Policy: FairnessWeighted, Key: FairnessKeyTenant, Weights: map[string]int{"a": 3}},
+			jobs:     "aaaaaaaabbbbbbbb",
+			pops:     8,
+			want:     "abaaabaa",
+		},
+		{
+			name:     "round robin ignores weights",
+			fairness: Fairness{Policy: FairnessRoundRobin, Key: FairnessKeyTenant, Weights: map[string]int{"a": 3}},
+			jobs:     "aaaabbbb",
+			pops:     4,
+			want:     "abab",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestQueue(t)
+			if err := q.SetFairness(tt.fairness); err != nil {
+				t.Fatalf("SetFairness: %v", err)
+			}
+			for i, key := range tt.jobs {
+				pushJobs(t, q, keyedJob(fmt.Sprintf("%c%d", key, i), string(key), tt.fairness))
+			}
+
+			popped := strings.Split(drain(t, q, tt.pops), ",")
+			var got strings.Builder
+			for _, id := range popped {
+				if id != "" {
+					got.WriteByte(id[0])
+				}
+			}
+			if got.String() != tt.want {
+				t.Errorf("handed out %s, want %s", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestIdleKeyCannotClaimMissedTurns(t *testing.T) {
+	q := newTestQueue(t)
+	fairness := Fairness{Policy: FairnessRoundRobin, Key: FairnessKeyTenant}
+	if err := q.SetFairness(fairness); err != nil {
+		t.Fatalf("SetFairness: %v", err)
+	}
+	for i := range 6 {
+		pushJobs(t, q, keyedJob(fmt.Sprintf("a%d", i), "a", fairness))
+	}
+	if got := drain(t, q, 3); got != "a0,a1,a2" {
+		t.Fatalf("popped %s, want a0,a1,a2", got)
+	}
+
+	// b arrives after a had three turns alone and then shares evenly with it
+	for i := range 3 {
+		pushJobs(t, q, keyedJob(fmt.Sprintf("b%d", i), "b", fairness))
+	}
+	if got := drain(t, q, 4); got != "b0,a3,b1,a4" {
+		t.Errorf("popped %s, want b0,a3,b1,a4", got)
+	}
+}
+
+// keyedJob returns a job whose fairness key under the policy is key
+func keyedJob(id, key string, fairness Fairness) Message {
+	msg := Message{ID: id, Payload: []byte("x")}
+	switch fairness.Key {
+	case FairnessKeyGroup:
+		msg.Group = key
+	case FairnessKeyHeader:
+		msg.Headers = map[string]string{"X-Customer": key}
+	default:
+		msg.Tenant = key
+	}
+	return msg
+}
+
+// drain pops up to n jobs without finishing them and returns their IDs, comma separated
+func drain(t *testing.T, q *Queue, n int) string {
+	t.Helper()
+	var ids []string
+	for range n {
+		job, err := q.Pop("worker-1", JobFilter{})
+		if err != nil {
+			t.Fatalf("Pop: %v", err)
+		}
+		if job == nil {
+			break
+		}
+		ids = append(ids, job.ID)
+	}
+	return strings.Join(ids, ",")
+}
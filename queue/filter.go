@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// JobFilter selects jobs by their attributes, empty fields match everything
+type JobFilter struct {
+	Statuses      []JobStatus       `json:"statuses,omitempty"`
+	CreatedAfter  time.Time         `json:"created_after,omitempty"`
+	CreatedBefore time.Time         `json:"created_before,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	IDPrefix      string            `json:"id_prefix,omitempty"`
+	Tenant        string            `json:"tenant,omitempty"`
+	WorkerID      string            `json:"worker_id,omitempty"` // Worker holding or having completed the job
+	Group         string            `json:"group,omitempty"`
+	Capabilities  map[string]string `json:"-"` // Of the worker popping jobs, ignored by Matches
+}
+
+// Matches reports whether a job passes the filter
+func (f JobFilter) Matches(job *Message) bool {
+	if len(f.Statuses) > 0 && !containsValue(f.Statuses, job.Status) {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && job.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !job.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	if f.IDPrefix != "" && !strings.HasPrefix(job.ID, f.IDPrefix) {
+		return false
+	}
+	if f.Tenant != "" && job.Tenant != f.Tenant {
+		return false
+	}
+	if f.WorkerID != "" && job.WorkerID != f.WorkerID {
+		return false
+	}
+	if f.Group != "" && job.Group != f.Group {
+		return false
+	}
+	for name, value := range f.Headers {
+		if headerValue(job.Headers, name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// takes reports whether a worker popping with the filter may take the job: it matches and its requirements are met
+func (f JobFilter) takes(job *Message) bool {
+	return f.Matches(job) && job.Requirements.SatisfiedBy(f.Capabilities)
+}
+
+// JobCursor marks a position in the job listing order (creation time, then ID)
+type JobCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// before reports whether the job sorts at or before the cursor
+func (c *JobCursor) before(job *Message) bool {
+	if c == nil {
+		return false
+	}
+	if !job.CreatedAt.Equal(c.CreatedAt) {
+		return job.CreatedAt.Before(c.CreatedAt)
+	}
+	return job.ID <= c.ID
+}
+
+// sortJobs orders jobs by creation time, then ID
+func sortJobs(jobs []Message) {
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+}
+
+// headerValue looks up a header case-insensitively
+func headerValue(headers map[string]string, name string) string {
+	if value, ok := headers[name]; ok {
+		return value
+	}
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
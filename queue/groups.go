@@ -0,0 +1,26 @@
+package queue
+
+// groupKey identifies a message group, groups of different tenants are separate
+type groupKey struct {
+	tenant string
+	group  string
+}
+
+// groupKey returns the group of a message, false for messages outside any group
+func (m *Message) groupKey() (groupKey, bool) {
+	return groupKey{tenant: m.Tenant, group: m.Group}, m.Group != ""
+}
+
+// busyGroups returns the groups with a job being processed, their later jobs must wait for it
+func (jsm *JobStatusManager) busyGroups() map[groupKey]bool {
+	jsm.mutex.Lock()
+	defer jsm.mutex.Unlock()
+
+	busy := make(map[groupKey]bool)
+	for _, job := range jsm.statusMap {
+		if key, ok := job.groupKey(); ok && job.Status == JobStatusProcessing {
+			busy[key] = true
+		}
+	}
+	return busy
+}
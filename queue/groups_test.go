@@ -0,0 +1,129 @@
+package queue
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGroupOrder(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name string
+		jobs []Message
+		want []string // IDs handed out while no job is finished
+	}{
+		{
+			name: "one job of a group at a time",
+			jobs: []Message{{ID: "a1", Group: "a"}, {ID: "a2", Group: "a"}, {ID: "b1", Group: "b"}, {ID: "b2", Group: "b"}},
+			want: []string{"a1", "b1"},
+		},
+		{
+			name: "jobs without a group are not blocked",
+			jobs: []Message{{ID: "a1", Group: "a"}, {ID: "a2", Group: "a"}, {ID: "x"}, {ID: "y"}},
+			want: []string{"a1", "x", "y"},
+		},
+		{
+			name: "priority does not jump the group head",
+			jobs: []Message{{ID: "a1", Group: "a"}, {ID: "a2", Group: "a", Priority: 10}, {ID: "x", Priority: 5}},
+			want: []string{"x", "a1"},
+		},
+		{
+			name: "a head not yet due holds its group",
+			jobs: []Message{{ID: "a1", Group: "a", ScheduledAt: &later}, {ID: "a2", Group: "a"}, {ID: "b1", Group: "b"}},
+			want: []string{"b1"},
+		},
+		{
+			name: "groups of different tenants are separate",
+			jobs: []Message{{ID: "t1", Tenant: "one", Group: "g"}, {ID: "t2", Tenant: "two", Group: "g"}, {ID: "t1b", Tenant: "one", Group: "g"}},
+			want: []string{"t1", "t2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestQueue(t)
+			pushJobs(t, q, tt.jobs...)
+
+			var got []string
+			for {
+				job, err := q.Pop("worker-1", JobFilter{})
+				if err != nil {
+					t.Fatalf("Pop: %v", err)
+				}
+				if job == nil {
+					break
+				}
+				got = append(got, job.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("popped %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupWaitsForFinishedJob(t *testing.T) {
+	tests := []struct {
+		name   string
+		finish func(q *Queue, job *Message) error
+	}{
+		{"completed", func(q *Queue, job *Message) error {
+			return q.Complete(job.ID, job.LeaseToken, JobResult{})
+		}},
+		{"failed", func(q *Queue, job *Message) error {
+			return q.Fail(job.ID, job.LeaseToken, "broken")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestQueue(t)
+			pushJobs(t, q, Message{ID: "a1", Group: "a"}, Message{ID: "a2", Group: "a"})
+			first := popJob(t, q, "worker-1", JobFilter{})
+
+			if job, _ := q.Pop("worker-2", JobFilter{}); job != nil {
+				t.Fatalf("Pop = %s while %s is processing, want none", job.ID, first.ID)
+			}
+			if err := tt.finish(q, first); err != nil {
+				t.Fatalf("finish: %v", err)
+			}
+			if next := popJob(t, q, "worker-2", JobFilter{}); next.ID != "a2" {
+				t.Errorf("Pop = %s, want a2", next.ID)
+			}
+		})
+	}
+}
+
+func TestGroupJobKeepsPlaceWhenReturned(t *testing.T) {
+	tests := []struct {
+		name     string
+		giveBack func(q *Queue, job *Message) error
+	}{
+		{"released", func(q *Queue, job *Message) error {
+			return q.Release(job.ID, job.LeaseToken)
+		}},
+		{"requeued", func(q *Queue, job *Message) error {
+			_, err := q.Requeue(job.ID)
+			return err
+		}},
+		{"lease expired", func(q *Queue, job *Message) error {
+			_, err := q.ReleaseExpired(job.LeaseExpiresAt.Add(time.Second))
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestQueue(t)
+			q.SetLeaseTimeout(time.Minute)
+			pushJobs(t, q, Message{ID: "a1", Group: "a"}, Message{ID: "a2", Group: "a"})
+			first := popJob(t, q, "worker-1", JobFilter{})
+
+			if err := tt.giveBack(q, first); err != nil {
+				t.Fatalf("give back: %v", err)
+			}
+			if next := popJob(t, q, "worker-2", JobFilter{}); next.ID != "a1" {
+				t.Errorf("Pop = %s, want a1 ahead of the rest of its group", next.ID)
+			}
+		})
+	}
+}
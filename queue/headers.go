@@ -0,0 +1,204 @@
+package queue
+
+import (
+	"errors"
+	"net/textproto"
+	"slices"
+	"strings"
+)
+
+// RedactedValue replaces the value of redacted headers
+const RedactedValue = "[REDACTED]"
+
+// HeaderRules controls which request headers are captured with a job
+type HeaderRules struct {
+	Allow  []string          `json:"allow,omitempty"`  // Only keep these headers (all when empty)
+	Deny   []string          `json:"deny,omitempty"`   // Drop these headers
+	Rename map[string]string `json:"rename,omitempty"` // Rename headers from key to value
+	Redact []string          `json:"redact,omitempty"` // Keep these headers but hide their values
+}
+
+// NewHeaderRules builds header rules from lists, rename entries use the "From=To" format
+// Empty names and malformed rename entries are skipped
+func NewHeaderRules(allow, deny, rename, redact []string) *HeaderRules {
+	rules := HeaderRules{
+		Allow:  nonEmpty(allow),
+		Deny:   nonEmpty(deny),
+		Rename: make(map[string]string),
+		Redact: nonEmpty(redact),
+	}
+	for _, entry := range rename {
+		from, to, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(from) == "" || strings.TrimSpace(to) == "" {
+			continue
+		}
+		rules.Rename[from] = to
+	}
+	return rules.canonical()
+}
+
+// Validate checks that every rule names a header
+func (r HeaderRules) Validate() error {
+	for _, names := range [][]string{r.Allow, r.Deny, r.Redact} {
+		for _, name := range names {
+			if strings.TrimSpace(name) == "" {
+				return errors.New("header names must not be empty")
+			}
+		}
+	}
+	for from, to := range r.Rename {
+		if strings.TrimSpace(from) == "" || strings.TrimSpace(to) == "" {
+			return errors.New("rename entries need a header name on both sides")
+		}
+	}
+	return nil
+}
+
+// canonical returns a copy of the rules with every header name in canonical form
+func (r HeaderRules) canonical() *HeaderRules {
+	rules := &HeaderRules{
+		Allow:  canonicalNames(r.Allow),
+		Deny:   canonicalNames(r.Deny),
+		Rename: make(map[string]string, len(r.Rename)),
+		Redact: canonicalNames(r.Redact),
+	}
+	for from, to := range r.Rename {
+		rules.Rename[canonicalName(from)] = canonicalName(to)
+	}
+	return rules
+}
+
+// SetDefaultHeaderRules sets the rules used while the queue has none of its own, from the environment
+// Their redactions also apply on top of the queue's own rules
+func (q *Queue) SetDefaultHeaderRules(rules *HeaderRules) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.headers = rules
+}
+
+// SetHeaderRules replaces the header rules of the queue and persists them, nil goes back to the defaults
+// Jobs already captured keep their headers
+func (q *Queue) SetHeaderRules(rules *HeaderRules) error {
+	if rules != nil {
+		if err := rules.Validate(); err != nil {
+			return err
+		}
+		rules = rules.canonical()
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	previous := q.settings.Headers
+	q.settings.Headers = rules
+	if err := q.storage.SaveState(settingsKey, q.settings); err != nil {
+		q.settings.Headers = previous
+		return err
+	}
+	return nil
+}
+
+// GetHeaderRules returns the rules applied to headers captured with new jobs, nil when there are none
+func (q *Queue) GetHeaderRules() *HeaderRules {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.headerRulesLocked()
+}
+
+// headerRulesLocked returns the rules of the queue, or the defaults when it has none
+// Headers redacted by default stay redacted under the queue's own rules, must be called with the lock held
+func (q *Queue) headerRulesLocked() *HeaderRules {
+	own := q.settings.Headers
+	if own == nil {
+		return q.headers
+	}
+	if q.headers == nil {
+		return own
+	}
+
+	rules := *own
+	rules.Redact = slices.Clone(own.Redact)
+	for _, name := range q.headers.Redact {
+		if !containsValue(rules.Redact, name) {
+			rules.Redact = append(rules.Redact, name)
+		}
+	}
+	return &rules
+}
+
+// Apply returns the headers that should be captured, with the rules applied
+func (r *HeaderRules) Apply(headers map[string]string) map[string]string {
+	if r == nil || headers == nil {
+		return headers
+	}
+
+	result := make(map[string]string, len(headers))
+	for key, value := range headers {
+		name := canonicalName(key)
+		if containsValue(r.Deny, name) {
+			continue
+		}
+		if len(r.Allow) > 0 && !containsValue(r.Allow, name) {
+			continue
+		}
+		if containsValue(r.Redact, name) {
+			value = RedactedValue
+		}
+		if renamed, ok := r.Rename[name]; ok {
+			name = renamed
+		}
+		result[name] = value
+	}
+	return result
+}
+
+// Redacted returns a copy of the headers with redacted values hidden
+// Used for logs and admin views of jobs captured before the rules changed
+func (r *HeaderRules) Redacted(headers map[string]string) map[string]string {
+	if r == nil || len(r.Redact) == 0 || headers == nil {
+		return headers
+	}
+
+	result := make(map[string]string, len(headers))
+	for key, value := range headers {
+		name := canonicalName(key)
+		if containsValue(r.Redact, name) || containsValue(r.Redact, r.originalName(name)) {
+			value = RedactedValue
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// originalName returns the header name that was renamed to name, if any
+func (r *HeaderRules) originalName(name string) string {
+	for from, to := range r.Rename {
+		if to == name {
+			return from
+		}
+	}
+	return name
+}
+
+func canonicalName(name string) string {
+	return textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+}
+
+func canonicalNames(names []string) []string {
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		result = append(result, canonicalName(name))
+	}
+	return result
+}
+
+// nonEmpty drops blank names from a list read from the environment
+func nonEmpty(names []string) []string {
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		if strings.TrimSpace(name) != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
@@ -0,0 +1,58 @@
+package queue
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestQueueHeaderRulesKeepDefaultRedactions(t *testing.T) {
+	defaults := NewHeaderRules(nil, nil, nil, []string{"Authorization", "Cookie"})
+	headers := map[string]string{
+		"Authorization": "Bearer secret",
+		"Cookie":        "session=secret",
+		"X-Trace-Id":    "abc",
+		"X-Tenant-Key":  "key",
+	}
+
+	tests := []struct {
+		name  string
+		rules *HeaderRules
+		want  map[string]string
+	}{
+		{
+			name:  "defaults",
+			rules: nil,
+			want:  map[string]string{"Authorization": RedactedValue, "Cookie": RedactedValue, "X-Trace-Id": "abc", "X-Tenant-Key": "key"},
+		},
+		{
+			name:  "queue rules without redact",
+			rules: &HeaderRules{Rename: map[string]string{"x-trace-id": "X-Request-Id"}},
+			want:  map[string]string{"Authorization": RedactedValue, "Cookie": RedactedValue, "X-Request-Id": "abc", "X-Tenant-Key": "key"},
+		},
+		{
+			name:  "queue rules with redact",
+			rules: &HeaderRules{Redact: []string{"x-tenant-key"}},
+			want:  map[string]string{"Authorization": RedactedValue, "Cookie": RedactedValue, "X-Trace-Id": "abc", "X-Tenant-Key": RedactedValue},
+		},
+		{
+			name:  "queue rules denying a redacted header",
+			rules: &HeaderRules{Deny: []string{"cookie"}},
+			want:  map[string]string{"Authorization": RedactedValue, "X-Trace-Id": "abc", "X-Tenant-Key": "key"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestQueue(t)
+			q.SetDefaultHeaderRules(defaults)
+			if err := q.SetHeaderRules(tt.rules); err != nil {
+				t.Fatalf("SetHeaderRules: %v", err)
+			}
+			pushJobs(t, q, Message{ID: "job", Payload: []byte("x"), Headers: maps.Clone(headers)})
+
+			job, _ := q.GetStatusManager().GetJobStatus("job")
+			if !maps.Equal(job.Headers, tt.want) {
+				t.Errorf("headers = %v, want %v", job.Headers, tt.want)
+			}
+		})
+	}
+}
@@ -2,24 +2,43 @@ package queue
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
 
+var (
+	// ErrJobNotFound is returned for unknown job IDs
+	ErrJobNotFound = errors.New("job not found")
+	// ErrInvalidJobState is returned when an operation does not apply to the job's current status
+	ErrInvalidJobState = errors.New("operation not allowed in the job's current state")
+	// ErrJobCancelled is returned to submitters waiting on a job that was deleted, cleared or purged
+	ErrJobCancelled = errors.New("job was cancelled before completion")
+	// ErrJobFailed is returned to submitters waiting on a job its worker reported as failed
+	ErrJobFailed = errors.New("worker failed the job")
+)
+
 // JobStatusManager handles job status tracking throughout the job lifecycle
 type JobStatusManager struct {
 	statusMap map[string]Message // Map job ID to job with current status
 	waiters   map[string]chan Message
+	persisted map[string]time.Time // Lease expiry of processing jobs as last written to storage
+	removed   removedJobs          // Jobs cancelled by removal, for submitters that start waiting late
 	storage   *Storage
+	events    *EventBus
 	mutex     sync.Mutex
+	listeners []func(Message) // Called when a job reaches a terminal state
 }
 
-// NewJobStatusManager creates a new job status manager
-func NewJobStatusManager(storage *Storage) (*JobStatusManager, error) {
+// NewJobStatusManager creates a new job status manager publishing to the given event bus
+func NewJobStatusManager(storage *Storage, events *EventBus) (*JobStatusManager, error) {
 	jsm := &JobStatusManager{
 		statusMap: make(map[string]Message),
 		waiters:   make(map[string]chan Message),
+		persisted: make(map[string]time.Time),
+		removed:   removedJobs{jobs: make(map[string]Message)},
 		storage:   storage,
+		events:    events,
 		mutex:     sync.Mutex{},
 	}
 
@@ -33,27 +52,53 @@ func NewJobStatusManager(storage *Storage) (*JobStatusManager, error) {
 	return jsm, nil
 }
 
+// OnTerminal registers a listener called whenever a job reaches a terminal state
+// Listeners run outside the manager lock and must not block
+func (jsm *JobStatusManager) OnTerminal(listener func(Message)) {
+	jsm.mutex.Lock()
+	defer jsm.mutex.Unlock()
+	jsm.listeners = append(jsm.listeners, listener)
+}
+
+// notifyTerminal calls the terminal state listeners, must be called without holding the lock
+func (jsm *JobStatusManager) notifyTerminal(job Message, listeners []func(Message)) {
+	for _, listener := range listeners {
+		listener(job)
+	}
+}
+
 // RegisterJob registers a new job for status tracking
-func (jsm *JobStatusManager) RegisterJob(job *Message) {
+func (jsm *JobStatusManager) RegisterJob(job *Message) error {
 	jsm.mutex.Lock()
 	defer jsm.mutex.Unlock()
 
 	// Create a waiter channel for the job
 	jsm.waiters[job.ID] = make(chan Message, 1)
 
-	// Store initial status
+	// Store initial status, forgetting the job again when it cannot be saved
 	jsm.statusMap[job.ID] = *job
+	if err := jsm.storage.SaveJobStatus(jsm.statusMap); err != nil {
+		delete(jsm.statusMap, job.ID)
+		delete(jsm.waiters, job.ID)
+		return err
+	}
+	return nil
 }
 
 // UpdateStatus updates a job's status
 func (jsm *JobStatusManager) UpdateStatus(jobID string, status JobStatus) error {
+	return jsm.updateStatus(jobID, status, EventJobStatus)
+}
+
+// updateStatus updates a job's status and publishes the given event type
+func (jsm *JobStatusManager) updateStatus(jobID string, status JobStatus, eventType EventType) error {
 	jsm.mutex.Lock()
 	defer jsm.mutex.Unlock()
 
 	// Find if the job exists
 	job, exists := jsm.statusMap[jobID]
 	if !exists {
-		return errors.New("job not found")
+		return ErrJobNotFound
 	}
 
 	// Update status
@@ -62,31 +107,194 @@ func (jsm *JobStatusManager) UpdateStatus(jobID string, status JobStatus) error
 
 	// Store the updated job
 	jsm.statusMap[jobID] = job
+	jsm.events.Publish(eventType, &job)
 
 	return jsm.storage.SaveJobStatus(jsm.statusMap)
 }
 
-// SubmitResult stores the result of a processed job
-func (jsm *JobStatusManager) SubmitResult(jobID string, payload string, err error) error {
+// Requeue resets a job to pending so it can be processed again
+// The waiter of a submitter that is still waiting is kept, a new one is created otherwise
+func (jsm *JobStatusManager) Requeue(jobID string) (*Message, error) {
 	jsm.mutex.Lock()
 	defer jsm.mutex.Unlock()
 
-	// Find if the job exists in the status map
 	job, exists := jsm.statusMap[jobID]
 	if !exists {
-		// It's possible the job was just processed but not in statusMap
-		// Let's create a new status entry
-		now := time.Now()
-		job = Message{
-			ID:          jobID,
-			UpdatedAt:   now,
-			CompletedAt: &now,
+		return nil, ErrJobNotFound
+	}
+
+	job.Status = JobStatusPending
+	job.Progress = nil
+	job.LeaseExpiresAt = nil
+	job.LeaseToken = ""
+	job.WorkerID = ""
+	job.Result = nil
+	job.ResultContentType = ""
+	job.ResultStatusCode = 0
+	job.ResultHeaders = nil
+	job.ResultSchemaErrors = nil
+	job.Error = ""
+	job.CompletedAt = nil
+	job.UpdatedAt = time.Now()
+
+	if _, ok := jsm.waiters[jobID]; !ok {
+		jsm.waiters[jobID] = make(chan Message, 1)
+	}
+	jsm.statusMap[jobID] = job
+	jsm.events.Publish(EventJobQueued, &job)
+
+	return &job, jsm.storage.SaveJobStatus(jsm.statusMap)
+}
+
+// UpdateJob applies a change to a tracked job and persists it
+func (jsm *JobStatusManager) UpdateJob(jobID string, update func(job *Message)) error {
+	jsm.mutex.Lock()
+	defer jsm.mutex.Unlock()
+
+	job, exists := jsm.statusMap[jobID]
+	if !exists {
+		return ErrJobNotFound
+	}
+
+	update(&job)
+	job.UpdatedAt = time.Now()
+	jsm.statusMap[jobID] = job
+
+	return jsm.storage.SaveJobStatus(jsm.statusMap)
+}
+
+// Remove stops tracking a job, a submitter still waiting on it gets ErrJobCancelled
+func (jsm *JobStatusManager) Remove(jobID string) error {
+	jsm.mutex.Lock()
+
+	job, exists := jsm.statusMap[jobID]
+	if !exists {
+		jsm.mutex.Unlock()
+		return ErrJobNotFound
+	}
+
+	cancelled, wasCancelled := jsm.cancelLocked(job)
+	saveErr := jsm.storage.SaveJobStatus(jsm.statusMap)
+	listeners := jsm.listeners
+	jsm.mutex.Unlock()
+
+	if wasCancelled {
+		jsm.notifyTerminal(cancelled, listeners)
+	}
+	return saveErr
+}
+
+// Purge stops tracking every job matching the filter and returns them in creation order
+// Unfinished jobs are cancelled and their waiters resolved; a dry run only reports the matches
+func (jsm *JobStatusManager) Purge(filter JobFilter, dryRun bool) ([]Message, error) {
+	jsm.mutex.Lock()
+
+	matched := make([]Message, 0)
+	for _, job := range jsm.statusMap {
+		if filter.Matches(&job) {
+			matched = append(matched, job)
 		}
 	}
+	sortJobs(matched)
+
+	if dryRun {
+		jsm.mutex.Unlock()
+		return matched, nil
+	}
+
+	var cancelled []Message
+	for _, job := range matched {
+		if job, ok := jsm.cancelLocked(job); ok {
+			cancelled = append(cancelled, job)
+		}
+	}
+
+	saveErr := jsm.storage.SaveJobStatus(jsm.statusMap)
+	listeners := jsm.listeners
+	jsm.mutex.Unlock()
+
+	for _, job := range cancelled {
+		jsm.notifyTerminal(job, listeners)
+	}
+	return matched, saveErr
+}
+
+// cancelLocked stops tracking a job and reports whether it was cancelled
+// Jobs that did not complete yet get the cancelled status, which resolves their waiter
+// and publishes an event; must be called with the lock held
+func (jsm *JobStatusManager) cancelLocked(job Message) (Message, bool) {
+	delete(jsm.statusMap, job.ID)
+	if job.Status.IsTerminal() {
+		return job, false
+	}
 
-	// Update with payload
-	job.Result = payload
 	now := time.Now()
+	job.Status = JobStatusCancelled
+	job.Error = ErrJobCancelled.Error()
+	job.UpdatedAt = now
+	job.CompletedAt = &now
+
+	if waiter, ok := jsm.waiters[job.ID]; ok {
+		select {
+		case waiter <- job:
+		default:
+		}
+		delete(jsm.waiters, job.ID)
+	}
+	jsm.events.Publish(EventJobCancelled, &job)
+	jsm.removed.add(job)
+
+	return job, true
+}
+
+// removedHistorySize is the number of cancelled jobs remembered after their removal
+const removedHistorySize = 1000
+
+// removedJobs remembers the outcome of the last jobs cancelled by their removal, oldest first
+type removedJobs struct {
+	jobs  map[string]Message
+	order []string
+}
+
+func (r *removedJobs) add(job Message) {
+	if len(r.order) == removedHistorySize {
+		delete(r.jobs, r.order[0])
+		r.order = r.order[1:]
+	}
+	// Waits only need the outcome, the payload is not kept
+	r.jobs[job.ID] = Message{ID: job.ID, Status: job.Status, Error: job.Error}
+	r.order = append(r.order, job.ID)
+}
+
+func (r *removedJobs) get(jobID string) (Message, bool) {
+	job, ok := r.jobs[jobID]
+	return job, ok
+}
+
+// SubmitResult stores the result of a job processed under the lease identified by leaseToken
+// Unknown jobs, and jobs whose lease expired or was given to another worker, are rejected
+func (jsm *JobStatusManager) SubmitResult(jobID string, leaseToken string, result JobResult, err error) error {
+	jsm.mutex.Lock()
+
+	job, exists := jsm.statusMap[jobID]
+	if !exists {
+		jsm.mutex.Unlock()
+		return ErrJobNotFound
+	}
+	now := time.Now()
+	if leaseErr := checkLease(&job, leaseToken, now); leaseErr != nil {
+		jsm.mutex.Unlock()
+		return leaseErr
+	}
+
+	// Update with payload
+	job.Result = result.Payload
+	job.ResultContentType = result.ContentType
+	job.ResultStatusCode = result.StatusCode
+	job.ResultHeaders = result.Headers
+	job.ResultSchemaErrors = result.SchemaErrors
+	job.LeaseExpiresAt = nil
+	job.LeaseToken = ""
 	job.UpdatedAt = now
 	job.CompletedAt = &now
 
@@ -100,6 +308,7 @@ func (jsm *JobStatusManager) SubmitResult(jobID string, payload string, err erro
 
 	// Store the updated job
 	jsm.statusMap[jobID] = job
+	jsm.events.Publish(EventJobCompleted, &job)
 
 	// Notify any waiters for this job
 	if waiter, ok := jsm.waiters[jobID]; ok {
@@ -116,34 +325,25 @@ func (jsm *JobStatusManager) SubmitResult(jobID string, payload string, err erro
 	}
 
 	// Save job status to storage
-	return jsm.storage.SaveJobStatus(jsm.statusMap)
+	saveErr := jsm.storage.SaveJobStatus(jsm.statusMap)
+	listeners := jsm.listeners
+	jsm.mutex.Unlock()
+
+	jsm.notifyTerminal(job, listeners)
+	return saveErr
 }
 
 // WaitForCompletion waits for a job to reach completion with a timeout
 func (jsm *JobStatusManager) WaitForCompletion(jobID string, timeout time.Duration) (*Message, error) {
-	jsm.mutex.Lock()
-
-	// Check if we already have the job in completed/failed state
-	if job, exists := jsm.statusMap[jobID]; exists {
-		if job.Status == JobStatusCompleted {
-			jsm.mutex.Unlock()
-			return &job, nil
-		}
-	}
-
-	// Get the waiter channel for this job
-	waiter, exists := jsm.waiters[jobID]
-	if !exists {
-		jsm.mutex.Unlock()
-		return nil, errors.New("job not found")
+	waiter, job, err := jsm.waitFor(jobID)
+	if waiter == nil {
+		return job, err
 	}
 
-	jsm.mutex.Unlock()
-
 	// Wait for the job to complete with timeout
 	select {
 	case job := <-waiter:
-		return &job, nil
+		return waitResult(job)
 	case <-time.After(timeout):
 		return nil, errors.New("timeout waiting for job completion")
 	}
@@ -151,27 +351,43 @@ func (jsm *JobStatusManager) WaitForCompletion(jobID string, timeout time.Durati
 
 // WaitForCompletionWithoutTimeout waits indefinitely for a job to reach completion
 func (jsm *JobStatusManager) WaitForCompletionWithoutTimeout(jobID string) (*Message, error) {
+	waiter, job, err := jsm.waitFor(jobID)
+	if waiter == nil {
+		return job, err
+	}
+
+	// Wait indefinitely for the job to complete
+	return waitResult(<-waiter)
+}
+
+// waitFor returns the channel resolved when a job finishes, or the outcome of a job that already finished
+// Jobs removed before they finished are reported cancelled for as long as they are remembered
+func (jsm *JobStatusManager) waitFor(jobID string) (chan Message, *Message, error) {
 	jsm.mutex.Lock()
+	defer jsm.mutex.Unlock()
 
-	// Check if we already have the job in completed/failed state
-	if job, exists := jsm.statusMap[jobID]; exists {
-		if job.Status == JobStatusCompleted {
-			jsm.mutex.Unlock()
-			return &job, nil
-		}
+	if job, exists := jsm.statusMap[jobID]; exists && job.Status.IsTerminal() {
+		result, err := waitResult(job)
+		return nil, result, err
 	}
-
-	// Get the waiter channel for this job
-	waiter, exists := jsm.waiters[jobID]
-	if !exists {
-		jsm.mutex.Unlock()
-		return nil, errors.New("job not found")
+	if waiter, exists := jsm.waiters[jobID]; exists {
+		return waiter, nil, nil
 	}
+	if job, removed := jsm.removed.get(jobID); removed {
+		result, err := waitResult(job)
+		return nil, result, err
+	}
+	return nil, nil, ErrJobNotFound
+}
 
-	jsm.mutex.Unlock()
-
-	// Wait indefinitely for the job to complete
-	job := <-waiter
+// waitResult converts the terminal state of a job into the outcome of a wait
+func waitResult(job Message) (*Message, error) {
+	switch {
+	case job.Status == JobStatusCancelled:
+		return nil, ErrJobCancelled
+	case job.Error != "":
+		return nil, fmt.Errorf("%w: %s", ErrJobFailed, job.Error)
+	}
 	return &job, nil
 }
 
@@ -182,12 +398,65 @@ func (jsm *JobStatusManager) GetJobStatus(jobID string) (*Message, error) {
 
 	job, exists := jsm.statusMap[jobID]
 	if !exists {
-		return nil, errors.New("job not found")
+		return nil, ErrJobNotFound
 	}
 
 	return &job, nil
 }
 
+// ListJobs returns up to limit jobs matching the filter, ordered by creation time
+// Listing starts after the cursor when one is given, and the returned cursor is nil on the last page
+func (jsm *JobStatusManager) ListJobs(filter JobFilter, after *JobCursor, limit int) ([]Message, *JobCursor) {
+	jsm.mutex.Lock()
+	jobs := make([]Message, 0)
+	for _, job := range jsm.statusMap {
+		if after.before(&job) || !filter.Matches(&job) {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	jsm.mutex.Unlock()
+
+	sortJobs(jobs)
+	if len(jobs) <= limit {
+		return jobs, nil
+	}
+
+	jobs = jobs[:limit]
+	last := jobs[len(jobs)-1]
+	return jobs, &JobCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+}
+
+// CountJobs returns the number of tracked jobs matching the filter by status
+func (jsm *JobStatusManager) CountJobs(filter JobFilter) map[JobStatus]int {
+	jsm.mutex.Lock()
+	defer jsm.mutex.Unlock()
+	counts := make(map[JobStatus]int)
+	for _, job := range jsm.statusMap {
+		if filter.Matches(&job) {
+			counts[job.Status]++
+		}
+	}
+	return counts
+}
+
+// TenantUsage returns the pending jobs and stored payload and result bytes of a tenant
+func (jsm *JobStatusManager) TenantUsage(tenant string) TenantUsage {
+	jsm.mutex.Lock()
+	defer jsm.mutex.Unlock()
+	var usage TenantUsage
+	for _, job := range jsm.statusMap {
+		if job.Tenant != tenant {
+			continue
+		}
+		if job.Status == JobStatusPending {
+			usage.PendingJobs++
+		}
+		usage.StoredBytes += int64(len(job.Payload) + len(job.Result))
+	}
+	return usage
+}
+
 // Count returns the number of tracked jobs
 func (jsm *JobStatusManager) CountTotalJobs() int {
 	jsm.mutex.Lock()
@@ -231,11 +500,8 @@ func (jsm *JobStatusManager) CountCompletedJobs() int {
 	return count
 }
 
-// Clear removes all job status records
+// Clear removes all job status records, cancelling the jobs that did not complete
 func (jsm *JobStatusManager) Clear() error {
-	jsm.mutex.Lock()
-	defer jsm.mutex.Unlock()
-
-	jsm.statusMap = make(map[string]Message)
-	return jsm.storage.SaveJobStatus(jsm.statusMap)
+	_, err := jsm.Purge(JobFilter{}, false)
+	return err
 }
@@ -0,0 +1,185 @@
+package queue
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"time"
+)
+
+var (
+	// ErrLeaseTokenRequired is returned when a worker call does not carry the job's lease token
+	ErrLeaseTokenRequired = errors.New("lease token is required")
+	// ErrLeaseExpired is returned when the lease ran out, the job is or will be handed to another worker
+	ErrLeaseExpired = errors.New("lease expired, the job went back to the queue")
+	// ErrLeaseMismatch is returned for a token of an earlier lease, the job was handed to another worker since
+	ErrLeaseMismatch = errors.New("lease token does not match, the job was leased to another worker")
+)
+
+// SetLeaseTimeout sets how long a worker holds a job without a heartbeat, zero never expires leases
+// Only jobs taken afterwards are affected
+func (q *Queue) SetLeaseTimeout(timeout time.Duration) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.leaseTimeout = timeout
+}
+
+// ExtendLease renews the lease identified by leaseToken, recording the worker's progress when it is not nil
+func (q *Queue) ExtendLease(jobID string, leaseToken string, progress *Progress) (*Message, error) {
+	if progress != nil {
+		if err := progress.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	now := time.Now()
+	expiresAt := q.leaseExpiryLocked(now)
+	job, err := q.statusMgr.heartbeat(jobID, leaseToken, expiresAt, progress)
+	if err != nil {
+		return nil, err
+	}
+	q.touchWorkerLocked(job.WorkerID, now)
+	if expiresAt != nil {
+		q.leases[jobID] = *expiresAt
+	}
+	return job, nil
+}
+
+// Release puts a job leased with leaseToken back in the queue, for workers that stopped before finishing it
+// Jobs under another lease or no longer processing are left alone and the lease error is returned
+func (q *Queue) Release(jobID string, leaseToken string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	job, err := q.statusMgr.GetJobStatus(jobID)
+	if err != nil {
+		return err
+	}
+	if err := checkLease(job, leaseToken, time.Now()); err != nil {
+		return err
+	}
+	if err := q.releaseLocked(jobID); err != nil {
+		return err
+	}
+	if err := q.storage.SaveQueue(q.messages); err != nil {
+		return err
+	}
+	return q.dispatchLocked()
+}
+
+// ReleaseExpired puts processing jobs whose lease expired before now back in the queue
+// It returns the IDs of the released jobs
+func (q *Queue) ReleaseExpired(now time.Time) ([]string, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var released []string
+	for jobID, expiresAt := range q.leases {
+		if expiresAt.After(now) {
+			continue
+		}
+		delete(q.leases, jobID)
+
+		// Leases of jobs completed or removed since are simply forgotten
+		if err := q.releaseLocked(jobID); err == nil {
+			released = append(released, jobID)
+		}
+	}
+	if len(released) == 0 {
+		return nil, nil
+	}
+
+	if err := q.storage.SaveQueue(q.messages); err != nil {
+		return released, err
+	}
+	return released, q.dispatchLocked()
+}
+
+// RunLeaseExpiry releases jobs with expired leases every interval, it never returns
+func (q *Queue) RunLeaseExpiry(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		released, err := q.ReleaseExpired(now)
+		if len(released) > 0 {
+			log.Printf("Released %d jobs with expired leases: %v", len(released), released)
+		}
+		if err != nil {
+			log.Printf("Failed to save released jobs: %v", err)
+		}
+	}
+}
+
+// releaseLocked requeues a processing job in its original place, must be called with the lock held
+func (q *Queue) releaseLocked(jobID string) error {
+	job, err := q.statusMgr.GetJobStatus(jobID)
+	if err != nil {
+		return err
+	}
+	if job.Status != JobStatusProcessing {
+		return ErrInvalidJobState
+	}
+
+	job, err = q.statusMgr.Requeue(jobID)
+	if err != nil {
+		return err
+	}
+	delete(q.leases, jobID)
+	q.insertLocked(*job)
+	return nil
+}
+
+// insertLocked puts a job back in the queue ahead of later submissions, so it keeps its place
+// and stays ahead of the later jobs of its group; must be called with the lock held
+func (q *Queue) insertLocked(job Message) {
+	index := len(q.messages)
+	for i := range q.messages {
+		if q.messages[i].CreatedAt.After(job.CreatedAt) {
+			index = i
+			break
+		}
+	}
+	q.messages = slices.Insert(q.messages, index, job)
+}
+
+// checkLease verifies that leaseToken identifies the current, unexpired lease of a job
+// Finished jobs get ErrInvalidJobState
+func checkLease(job *Message, leaseToken string, now time.Time) error {
+	switch {
+	case leaseToken == "":
+		return ErrLeaseTokenRequired
+	case job.Status == JobStatusPending:
+		return ErrLeaseExpired
+	case job.Status != JobStatusProcessing:
+		return ErrInvalidJobState
+	case job.LeaseToken != leaseToken:
+		return ErrLeaseMismatch
+	case job.LeaseExpiresAt != nil && !job.LeaseExpiresAt.After(now):
+		// Expired leases are released shortly, the job must not be finished in the meantime
+		return ErrLeaseExpired
+	}
+	return nil
+}
+
+// leaseExpiryLocked returns when a lease taken or renewed now expires, nil when leases never expire
+// Must be called with the lock held
+func (q *Queue) leaseExpiryLocked(now time.Time) *time.Time {
+	if q.leaseTimeout <= 0 {
+		return nil
+	}
+	expiresAt := now.Add(q.leaseTimeout)
+	return &expiresAt
+}
+
+// loadLeases restores the leases of jobs that were processing when the queue was stopped
+func (q *Queue) loadLeases() {
+	processing, _ := q.statusMgr.ListJobs(JobFilter{Statuses: []JobStatus{JobStatusProcessing}}, nil, q.statusMgr.CountTotalJobs())
+	for _, job := range processing {
+		if job.LeaseExpiresAt != nil {
+			q.leases[job.ID] = *job.LeaseExpiresAt
+		}
+	}
+}
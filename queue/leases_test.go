@@ -0,0 +1,271 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestQueue creates a queue stored in a temporary directory
+func newTestQueue(t *testing.T) *Queue {
+	t.Helper()
+	q, err := NewQueue("test", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+	return q
+}
+
+// pushJobs adds jobs to the queue, in order
+func pushJobs(t *testing.T, q *Queue, jobs ...Message) {
+	t.Helper()
+	for _, job := range jobs {
+		if err := q.Push(job); err != nil {
+			t.Fatalf("Push %s: %v", job.ID, err)
+		}
+	}
+}
+
+// popJob takes the next job for workerID and fails the test when there is none
+func popJob(t *testing.T, q *Queue, workerID string, filter JobFilter) *Message {
+	t.Helper()
+	job, err := q.Pop(workerID, filter)
+	if err != nil {
+		t.Fatalf("Pop: %v", err)
+	}
+	if job == nil {
+		t.Fatal("Pop returned no job")
+	}
+	return job
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	tests := []struct {
+		name         string
+		timeout      time.Duration
+		after        time.Duration
+		wantReleased bool
+	}{
+		{"never expires", 0, 24 * time.Hour, false},
+		{"within the lease", time.Minute, 30 * time.Second, false},
+		{"after the lease", time.Minute, 2 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestQueue(t)
+			q.SetLeaseTimeout(tt.timeout)
+			pushJobs(t, q, Message{ID: "job", Payload: []byte("x")})
+			job := popJob(t, q, "worker-1", JobFilter{})
+			if (job.LeaseExpiresAt != nil) != (tt.timeout > 0) {
+				t.Fatalf("lease expires at %v with timeout %v", job.LeaseExpiresAt, tt.timeout)
+			}
+
+			released, err := q.ReleaseExpired(time.Now().Add(tt.after))
+			if err != nil {
+				t.Fatalf("ReleaseExpired: %v", err)
+			}
+			if (len(released) == 1) != tt.wantReleased {
+				t.Fatalf("ReleaseExpired = %v, want released %v", released, tt.wantReleased)
+			}
+
+			status, _ := q.GetStatusManager().GetJobStatus(job.ID)
+			wantStatus := JobStatusProcessing
+			if tt.wantReleased {
+				wantStatus = JobStatusPending
+			}
+			if status.Status != wantStatus {
+				t.Errorf("status = %s, want %s", status.Status, wantStatus)
+			}
+			if tt.wantReleased {
+				if retry := popJob(t, q, "worker-1", JobFilter{}); retry.ID != job.ID {
+					t.Errorf("Pop after release = %s, want %s", retry.ID, job.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckLease(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Second), now.Add(time.Minute)
+
+	tests := []struct {
+		name  string
+		job   Message
+		token string
+		want  error
+	}{
+		{"valid", Message{Status: JobStatusProcessing, LeaseToken: "a", LeaseExpiresAt: &future}, "a", nil},
+		{"never expires", Message{Status: JobStatusProcessing, LeaseToken: "a"}, "a", nil},
+		{"missing token", Message{Status: JobStatusProcessing, LeaseToken: "a"}, "", ErrLeaseTokenRequired},
+		{"other token", Message{Status: JobStatusProcessing, LeaseToken: "a"}, "b", ErrLeaseMismatch},
+		{"expired", Message{Status: JobStatusProcessing, LeaseToken: "a", LeaseExpiresAt: &past}, "a", ErrLeaseExpired},
+		{"released", Message{Status: JobStatusPending}, "a", ErrLeaseExpired},
+		{"completed", Message{Status: JobStatusCompleted}, "a", ErrInvalidJobState},
+		{"cancelled", Message{Status: JobStatusCancelled}, "a", ErrInvalidJobState},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkLease(&tt.job, tt.token, now); !errors.Is(err, tt.want) {
+				t.Errorf("checkLease = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompleteChecksLease(t *testing.T) {
+	tests := []struct {
+		name  string
+		token func(job *Message) string
+		want  error
+	}{
+		{"valid token", func(job *Message) string { return job.LeaseToken }, nil},
+		{"missing token", func(*Message) string { return "" }, ErrLeaseTokenRequired},
+		{"foreign token", func(*Message) string { return "not-the-lease" }, ErrLeaseMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestQueue(t)
+			pushJobs(t, q, Message{ID: "job", Payload: []byte("x")})
+			job := popJob(t, q, "worker-1", JobFilter{})
+			if job.LeaseToken == "" {
+				t.Fatal("popped job has no lease token")
+			}
+
+			err := q.Complete(job.ID, tt.token(job), JobResult{Payload: []byte("done")})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Complete = %v, want %v", err, tt.want)
+			}
+
+			status, _ := q.GetStatusManager().GetJobStatus(job.ID)
+			wantStatus := JobStatusProcessing
+			if tt.want == nil {
+				wantStatus = JobStatusCompleted
+			}
+			if status.Status != wantStatus {
+				t.Errorf("status = %s, want %s", status.Status, wantStatus)
+			}
+		})
+	}
+}
+
+func TestCompleteUnknownOrFinishedJob(t *testing.T) {
+	q := newTestQueue(t)
+	pushJobs(t, q, Message{ID: "job", Payload: []byte("x")})
+	job := popJob(t, q, "", JobFilter{})
+
+	if err := q.Complete("missing", job.LeaseToken, JobResult{}); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Complete unknown job = %v, want %v", err, ErrJobNotFound)
+	}
+	if err := q.Complete(job.ID, job.LeaseToken, JobResult{}); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if err := q.Complete(job.ID, job.LeaseToken, JobResult{}); !errors.Is(err, ErrInvalidJobState) {
+		t.Errorf("second Complete = %v, want %v", err, ErrInvalidJobState)
+	}
+}
+
+func TestStaleWorkerCannotFinishRetry(t *testing.T) {
+	q := newTestQueue(t)
+	q.SetLeaseTimeout(time.Minute)
+	pushJobs(t, q, Message{ID: "job", Payload: []byte("x")})
+	first := popJob(t, q, "slow", JobFilter{})
+
+	released, err := q.ReleaseExpired(time.Now().Add(2 * time.Minute))
+	if err != nil || len(released) != 1 || released[0] != first.ID {
+		t.Fatalf("ReleaseExpired = %v, %v, want [%s]", released, err, first.ID)
+	}
+	if err := q.Complete(first.ID, first.LeaseToken, JobResult{}); !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("Complete after release = %v, want %v", err, ErrLeaseExpired)
+	}
+
+	retry := popJob(t, q, "fast", JobFilter{})
+	if retry.LeaseToken == first.LeaseToken {
+		t.Fatal("retry reused the lease token")
+	}
+	if err := q.Fail(first.ID, first.LeaseToken, "gave up"); !errors.Is(err, ErrLeaseMismatch) {
+		t.Errorf("Fail with the stale token = %v, want %v", err, ErrLeaseMismatch)
+	}
+	if _, err := q.ExtendLease(first.ID, first.LeaseToken, nil); !errors.Is(err, ErrLeaseMismatch) {
+		t.Errorf("ExtendLease with the stale token = %v, want %v", err, ErrLeaseMismatch)
+	}
+	if err := q.Complete(retry.ID, retry.LeaseToken, JobResult{Payload: []byte("ok")}); err != nil {
+		t.Errorf("Complete with the retry's token: %v", err)
+	}
+}
+
+func TestReleaseExpiredKeepsLiveLeases(t *testing.T) {
+	q := newTestQueue(t)
+	q.SetLeaseTimeout(time.Minute)
+	pushJobs(t, q, Message{ID: "job", Payload: []byte("x")})
+	job := popJob(t, q, "worker-1", JobFilter{})
+
+	// A heartbeat moves the lease past the release time
+	renewed, err := q.ExtendLease(job.ID, job.LeaseToken, nil)
+	if err != nil {
+		t.Fatalf("ExtendLease: %v", err)
+	}
+	if released, _ := q.ReleaseExpired(renewed.LeaseExpiresAt.Add(-time.Second)); len(released) != 0 {
+		t.Errorf("ReleaseExpired released %v before the renewed lease expired", released)
+	}
+	if released, _ := q.ReleaseExpired(renewed.LeaseExpiresAt.Add(time.Second)); len(released) != 1 {
+		t.Errorf("ReleaseExpired released %v after the renewed lease expired, want the job", released)
+	}
+}
+
+func TestFail(t *testing.T) {
+	tests := []struct {
+		name    string
+		reason  string
+		wantErr bool
+	}{
+		{"with reason", "image could not be decoded", false},
+		{"without reason", "", true},
+		{"reason too long", string(make([]byte, maxFailureLength+1)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestQueue(t)
+			pushJobs(t, q, Message{ID: "job", Payload: []byte("x")})
+			job := popJob(t, q, "", JobFilter{})
+
+			err := q.Fail(job.ID, job.LeaseToken, tt.reason)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Fail accepted an invalid reason")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fail: %v", err)
+			}
+
+			// Submitters waiting on the job get the reason
+			if _, err := q.GetStatusManager().WaitForCompletion(job.ID, time.Second); !errors.Is(err, ErrJobFailed) {
+				t.Errorf("WaitForCompletion = %v, want %v", err, ErrJobFailed)
+			}
+			status, _ := q.GetStatusManager().GetJobStatus(job.ID)
+			if status.Status != JobStatusCompleted || status.Error != tt.reason {
+				t.Errorf("status = %s with error %q, want completed with %q", status.Status, status.Error, tt.reason)
+			}
+		})
+	}
+}
+
+func TestPopKeepsJobWhenStartFails(t *testing.T) {
+	q := newTestQueue(t)
+	q.SetLeaseTimeout(time.Minute)
+
+	// A queued job without a status record cannot be marked processing
+	q.messages = append(q.messages, Message{ID: "orphan", Status: JobStatusPending, CreatedAt: time.Now()})
+
+	if _, err := q.Pop("worker-1", JobFilter{}); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("Pop = %v, want %v", err, ErrJobNotFound)
+	}
+	if len(q.messages) != 1 || q.messages[0].ID != "orphan" || q.messages[0].LeaseToken != "" {
+		t.Errorf("queue = %+v, want the job still queued without a lease", q.messages)
+	}
+	if len(q.leases) != 0 {
+		t.Errorf("leases = %v, want none", q.leases)
+	}
+}
@@ -0,0 +1,193 @@
+package queue
+
+import (
+	"time"
+)
+
+// Position is where a job is moved to within the queue
+type Position string
+
+const (
+	PositionFront Position = "front"
+	PositionBack  Position = "back"
+)
+
+// Schedule changes a pending job's ordering, nil fields are left unchanged
+type Schedule struct {
+	Priority      *int
+	ScheduledAt   *time.Time
+	ClearSchedule bool // Make the job due immediately
+}
+
+// Requeue puts a processing or completed job back in the queue, in its original place
+func (q *Queue) Requeue(jobID string) (*Message, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	job, err := q.statusMgr.GetJobStatus(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != JobStatusProcessing && job.Status != JobStatusCompleted {
+		return nil, ErrInvalidJobState
+	}
+
+	job, err = q.statusMgr.Requeue(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(q.leases, jobID)
+	q.insertLocked(*job)
+	if err := q.storage.SaveQueue(q.messages); err != nil {
+		return nil, err
+	}
+	return job, q.dispatchLocked()
+}
+
+// Reschedule changes the priority or scheduled time of a pending job
+func (q *Queue) Reschedule(jobID string, schedule Schedule) (*Message, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	index := q.indexOf(jobID)
+	if index < 0 {
+		return nil, q.missingJobError(jobID)
+	}
+
+	update := func(msg *Message) {
+		if schedule.Priority != nil {
+			msg.Priority = *schedule.Priority
+		}
+		if schedule.ScheduledAt != nil {
+			scheduledAt := *schedule.ScheduledAt
+			msg.ScheduledAt = &scheduledAt
+		}
+		if schedule.ClearSchedule {
+			msg.ScheduledAt = nil
+		}
+	}
+
+	update(&q.messages[index])
+	q.messages[index].UpdatedAt = time.Now()
+	if err := q.statusMgr.UpdateJob(jobID, update); err != nil {
+		return nil, err
+	}
+
+	// A job made due now may go straight to a waiting worker
+	msg := q.messages[index]
+	if err := q.storage.SaveQueue(q.messages); err != nil {
+		return nil, err
+	}
+	return &msg, q.dispatchLocked()
+}
+
+// Delete removes a job from the queue and from status tracking
+func (q *Queue) Delete(jobID string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if err := q.statusMgr.Remove(jobID); err != nil {
+		return err
+	}
+
+	if index := q.indexOf(jobID); index >= 0 {
+		q.messages = append(q.messages[:index], q.messages[index+1:]...)
+		q.notifyRoomLocked()
+		if err := q.storage.SaveQueue(q.messages); err != nil {
+			return err
+		}
+	}
+	// The next job of the deleted job's group may be due now
+	return q.dispatchLocked()
+}
+
+// Move moves a pending job to the front or back of the queue
+func (q *Queue) Move(jobID string, position Position) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	index := q.indexOf(jobID)
+	if index < 0 {
+		return q.missingJobError(jobID)
+	}
+
+	msg := q.messages[index]
+	q.messages = append(q.messages[:index], q.messages[index+1:]...)
+
+	switch position {
+	case PositionFront:
+		q.messages = append([]Message{msg}, q.messages...)
+	default:
+		q.messages = append(q.messages, msg)
+	}
+
+	if err := q.storage.SaveQueue(q.messages); err != nil {
+		return err
+	}
+	// Moving a job can change which job of its group comes first
+	return q.dispatchLocked()
+}
+
+// indexOf returns the position of a pending job, -1 if it is not queued
+// Must be called with the lock held
+func (q *Queue) indexOf(jobID string) int {
+	for i := range q.messages {
+		if q.messages[i].ID == jobID {
+			return i
+		}
+	}
+	return -1
+}
+
+// missingJobError distinguishes unknown jobs from jobs that are no longer queued
+func (q *Queue) missingJobError(jobID string) error {
+	if _, err := q.statusMgr.GetJobStatus(jobID); err != nil {
+		return err
+	}
+	return ErrInvalidJobState
+}
+
+// Purge removes every job matching the filter from the queue and from status tracking
+// Submitters waiting on removed jobs get ErrJobCancelled; a dry run only reports the matches
+func (q *Queue) Purge(filter JobFilter, dryRun bool) ([]Message, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	matched, err := q.statusMgr.Purge(filter, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	// Queued jobs without a status record still match on their own attributes
+	removed := make(map[string]bool, len(matched))
+	for _, job := range matched {
+		removed[job.ID] = true
+	}
+	for _, msg := range q.messages {
+		if !removed[msg.ID] && filter.Matches(&msg) {
+			matched = append(matched, msg)
+			removed[msg.ID] = true
+		}
+	}
+
+	if dryRun {
+		return matched, nil
+	}
+
+	kept := make([]Message, 0, len(q.messages))
+	for _, msg := range q.messages {
+		if !removed[msg.ID] {
+			kept = append(kept, msg)
+		}
+	}
+	if len(kept) < len(q.messages) {
+		q.notifyRoomLocked()
+	}
+	q.messages = kept
+
+	if err := q.storage.SaveQueue(q.messages); err != nil {
+		return matched, err
+	}
+	return matched, q.dispatchLocked()
+}
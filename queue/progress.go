@@ -0,0 +1,109 @@
+package queue
+
+import (
+	"errors"
+	"time"
+)
+
+// Progress is what a worker last reported about a job it is processing
+type Progress struct {
+	Percent   int       `json:"percent"`
+	Message   string    `json:"message,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate checks the percentage is in range
+func (p Progress) Validate() error {
+	if p.Percent < 0 || p.Percent > 100 {
+		return errors.New("progress percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// heartbeat renews the lease held with leaseToken and records the job's progress when it is not nil
+// A job.progress event is published for progress; must be called with the queue lock held
+func (jsm *JobStatusManager) heartbeat(jobID string, leaseToken string, leaseExpiresAt *time.Time, progress *Progress) (*Message, error) {
+	jsm.mutex.Lock()
+	defer jsm.mutex.Unlock()
+
+	job, exists := jsm.statusMap[jobID]
+	if !exists {
+		return nil, ErrJobNotFound
+	}
+	now := time.Now()
+	if err := checkLease(&job, leaseToken, now); err != nil {
+		return nil, err
+	}
+
+	// A plain heartbeat is written only once half the written lease has passed, not on every call
+	save := progress != nil
+	if leaseExpiresAt != nil {
+		job.LeaseExpiresAt = leaseExpiresAt
+		save = save || jsm.leaseStaleLocked(jobID, *leaseExpiresAt, now)
+	}
+	if progress != nil {
+		progress.UpdatedAt = now
+		job.Progress = progress
+		job.UpdatedAt = now
+	}
+	jsm.statusMap[jobID] = job
+
+	// Plain heartbeats only move the lease, which is not worth an event
+	if progress != nil {
+		jsm.events.Publish(EventJobProgress, &job)
+	}
+	if !save {
+		return &job, nil
+	}
+	if err := jsm.storage.SaveJobStatus(jsm.statusMap); err != nil {
+		return nil, err
+	}
+	jsm.persistedLocked(jobID, job.LeaseExpiresAt)
+	return &job, nil
+}
+
+// leaseStaleLocked reports whether the written lease of a job is too old to keep, must be called with the lock held
+func (jsm *JobStatusManager) leaseStaleLocked(jobID string, expiresAt time.Time, now time.Time) bool {
+	written, ok := jsm.persisted[jobID]
+	return !ok || written.Sub(now) < expiresAt.Sub(written)
+}
+
+// persistedLocked records the lease expiry of a job just written to storage, must be called with the lock held
+// Entries of jobs no longer processing are dropped
+func (jsm *JobStatusManager) persistedLocked(jobID string, expiresAt *time.Time) {
+	for id := range jsm.persisted {
+		if jsm.statusMap[id].Status != JobStatusProcessing {
+			delete(jsm.persisted, id)
+		}
+	}
+	if expiresAt != nil {
+		jsm.persisted[jobID] = *expiresAt
+	}
+}
+
+// start marks a job processing under the lease of the taken message and publishes a job.started event
+func (jsm *JobStatusManager) start(msg *Message) error {
+	jsm.mutex.Lock()
+	defer jsm.mutex.Unlock()
+
+	job, exists := jsm.statusMap[msg.ID]
+	if !exists {
+		return ErrJobNotFound
+	}
+
+	previous := job
+	job.Status = JobStatusProcessing
+	job.LeaseExpiresAt = msg.LeaseExpiresAt
+	job.LeaseToken = msg.LeaseToken
+	job.WorkerID = msg.WorkerID
+	job.Progress = nil
+	job.UpdatedAt = msg.UpdatedAt
+	jsm.statusMap[msg.ID] = job
+	if err := jsm.storage.SaveJobStatus(jsm.statusMap); err != nil {
+		jsm.statusMap[msg.ID] = previous
+		return err
+	}
+	jsm.persistedLocked(msg.ID, job.LeaseExpiresAt)
+	jsm.events.Publish(EventJobStarted, &job)
+	return nil
+}
@@ -0,0 +1,136 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressValidate(t *testing.T) {
+	tests := []struct {
+		percent int
+		wantErr bool
+	}{
+		{0, false},
+		{55, false},
+		{100, false},
+		{-1, true},
+		{101, true},
+	}
+	for _, tt := range tests {
+		err := Progress{Percent: tt.percent}.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%d) = %v, want error %v", tt.percent, err, tt.wantErr)
+		}
+	}
+}
+
+func TestHeartbeatRecordsProgress(t *testing.T) {
+	q := newTestQueue(t)
+	q.SetLeaseTimeout(time.Minute)
+	pushJobs(t, q, Message{ID: "job", Payload: []byte("x")})
+	job := popJob(t, q, "worker-1", JobFilter{})
+
+	renewed, err := q.ExtendLease(job.ID, job.LeaseToken, &Progress{Percent: 40, Message: "resizing"})
+	if err != nil {
+		t.Fatalf("ExtendLease: %v", err)
+	}
+	if renewed.LeaseExpiresAt.Before(*job.LeaseExpiresAt) {
+		t.Errorf("renewed lease = %v, want no earlier than %v", renewed.LeaseExpiresAt, job.LeaseExpiresAt)
+	}
+
+	status, _ := q.GetStatusManager().GetJobStatus(job.ID)
+	if status.Progress == nil || status.Progress.Percent != 40 || status.Progress.Message != "resizing" {
+		t.Errorf("progress = %+v, want 40%% resizing", status.Progress)
+	}
+
+	// A plain heartbeat keeps the last progress
+	if _, err := q.ExtendLease(job.ID, job.LeaseToken, nil); err != nil {
+		t.Fatalf("ExtendLease: %v", err)
+	}
+	if status, _ := q.GetStatusManager().GetJobStatus(job.ID); status.Progress == nil || status.Progress.Percent != 40 {
+		t.Errorf("progress after a plain heartbeat = %+v, want 40%%", status.Progress)
+	}
+}
+
+func TestLeaseStale(t *testing.T) {
+	now := time.Now()
+	lease := time.Minute
+
+	tests := []struct {
+		name    string
+		written *time.Time // Lease expiry last written, nil when never written
+		want    bool
+	}{
+		{"never written", nil, true},
+		{"just written", ptr(now.Add(lease)), false},
+		{"less than half passed", ptr(now.Add(lease - 20*time.Second)), false},
+		{"more than half passed", ptr(now.Add(lease - 40*time.Second)), true},
+		{"already expired", ptr(now.Add(-time.Second)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsm := &JobStatusManager{persisted: make(map[string]time.Time)}
+			if tt.written != nil {
+				jsm.persisted["job"] = *tt.written
+			}
+			if got := jsm.leaseStaleLocked("job", now.Add(lease), now); got != tt.want {
+				t.Errorf("leaseStaleLocked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeartbeatsPersistProgressAndLease(t *testing.T) {
+	dir := t.TempDir()
+	q, err := NewQueue("test", dir)
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+	q.SetLeaseTimeout(time.Minute)
+	pushJobs(t, q, Message{ID: "job", Payload: []byte("x")})
+	job := popJob(t, q, "worker-1", JobFilter{})
+
+	// A plain heartbeat right after the start only renews the lease in memory
+	renewed, err := q.ExtendLease(job.ID, job.LeaseToken, nil)
+	if err != nil {
+		t.Fatalf("ExtendLease: %v", err)
+	}
+	stored := loadStatus(t, dir, job.ID)
+	if !stored.LeaseExpiresAt.Equal(*job.LeaseExpiresAt) || stored.LeaseExpiresAt.Equal(*renewed.LeaseExpiresAt) {
+		t.Errorf("stored lease = %v, want the lease of the start %v", stored.LeaseExpiresAt, job.LeaseExpiresAt)
+	}
+
+	// Progress is written at once, with the renewed lease
+	if _, err := q.ExtendLease(job.ID, job.LeaseToken, &Progress{Percent: 40, Message: "resizing"}); err != nil {
+		t.Fatalf("ExtendLease with progress: %v", err)
+	}
+	stored = loadStatus(t, dir, job.ID)
+	if stored.Progress == nil || stored.Progress.Percent != 40 || stored.Progress.Message != "resizing" {
+		t.Errorf("stored progress = %+v, want 40%% resizing", stored.Progress)
+	}
+	if !stored.LeaseExpiresAt.After(*job.LeaseExpiresAt) {
+		t.Errorf("stored lease = %v, want later than %v", stored.LeaseExpiresAt, job.LeaseExpiresAt)
+	}
+}
+
+// loadStatus reads the stored status of a job, as a restarted server would
+func loadStatus(t *testing.T, dir string, jobID string) Message {
+	t.Helper()
+	storage, err := NewStorage("test", dir)
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	statuses, err := storage.LoadJobStatus()
+	if err != nil {
+		t.Fatalf("LoadJobStatus: %v", err)
+	}
+	job, ok := statuses[jobID]
+	if !ok {
+		t.Fatalf("job %s is not stored", jobID)
+	}
+	return job
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
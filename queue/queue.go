@@ -1,8 +1,13 @@
 package queue
 
 import (
+	"crypto/rand"
+	"log"
 	"sync"
 	"time"
+
+	"github.com/PAFFx/job-poll-queue/ratelimit"
+	"github.com/PAFFx/job-poll-queue/schema"
 )
 
 // JobStatus represents the current status of a job
@@ -12,30 +17,115 @@ const (
 	JobStatusPending    JobStatus = "pending"
 	JobStatusProcessing JobStatus = "processing"
 	JobStatusCompleted  JobStatus = "completed"
+	JobStatusCancelled  JobStatus = "cancelled" // Removed by an admin before completing
 )
 
+// IsTerminal reports whether a job in this status will not change any more
+func (s JobStatus) IsTerminal() bool {
+	return s == JobStatusCompleted || s == JobStatusCancelled
+}
+
 // Message represents an item in the queue
 type Message struct {
-	ID          string            `json:"id"`
-	Payload     string            `json:"payload"`
-	Headers     map[string]string `json:"headers,omitempty"`
-	Status      JobStatus         `json:"status"`
-	Result      string            `json:"result,omitempty"`
-	Error       string            `json:"error,omitempty"`
-	CreatedAt   time.Time         `json:"created_at"`
-	UpdatedAt   time.Time         `json:"updated_at"`
-	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+	ID                 string              `json:"id"`
+	Payload            []byte              `json:"payload"`
+	ContentType        string              `json:"content_type,omitempty"`
+	Headers            map[string]string   `json:"headers,omitempty"`
+	Request            *HTTPRequest        `json:"request,omitempty"`
+	Callback           *Callback           `json:"callback,omitempty"`
+	SubmittedBy        *Submitter          `json:"submitted_by,omitempty"` // Authenticated caller that submitted the job
+	Tenant             string              `json:"tenant,omitempty"`       // Namespace owning the job, empty for untenanted jobs
+	Priority           int                 `json:"priority,omitempty"`     // Higher priorities are popped first
+	Group              string              `json:"group,omitempty"`        // Jobs of a group are processed one at a time, in queue order
+	ScheduledAt        *time.Time          `json:"scheduled_at,omitempty"` // Not popped before this time
+	Requirements       Requirements        `json:"requirements,omitempty"` // Only popped by workers with these capabilities
+	Status             JobStatus           `json:"status"`
+	Progress           *Progress           `json:"progress,omitempty"`         // Last progress reported while processing
+	LeaseExpiresAt     *time.Time          `json:"lease_expires_at,omitempty"` // Released back to the queue without a heartbeat by then
+	LeaseToken         string              `json:"lease_token,omitempty"`      // Proves which worker holds the job, new on every lease
+	WorkerID           string              `json:"worker_id,omitempty"`        // Registered worker holding or having completed the job
+	Result             []byte              `json:"result,omitempty"`
+	ResultContentType  string              `json:"result_content_type,omitempty"`
+	ResultStatusCode   int                 `json:"result_status_code,omitempty"`
+	ResultHeaders      map[string]string   `json:"result_headers,omitempty"`
+	ResultSchemaErrors []schema.FieldError `json:"result_schema_errors,omitempty"` // Flagged result schema violations
+	Error              string              `json:"error,omitempty"`
+	CreatedAt          time.Time           `json:"created_at"`
+	UpdatedAt          time.Time           `json:"updated_at"`
+	CompletedAt        *time.Time          `json:"completed_at,omitempty"`
+}
+
+// HTTPRequest describes the original request of a job submitted through the proxy route
+type HTTPRequest struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+}
+
+// Submitter identifies the authenticated caller that submitted a job
+type Submitter struct {
+	ID     string `json:"id"` // API key ID, or token subject
+	Name   string `json:"name,omitempty"`
+	Method string `json:"method"` // api_key or jwt
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// Callback is a webhook notified when the job reaches a terminal state
+type Callback struct {
+	URL    string `json:"url"`
+	Secret string `json:"-"`                // Set on submission, kept in CallbackSecrets once the job is queued
+	Signed bool   `json:"signed,omitempty"` // Deliveries are signed with the secret in CallbackSecrets
+}
+
+// JobResult holds the response a worker produced for a job
+type JobResult struct {
+	Payload      []byte
+	ContentType  string
+	StatusCode   int
+	Headers      map[string]string
+	SchemaErrors []schema.FieldError // Result schema violations, set when they are flagged
 }
 
 // Queue implements a FIFO queue with storage persistence
 type Queue struct {
-	name      string
-	messages  []Message
-	storage   *Storage
-	mutex     sync.Mutex
-	statusMgr *JobStatusManager
+	name          string
+	messages      []Message
+	storage       *Storage
+	mutex         sync.Mutex
+	statusMgr     *JobStatusManager
+	secrets       *CallbackSecrets
+	headers       *HeaderRules // Default header rules, see headerRulesLocked
+	events        *EventBus
+	settings      queueSettings
+	tenants       map[string]Tenant
+	defaultQuota  Quota                        // Applies to tenants without a stored configuration
+	buckets       map[string]*ratelimit.Bucket // Submit rate of each tenant
+	room          chan struct{}                // Closed when jobs leave the queue, see PushWait
+	waiters       []*waiter                    // Workers blocked in PopWait, in arrival order
+	wakeup        *time.Timer                  // Dispatches the next scheduled job to waiters
+	leaseTimeout  time.Duration                // How long workers hold jobs without a heartbeat, zero is forever
+	leases        map[string]time.Time         // Expiry of each processing job's lease
+	workers       map[string]*workerState      // Workers seen since the server started, by ID
+	workerTimeout time.Duration                // Idle workers not seen for longer are offline
+	passes        map[string]float64           // Turns taken by each fairness key, see chargeLocked
+	virtualPass   float64                      // Pass of the fairness key served last
+}
+
+// queueSettings is the persisted runtime configuration of a queue
+type queueSettings struct {
+	Paused   bool         `json:"paused"`
+	Schemas  Schemas      `json:"schemas"`
+	MaxDepth int          `json:"max_depth,omitempty"` // Maximum pending jobs, zero is unlimited
+	Fairness Fairness     `json:"fairness,omitempty"`
+	Headers  *HeaderRules `json:"headers,omitempty"` // Header capture rules, the defaults when nil
 }
 
+// settingsKey names the persisted queue settings in storage
+const settingsKey = "settings"
+
+// eventHistorySize is the number of lifecycle events kept for resuming streams
+const eventHistorySize = 1000
+
 // NewQueue creates a new queue with the given name and storage directory
 func NewQueue(name string, storageDir string) (*Queue, error) {
 	// Initialize storage storage
@@ -44,8 +134,13 @@ func NewQueue(name string, storageDir string) (*Queue, error) {
 		return nil, err
 	}
 
-	// Initialize job status manager
-	statusMgr, err := NewJobStatusManager(storage)
+	// Initialize the lifecycle event bus and job status manager
+	events := NewEventBus(eventHistorySize)
+	statusMgr, err := NewJobStatusManager(storage, events)
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := NewCallbackSecrets(storage)
 	if err != nil {
 		return nil, err
 	}
@@ -56,6 +151,14 @@ func NewQueue(name string, storageDir string) (*Queue, error) {
 		storage:   storage,
 		mutex:     sync.Mutex{},
 		statusMgr: statusMgr,
+		secrets:   secrets,
+		events:    events,
+		tenants:   make(map[string]Tenant),
+		buckets:   make(map[string]*ratelimit.Bucket),
+		room:      make(chan struct{}),
+		leases:    make(map[string]time.Time),
+		workers:   make(map[string]*workerState),
+		passes:    make(map[string]float64),
 	}
 
 	// Load existing messages from storage
@@ -65,72 +168,240 @@ func NewQueue(name string, storageDir string) (*Queue, error) {
 	}
 	q.messages = messages
 
+	// Load runtime settings such as the paused state
+	if _, err := storage.LoadState(settingsKey, &q.settings); err != nil {
+		return nil, err
+	}
+
+	// Load tenant quotas
+	var tenants []Tenant
+	if _, err := storage.LoadState(tenantsKey, &tenants); err != nil {
+		return nil, err
+	}
+	for _, tenant := range tenants {
+		q.tenants[tenant.ID] = tenant
+	}
+
+	// Jobs still processing keep their leases across restarts
+	q.loadLeases()
+
 	return q, nil
 }
 
+// GetName returns the name of the queue
+func (q *Queue) GetName() string {
+	return q.name
+}
+
 func (q *Queue) GetStatusManager() *JobStatusManager {
 	return q.statusMgr
 }
 
+// GetEventBus returns the bus publishing job lifecycle events
+func (q *Queue) GetEventBus() *EventBus {
+	return q.events
+}
+
 func (q *Queue) GetStorage() *Storage {
 	return q.storage
 }
 
 // Push adds a message to the end of the queue and persists to storage
+// Jobs are rejected with a *CapacityError when the queue is full,
+// and with a *QuotaError when they would take their tenant over a quota
 func (q *Queue) Push(msg Message) error {
+	_, err := q.push(msg)
+	return err
+}
+
+// push adds a message, the returned channel is closed once there may be room when the queue is full
+func (q *Queue) push(msg Message) (<-chan struct{}, error) {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
 
+	// Reject payloads the workers could not handle
+	if err := validatePayload(q.settings.Schemas.Payload, "payload", msg.Payload); err != nil {
+		return nil, err
+	}
+	if err := q.checkCapacityLocked(); err != nil {
+		return q.room, err
+	}
+	if err := q.checkQuotaLocked(&msg); err != nil {
+		return nil, err
+	}
+
 	// Initialize job fields
 	now := time.Now()
 	msg.Status = JobStatusPending
 	msg.CreatedAt = now
 	msg.UpdatedAt = now
 
+	// Filter captured headers before they are stored or handed to workers
+	msg.Headers = q.headerRulesLocked().Apply(msg.Headers)
+
+	// Register job in status manager, a job it cannot track is never queued
+	if err := q.keepCallbackSecret(&msg); err != nil {
+		return nil, err
+	}
+	if err := q.statusMgr.RegisterJob(&msg); err != nil {
+		q.secrets.Delete(msg.ID)
+		return nil, err
+	}
 	q.messages = append(q.messages, msg)
+	q.events.Publish(EventJobQueued, &msg)
 
-	// Register job in status manager
-	q.statusMgr.RegisterJob(&msg)
+	if err := q.storage.SaveQueue(q.messages); err != nil {
+		return nil, err
+	}
 
-	return q.storage.SaveQueue(q.messages)
+	// The job is stored by now, a failure to hand it out is not the submitter's concern
+	if err := q.dispatchLocked(); err != nil {
+		log.Printf("Failed to dispatch jobs after queueing %s: %v", msg.ID, err)
+	}
+	return nil, nil
 }
 
-// Pop removes and returns the first message from the queue
-// Returns nil if the queue is empty
-func (q *Queue) Pop() (*Message, error) {
+// Pop removes and returns the next message matching the filter whose requirements the filter's capabilities meet,
+// leased to the given worker
+// Workers are registered on first sight, an empty ID takes the job anonymously
+// Returns nil if no such message is due, the queue is paused or the worker is draining
+func (q *Queue) Pop(workerID string, filter JobFilter) (*Message, error) {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
 
-	if len(q.messages) == 0 {
+	now := time.Now()
+	if err := q.seeConsumerLocked(workerID, filter.Tenant, now); err != nil {
+		return nil, err
+	}
+	if q.settings.Paused || q.workerDrainingLocked(workerID) {
+		return nil, nil
+	}
+
+	index := q.nextIndex(now, filter.takes)
+	if index < 0 {
 		return nil, nil
 	}
+	msg, err := q.takeLocked(index, now, workerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Save the updated queue state
+	if err := q.storage.SaveQueue(q.messages); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
 
-	// Get the first message
-	msg := q.messages[0]
+// takeLocked removes the message at index and leases it to a worker, must be called with the lock held
+// The returned message carries the lease token the worker must present to finish the job
+// If its running status cannot be recorded, the message stays queued and the error is returned
+func (q *Queue) takeLocked(index int, now time.Time, workerID string) (*Message, error) {
+	msg := q.messages[index]
 
 	// Update status to processing
 	msg.Status = JobStatusProcessing
-	msg.UpdatedAt = time.Now()
+	msg.UpdatedAt = now
+	msg.WorkerID = workerID
+	msg.LeaseToken = rand.Text()
+	msg.LeaseExpiresAt = q.leaseExpiryLocked(now)
 
 	// Update status in status manager
-	q.statusMgr.UpdateStatus(msg.ID, JobStatusProcessing)
-
-	// Remove it from the queue
-	q.messages = q.messages[1:]
-
-	// Save the updated queue state
-	if err := q.storage.SaveQueue(q.messages); err != nil {
+	if err := q.statusMgr.start(&msg); err != nil {
 		return nil, err
 	}
+	q.touchWorkerLocked(workerID, now)
+	q.chargeLocked(&msg)
+	if msg.LeaseExpiresAt != nil {
+		q.leases[msg.ID] = *msg.LeaseExpiresAt
+	}
+
+	// Remove it from the queue
+	q.messages = append(q.messages[:index], q.messages[index+1:]...)
+	q.notifyRoomLocked()
 
 	return &msg, nil
 }
 
-// Clear removes all messages from the queue
-func (q *Queue) Clear() error {
+// Pause stops workers from taking jobs, submissions are still accepted
+func (q *Queue) Pause() error {
+	return q.setPaused(true)
+}
+
+// Resume lets workers take jobs again
+func (q *Queue) Resume() error {
+	return q.setPaused(false)
+}
+
+// IsPaused reports whether consumption is paused
+func (q *Queue) IsPaused() bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.settings.Paused
+}
+
+func (q *Queue) setPaused(paused bool) error {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
 
-	q.messages = []Message{}
-	return q.storage.SaveQueue(q.messages)
+	q.settings.Paused = paused
+	if err := q.storage.SaveState(settingsKey, q.settings); err != nil {
+		return err
+	}
+	// Workers waiting while the queue was paused get the jobs that piled up
+	return q.dispatchLocked()
+}
+
+// Peek returns a copy of the next message matching the filter without removing it
+// Returns nil if no such message is due
+func (q *Queue) Peek(filter JobFilter) *Message {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	index := q.nextIndex(time.Now(), filter.Matches)
+	if index < 0 {
+		return nil
+	}
+
+	msg := q.messages[index]
+	return &msg
+}
+
+// nextIndex returns the index of the next message to pop, -1 if none is due
+// Only messages passing eligible are considered when it is not nil
+// The highest priority wins, ties keep queue order; must be called with the lock held
+// Of a message group only the first queued job is considered, and only while no job of the group is processing
+// With a fairness policy, the best job of each fairness key competes for the key's turn
+func (q *Queue) nextIndex(now time.Time, eligible func(*Message) bool) int {
+	blocked := q.statusMgr.busyGroups()
+	fairness := q.settings.Fairness
+	heads := make(map[string]int)
+	for i := range q.messages {
+		msg := &q.messages[i]
+		if key, ok := msg.groupKey(); ok {
+			if blocked[key] {
+				continue
+			}
+			// Later jobs of the group wait for this one, even when it is not due or not eligible
+			blocked[key] = true
+		}
+		if msg.ScheduledAt != nil && msg.ScheduledAt.After(now) {
+			continue
+		}
+		if eligible != nil && !eligible(msg) {
+			continue
+		}
+		key := fairness.keyOf(msg)
+		if best, ok := heads[key]; !ok || msg.Priority > q.messages[best].Priority {
+			heads[key] = i
+		}
+	}
+	return q.pickLocked(heads)
+}
+
+// Clear removes all jobs, submitters waiting on unfinished jobs get ErrJobCancelled
+func (q *Queue) Clear() error {
+	_, err := q.Purge(JobFilter{}, false)
+	return err
 }
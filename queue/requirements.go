@@ -0,0 +1,63 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Limits on job requirements and worker capabilities
+const (
+	maxRequirements   = 32
+	maxRequirementLen = 128
+)
+
+// Requirements are the capabilities a worker needs to take a job, by name
+// An empty value only needs the capability to be advertised, any other value must match exactly
+type Requirements map[string]string
+
+// SatisfiedBy reports whether a worker advertising the capabilities may take the job
+func (r Requirements) SatisfiedBy(capabilities map[string]string) bool {
+	for name, value := range r {
+		advertised, ok := capabilities[name]
+		if !ok || (value != "" && advertised != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseRequirements parses a comma separated list such as "gpu, model=llama-3, region=eu-west"
+// Used for both job requirements and worker capabilities, a bare name has an empty value
+func ParseRequirements(value string) (Requirements, error) {
+	parsed := make(Requirements)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, val, _ := strings.Cut(item, "=")
+		name, val = strings.TrimSpace(name), strings.TrimSpace(val)
+		if name == "" {
+			return nil, fmt.Errorf("invalid requirement %q, expected name or name=value", item)
+		}
+		parsed[name] = val
+	}
+	return parsed, parsed.Validate()
+}
+
+// Validate checks the number and length of the entries
+func (r Requirements) Validate() error {
+	if len(r) > maxRequirements {
+		return fmt.Errorf("no more than %d requirements or capabilities are allowed", maxRequirements)
+	}
+	for name, value := range r {
+		if name == "" {
+			return errors.New("requirement and capability names must not be empty")
+		}
+		if len(name) > maxRequirementLen || len(value) > maxRequirementLen {
+			return fmt.Errorf("requirement %q is longer than %d characters", name, maxRequirementLen)
+		}
+	}
+	return nil
+}
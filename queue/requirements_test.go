@@ -0,0 +1,81 @@
+package queue
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestParseRequirements(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Requirements
+		wantErr bool
+	}{
+		{"", Requirements{}, false},
+		{"gpu", Requirements{"gpu": ""}, false},
+		{" gpu , model = llama-3,region=eu-west ,", Requirements{"gpu": "", "model": "llama-3", "region": "eu-west"}, false},
+		{"=llama-3", nil, true},
+		{"gpu," + strings.Repeat("x", maxRequirementLen+1), nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRequirements(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRequirements(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !maps.Equal(got, tt.want) {
+			t.Errorf("ParseRequirements(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRequirementsSatisfiedBy(t *testing.T) {
+	tests := []struct {
+		name         string
+		requirements Requirements
+		capabilities map[string]string
+		want         bool
+	}{
+		{"no requirements", nil, nil, true},
+		{"advertised", Requirements{"gpu": ""}, map[string]string{"gpu": "a100"}, true},
+		{"not advertised", Requirements{"gpu": ""}, map[string]string{"cpu": ""}, false},
+		{"matching value", Requirements{"model": "llama-3"}, map[string]string{"model": "llama-3", "gpu": ""}, true},
+		{"other value", Requirements{"model": "llama-3"}, map[string]string{"model": "mistral"}, false},
+		{"one of several missing", Requirements{"gpu": "", "region": "eu"}, map[string]string{"gpu": ""}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.requirements.SatisfiedBy(tt.capabilities); got != tt.want {
+				t.Errorf("SatisfiedBy = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPopSkipsJobsWithUnmetRequirements(t *testing.T) {
+	tests := []struct {
+		name         string
+		capabilities map[string]string
+		want         string
+	}{
+		{"without capabilities", nil, "plain"},
+		{"with a gpu", map[string]string{"gpu": ""}, "gpu"},
+		{"with the model", map[string]string{"gpu": "", "model": "llama-3"}, "llama"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestQueue(t)
+			pushJobs(t, q,
+				Message{ID: "llama", Priority: 2, Requirements: Requirements{"gpu": "", "model": "llama-3"}},
+				Message{ID: "gpu", Priority: 1, Requirements: Requirements{"gpu": ""}},
+				Message{ID: "plain"},
+			)
+
+			job := popJob(t, q, "worker-1", JobFilter{Capabilities: tt.capabilities})
+			if job.ID != tt.want {
+				t.Errorf("Pop = %s, want %s", job.ID, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/PAFFx/job-poll-queue/schema"
+)
+
+// ResultSchemaMode decides what happens to results that do not match the result schema
+type ResultSchemaMode string
+
+const (
+	// ResultSchemaReject refuses the result, the job stays processing so the worker can retry
+	ResultSchemaReject ResultSchemaMode = "reject"
+	// ResultSchemaFlag stores the result together with its violations
+	ResultSchemaFlag ResultSchemaMode = "flag"
+)
+
+// Schemas are the JSON Schemas job payloads and results of a queue must match, nil schemas are not enforced
+type Schemas struct {
+	Payload    *schema.Schema   `json:"payload,omitempty"`
+	Result     *schema.Schema   `json:"result,omitempty"`
+	ResultMode ResultSchemaMode `json:"result_mode,omitempty"` // Defaults to reject
+}
+
+// SchemaError reports a payload or result that does not match the queue schema
+type SchemaError struct {
+	Target string // "payload" or "result"
+	Errors []schema.FieldError
+}
+
+func (e *SchemaError) Error() string {
+	return e.Target + " does not match the queue schema"
+}
+
+// SetSchemas replaces the schemas of the queue and persists them
+func (q *Queue) SetSchemas(schemas Schemas) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.settings.Schemas = schemas
+	return q.storage.SaveState(settingsKey, q.settings)
+}
+
+// GetSchemas returns the schemas of the queue
+func (q *Queue) GetSchemas() Schemas {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.settings.Schemas
+}
+
+// Complete stores a worker's result after checking it against the result schema
+// In reject mode an invalid result returns a *SchemaError and is not stored,
+// in flag mode it is stored with its violations. The lease token must be the one of the job's current lease
+func (q *Queue) Complete(jobID string, leaseToken string, result JobResult) error {
+	schemas := q.GetSchemas()
+	if err := validatePayload(schemas.Result, "result", result.Payload); err != nil {
+		if schemas.ResultMode == ResultSchemaFlag {
+			result.SchemaErrors = err.Errors
+		} else {
+			return err
+		}
+	}
+
+	return q.finish(jobID, leaseToken, result, nil)
+}
+
+// maxFailureLength bounds the reason a worker gives for failing a job
+const maxFailureLength = 1024
+
+// ValidateFailure checks the reason a worker gives for failing a job
+func ValidateFailure(reason string) error {
+	if reason == "" {
+		return errors.New("error is required")
+	}
+	if len(reason) > maxFailureLength {
+		return fmt.Errorf("error must be at most %d characters", maxFailureLength)
+	}
+	return nil
+}
+
+// Fail finishes a job leased with leaseToken as failed, the reason is stored as the job's error
+// and returned to a submitter waiting for the result
+func (q *Queue) Fail(jobID string, leaseToken string, reason string) error {
+	if err := ValidateFailure(reason); err != nil {
+		return err
+	}
+	return q.finish(jobID, leaseToken, JobResult{}, errors.New(reason))
+}
+
+// finish stores the outcome of a job under the worker's lease and hands out the jobs it held back
+func (q *Queue) finish(jobID string, leaseToken string, result JobResult, jobErr error) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if err := q.statusMgr.SubmitResult(jobID, leaseToken, result, jobErr); err != nil {
+		return err
+	}
+	delete(q.leases, jobID)
+
+	// Throughput is credited to the worker that held the lease
+	if job, err := q.statusMgr.GetJobStatus(jobID); err == nil {
+		q.recordCompletionLocked(job.WorkerID, time.Now())
+	}
+	// The next job of the finished job's group can be handed out; the outcome is stored by now,
+	// so a failure to hand out jobs is not the worker's concern
+	if err := q.dispatchLocked(); err != nil {
+		log.Printf("Failed to dispatch jobs after finishing %s: %v", jobID, err)
+	}
+	return nil
+}
+
+// validatePayload checks a JSON document against a schema, nil schemas accept anything
+func validatePayload(s *schema.Schema, target string, data []byte) *SchemaError {
+	if s == nil {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return &SchemaError{Target: target, Errors: []schema.FieldError{{Message: target + " is not valid JSON"}}}
+	}
+	if errs := s.Validate(value); len(errs) > 0 {
+		return &SchemaError{Target: target, Errors: errs}
+	}
+	return nil
+}
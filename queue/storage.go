@@ -9,6 +9,8 @@ import (
 
 // Storage handles persistence operations for the queue
 type Storage struct {
+	name          string
+	dir           string
 	queuePath     string
 	jobStatusPath string
 }
@@ -20,10 +22,176 @@ func NewStorage(name string, storageDir string) (*Storage, error) {
 		return nil, fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
-	return &Storage{
+	s := &Storage{
+		name:          name,
+		dir:           storageDir,
 		queuePath:     filepath.Join(storageDir, fmt.Sprintf("%s.json", name)),
 		jobStatusPath: filepath.Join(storageDir, fmt.Sprintf("%s-jobstatus.json", name)),
-	}, nil
+	}
+	if err := s.migrate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Versions of the persisted queue and job status files
+// Version 1 has no format state and stores payloads and results as plain strings,
+// version 2 stores them as base64 encoded bytes, version 3 keeps callback secrets in their own file
+const (
+	formatKey     = "format"
+	currentFormat = 3
+)
+
+// legacyMessage reads a message saved in format version 1
+type legacyMessage struct {
+	Message
+	Payload string `json:"payload"`
+	Result  string `json:"result,omitempty"`
+}
+
+func (m legacyMessage) upgrade() Message {
+	msg := m.Message
+	msg.Payload = []byte(m.Payload)
+	if m.Result != "" {
+		msg.Result = []byte(m.Result)
+	}
+	return msg
+}
+
+// legacyCallback reads the callback secret of a message saved in format version 2 or earlier
+type legacyCallback struct {
+	ID       string `json:"id"`
+	Callback *struct {
+		Secret string `json:"secret"`
+	} `json:"callback"`
+}
+
+func (m legacyCallback) secret() string {
+	if m.Callback == nil {
+		return ""
+	}
+	return m.Callback.Secret
+}
+
+// migrate rewrites files saved in an older format once, before anything loads them
+func (s *Storage) migrate() error {
+	var format int
+	found, err := s.LoadState(formatKey, &format)
+	if err != nil || (found && format >= currentFormat) {
+		return err
+	}
+
+	// Secrets are read first, messages upgraded below no longer carry them
+	secrets, err := s.legacyCallbackSecrets()
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		var messages []legacyMessage
+		if ok, err := readJSON(s.queuePath, &messages); err != nil {
+			return fmt.Errorf("failed to migrate queue data: %w", err)
+		} else if ok {
+			upgraded := make([]Message, 0, len(messages))
+			for _, msg := range messages {
+				upgraded = append(upgraded, msg.upgrade())
+			}
+			if err := s.SaveQueue(upgraded); err != nil {
+				return err
+			}
+		}
+
+		jobs := make(map[string]legacyMessage)
+		if ok, err := readJSON(s.jobStatusPath, &jobs); err != nil {
+			return fmt.Errorf("failed to migrate job status data: %w", err)
+		} else if ok {
+			upgraded := make(map[string]Message, len(jobs))
+			for id, job := range jobs {
+				upgraded[id] = job.upgrade()
+			}
+			if err := s.SaveJobStatus(upgraded); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(secrets) > 0 {
+		if err := s.moveCallbackSecrets(secrets); err != nil {
+			return err
+		}
+	}
+
+	return s.SaveState(formatKey, currentFormat)
+}
+
+// legacyCallbackSecrets returns the callback secrets stored in the queue and job status files, by job ID
+func (s *Storage) legacyCallbackSecrets() (map[string]string, error) {
+	secrets := make(map[string]string)
+
+	var queued []legacyCallback
+	if _, err := readJSON(s.queuePath, &queued); err != nil {
+		return nil, fmt.Errorf("failed to migrate queue data: %w", err)
+	}
+	for _, msg := range queued {
+		if secret := msg.secret(); secret != "" {
+			secrets[msg.ID] = secret
+		}
+	}
+
+	jobs := make(map[string]legacyCallback)
+	if _, err := readJSON(s.jobStatusPath, &jobs); err != nil {
+		return nil, fmt.Errorf("failed to migrate job status data: %w", err)
+	}
+	for id, job := range jobs {
+		if secret := job.secret(); secret != "" {
+			secrets[id] = secret
+		}
+	}
+	return secrets, nil
+}
+
+// moveCallbackSecrets stores secrets read from an older format in their own file
+// and marks the callbacks of their jobs as signed, the rewritten files drop the secrets
+func (s *Storage) moveCallbackSecrets(secrets map[string]string) error {
+	if err := s.savePrivateState(callbackSecretsKey, secrets); err != nil {
+		return err
+	}
+
+	messages, err := s.LoadQueue()
+	if err != nil {
+		return err
+	}
+	for i := range messages {
+		if _, ok := secrets[messages[i].ID]; ok && messages[i].Callback != nil {
+			messages[i].Callback.Signed = true
+		}
+	}
+	if err := s.SaveQueue(messages); err != nil {
+		return err
+	}
+
+	jobs, err := s.LoadJobStatus()
+	if err != nil {
+		return err
+	}
+	for id, job := range jobs {
+		if _, ok := secrets[id]; ok && job.Callback != nil {
+			job.Callback.Signed = true
+		}
+	}
+	return s.SaveJobStatus(jobs)
+}
+
+// readJSON decodes a file, it returns false when the file does not exist
+func readJSON(path string, value interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, value)
 }
 
 // SaveQueue persists the queue messages to storage
@@ -33,15 +201,8 @@ func (s *Storage) SaveQueue(messages []Message) error {
 		return fmt.Errorf("failed to marshal queue data: %w", err)
 	}
 
-	// Write to temporary file first to avoid corruption
-	tempFile := s.queuePath + ".tmp"
-	if err := os.WriteFile(tempFile, data, 0644); err != nil {
-		return fmt.Errorf("failed to write queue data to temp file: %w", err)
-	}
-
-	// Rename temp file to actual file (atomic operation)
-	if err := os.Rename(tempFile, s.queuePath); err != nil {
-		return fmt.Errorf("failed to rename temp file: %w", err)
+	if err := writeAtomic(s.queuePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write queue data: %w", err)
 	}
 
 	return nil
@@ -77,15 +238,8 @@ func (s *Storage) SaveJobStatus(jobStatus map[string]Message) error {
 		return fmt.Errorf("failed to marshal job status data: %w", err)
 	}
 
-	// Write to temporary file first to avoid corruption
-	tempFile := s.jobStatusPath + ".tmp"
-	if err := os.WriteFile(tempFile, data, 0644); err != nil {
-		return fmt.Errorf("failed to write job status data to temp file: %w", err)
-	}
-
-	// Rename temp file to actual file (atomic operation)
-	if err := os.Rename(tempFile, s.jobStatusPath); err != nil {
-		return fmt.Errorf("failed to rename temp file: %w", err)
+	if err := writeAtomic(s.jobStatusPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write job status data: %w", err)
 	}
 
 	return nil
@@ -113,3 +267,63 @@ func (s *Storage) LoadJobStatus() (map[string]Message, error) {
 
 	return jobStatus, nil
 }
+
+// SaveState persists a named state document (e.g. webhook deliveries) next to the queue data
+func (s *Storage) SaveState(key string, state interface{}) error {
+	return s.saveState(key, state, 0644)
+}
+
+// savePrivateState persists a state document holding secrets, only the server user can read it
+func (s *Storage) savePrivateState(key string, state interface{}) error {
+	return s.saveState(key, state, 0600)
+}
+
+func (s *Storage) saveState(key string, state interface{}, perm os.FileMode) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s state: %w", key, err)
+	}
+
+	if err := writeAtomic(s.statePath(key), data, perm); err != nil {
+		return fmt.Errorf("failed to write %s state: %w", key, err)
+	}
+
+	return nil
+}
+
+// LoadState loads a named state document into state
+// Returns false when the state has never been saved
+func (s *Storage) LoadState(key string, state interface{}) (bool, error) {
+	data, err := os.ReadFile(s.statePath(key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s state from storage: %w", key, err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return false, fmt.Errorf("failed to unmarshal %s state: %w", key, err)
+	}
+
+	return true, nil
+}
+
+func (s *Storage) statePath(key string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s-%s.json", s.name, key))
+}
+
+// writeAtomic writes data to a temporary file first and renames it to avoid corruption
+func writeAtomic(path string, data []byte, perm os.FileMode) error {
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, data, perm); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	// Rename temp file to actual file (atomic operation)
+	if err := os.Rename(tempFile, path); err != nil {
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+
+	return nil
+}
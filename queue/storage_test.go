@@ -0,0 +1,152 @@
+package queue
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPayloadsSurviveRestart(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     []byte
+		contentType string
+		result      []byte
+	}{
+		{"text", []byte(`{"image":"cat.png"}`), "application/json", []byte(`{"labels":["cat"]}`)},
+		{"binary", []byte{0x89, 'P', 'N', 'G', 0x00, 0xff}, "image/png", []byte{0x00, 0x01, 0xfe}},
+		{"empty", []byte{}, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			q, err := NewQueue("test", dir)
+			if err != nil {
+				t.Fatalf("NewQueue: %v", err)
+			}
+			if err := q.Push(Message{ID: "finished", Payload: tt.payload, ContentType: tt.contentType}); err != nil {
+				t.Fatalf("Push: %v", err)
+			}
+			if err := q.Push(Message{ID: "queued", Payload: tt.payload, ContentType: tt.contentType}); err != nil {
+				t.Fatalf("Push: %v", err)
+			}
+			job := popJob(t, q, "worker-1", JobFilter{})
+			if err := q.Complete(job.ID, job.LeaseToken, JobResult{Payload: tt.result, ContentType: "application/octet-stream"}); err != nil {
+				t.Fatalf("Complete: %v", err)
+			}
+
+			restarted, err := NewQueue("test", dir)
+			if err != nil {
+				t.Fatalf("NewQueue after restart: %v", err)
+			}
+			next, err := restarted.Pop("worker-1", JobFilter{})
+			if err != nil || next == nil {
+				t.Fatalf("Pop after restart = %v, %v", next, err)
+			}
+			if next.ID != "queued" || !bytes.Equal(next.Payload, tt.payload) || next.ContentType != tt.contentType {
+				t.Errorf("queued job = %s %q (%s), want queued %q (%s)", next.ID, next.Payload, next.ContentType, tt.payload, tt.contentType)
+			}
+			finished, err := restarted.GetStatusManager().GetJobStatus("finished")
+			if err != nil {
+				t.Fatalf("GetJobStatus: %v", err)
+			}
+			if !bytes.Equal(finished.Result, tt.result) || finished.ResultContentType != "application/octet-stream" {
+				t.Errorf("result = %q (%s), want %q", finished.Result, finished.ResultContentType, tt.result)
+			}
+		})
+	}
+}
+
+func TestMigrateStringPayloads(t *testing.T) {
+	dir := t.TempDir()
+	created := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339Nano)
+
+	// Files as saved by format version 1, with payloads and results as plain strings
+	queued := `{"id":"queued","payload":"{\"image\":\"cat.png\"}","status":"pending","created_at":"` + created + `","updated_at":"` + created + `"}`
+	done := `{"id":"done","payload":"resize","status":"completed","result":"done: 200x200","created_at":"` + created + `","updated_at":"` + created + `"}`
+	writeFile(t, filepath.Join(dir, "test.json"), `[`+queued+`]`)
+	writeFile(t, filepath.Join(dir, "test-jobstatus.json"), `{"queued":`+queued+`,"done":`+done+`}`)
+
+	// The second start must read the converted files as they are
+	for start := 1; start <= 2; start++ {
+		q, err := NewQueue("test", dir)
+		if err != nil {
+			t.Fatalf("start %d: NewQueue: %v", start, err)
+		}
+		finished, err := q.GetStatusManager().GetJobStatus("done")
+		if err != nil {
+			t.Fatalf("start %d: GetJobStatus: %v", start, err)
+		}
+		if string(finished.Payload) != "resize" || string(finished.Result) != "done: 200x200" {
+			t.Errorf("start %d: finished job = %q with result %q, want resize with done: 200x200", start, finished.Payload, finished.Result)
+		}
+		pending, err := q.GetStatusManager().GetJobStatus("queued")
+		if err != nil {
+			t.Fatalf("start %d: GetJobStatus: %v", start, err)
+		}
+		if string(pending.Payload) != `{"image":"cat.png"}` || pending.Result != nil {
+			t.Errorf("start %d: queued job = %q with result %q, want the JSON payload and no result", start, pending.Payload, pending.Result)
+		}
+	}
+
+	q, err := NewQueue("test", dir)
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+	if job := popJob(t, q, "worker-1", JobFilter{}); string(job.Payload) != `{"image":"cat.png"}` {
+		t.Errorf("popped payload = %q, want the JSON payload", job.Payload)
+	}
+}
+
+func TestMigrateCallbackSecrets(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string // Content of the format state, none for version 1
+		payload string
+	}{
+		{"version 1", "", `"resize"`},
+		{"version 2", "2", `"cmVzaXpl"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.format != "" {
+				writeFile(t, filepath.Join(dir, "test-format.json"), tt.format)
+			}
+			queued := `{"id":"queued","payload":` + tt.payload + `,"status":"pending","callback":{"url":"http://example.com/a","secret":"secret-a"}}`
+			done := `{"id":"done","payload":` + tt.payload + `,"status":"completed","callback":{"url":"http://example.com/b","secret":"secret-b"}}`
+			plain := `{"id":"plain","payload":` + tt.payload + `,"status":"completed","callback":{"url":"http://example.com/c"}}`
+			writeFile(t, filepath.Join(dir, "test.json"), `[`+queued+`]`)
+			writeFile(t, filepath.Join(dir, "test-jobstatus.json"), `{"queued":`+queued+`,"done":`+done+`,"plain":`+plain+`}`)
+
+			q, err := NewQueue("test", dir)
+			if err != nil {
+				t.Fatalf("NewQueue: %v", err)
+			}
+			for id, want := range map[string]string{"queued": "secret-a", "done": "secret-b", "plain": ""} {
+				job, err := q.GetStatusManager().GetJobStatus(id)
+				if err != nil {
+					t.Fatalf("GetJobStatus %s: %v", id, err)
+				}
+				secret, _ := q.GetCallbackSecrets().Get(id)
+				if secret != want || job.Callback.Signed != (want != "") || string(job.Payload) != "resize" {
+					t.Errorf("%s: secret %q, signed %v, payload %q, want secret %q", id, secret, job.Callback.Signed, job.Payload, want)
+				}
+			}
+			for _, name := range []string{"test.json", "test-jobstatus.json"} {
+				if data, _ := os.ReadFile(filepath.Join(dir, name)); bytes.Contains(data, []byte("secret-")) {
+					t.Errorf("%s still holds a secret: %s", name, data)
+				}
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
@@ -0,0 +1,211 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/PAFFx/job-poll-queue/ratelimit"
+)
+
+// tenantsKey names the persisted tenants in storage
+const tenantsKey = "tenants"
+
+var (
+	// ErrTenantNotFound is returned for tenants without a stored configuration
+	ErrTenantNotFound = errors.New("tenant not found")
+	// ErrQuotaExceeded is wrapped by every QuotaError
+	ErrQuotaExceeded = errors.New("tenant quota exceeded")
+)
+
+// Quota names reported by QuotaError
+const (
+	QuotaPendingJobs = "pending_jobs"
+	QuotaStoredBytes = "stored_bytes"
+	QuotaSubmitRate  = "submit_rate"
+)
+
+// Quota limits what a tenant may keep in the queue, zero fields are unlimited
+type Quota struct {
+	MaxPendingJobs int     `json:"max_pending_jobs,omitempty"`
+	MaxStoredBytes int64   `json:"max_stored_bytes,omitempty"` // Payloads and results of tracked jobs
+	SubmitRate     float64 `json:"submit_rate,omitempty"`      // Jobs per second
+	SubmitBurst    int     `json:"submit_burst,omitempty"`     // Defaults to the rate rounded up
+}
+
+// Validate checks the limits are not negative
+func (q Quota) Validate() error {
+	if q.MaxPendingJobs < 0 || q.MaxStoredBytes < 0 || q.SubmitRate < 0 || q.SubmitBurst < 0 {
+		return errors.New("quota limits must not be negative")
+	}
+	if math.IsInf(q.SubmitRate, 0) || math.IsNaN(q.SubmitRate) {
+		return errors.New("submit_rate must be a finite number")
+	}
+	return nil
+}
+
+// submitLimit returns the submit rate as a token bucket limit
+func (q Quota) submitLimit() ratelimit.Limit {
+	return ratelimit.Limit{Rate: q.SubmitRate, Burst: q.SubmitBurst}
+}
+
+// Tenant is a team sharing the deployment, its jobs form a namespace no other tenant can see
+type Tenant struct {
+	ID        string    `json:"id"`
+	Quota     Quota     `json:"quota"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TenantUsage is what a tenant currently holds in the queue
+type TenantUsage struct {
+	PendingJobs int   `json:"pending_jobs"`
+	StoredBytes int64 `json:"stored_bytes"`
+}
+
+// QuotaError reports a job rejected because its tenant is over a quota
+type QuotaError struct {
+	Tenant     string
+	Quota      string        // pending_jobs, stored_bytes or submit_rate
+	Limit      float64       // The configured limit
+	RetryAfter time.Duration // When another submission may succeed, zero unless the rate was exceeded
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("tenant %s exceeded its %s quota of %g", e.Tenant, e.Quota, e.Limit)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// SetDefaultQuota sets the quota of tenants without a stored configuration
+func (q *Queue) SetDefaultQuota(quota Quota) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.defaultQuota = quota
+}
+
+// SetTenant creates or updates a tenant's quota and persists it
+func (q *Queue) SetTenant(id string, quota Quota) (*Tenant, error) {
+	if id == "" {
+		return nil, errors.New("tenant ID is required")
+	}
+	if err := quota.Validate(); err != nil {
+		return nil, err
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	now := time.Now()
+	previous, ok := q.tenants[id]
+	tenant := previous
+	if !ok {
+		tenant = Tenant{ID: id, CreatedAt: now}
+	}
+	tenant.Quota = quota
+	tenant.UpdatedAt = now
+
+	q.tenants[id] = tenant
+	if err := q.storage.SaveState(tenantsKey, q.tenantListLocked()); err != nil {
+		if ok {
+			q.tenants[id] = previous
+		} else {
+			delete(q.tenants, id)
+		}
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// GetTenant returns a stored tenant
+func (q *Queue) GetTenant(id string) (*Tenant, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	tenant, ok := q.tenants[id]
+	if !ok {
+		return nil, ErrTenantNotFound
+	}
+	return &tenant, nil
+}
+
+// ListTenants returns every stored tenant ordered by ID
+func (q *Queue) ListTenants() []Tenant {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.tenantListLocked()
+}
+
+// DeleteTenant removes a tenant's configuration, its jobs are kept and fall back to the default quota
+func (q *Queue) DeleteTenant(id string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if _, ok := q.tenants[id]; !ok {
+		return ErrTenantNotFound
+	}
+	delete(q.tenants, id)
+	delete(q.buckets, id)
+	return q.storage.SaveState(tenantsKey, q.tenantListLocked())
+}
+
+// TenantQuota returns the quota enforced for a tenant
+func (q *Queue) TenantQuota(id string) Quota {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.quotaLocked(id)
+}
+
+func (q *Queue) quotaLocked(id string) Quota {
+	if tenant, ok := q.tenants[id]; ok {
+		return tenant.Quota
+	}
+	return q.defaultQuota
+}
+
+func (q *Queue) tenantListLocked() []Tenant {
+	tenants := make([]Tenant, 0, len(q.tenants))
+	for _, tenant := range q.tenants {
+		tenants = append(tenants, tenant)
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].ID < tenants[j].ID
+	})
+	return tenants
+}
+
+// checkQuotaLocked rejects a job that would take its tenant over a quota
+// Jobs without a tenant are not limited; must be called with the lock held
+func (q *Queue) checkQuotaLocked(msg *Message) error {
+	if msg.Tenant == "" {
+		return nil
+	}
+	quota := q.quotaLocked(msg.Tenant)
+
+	if quota.MaxPendingJobs > 0 || quota.MaxStoredBytes > 0 {
+		usage := q.statusMgr.TenantUsage(msg.Tenant)
+		if quota.MaxPendingJobs > 0 && usage.PendingJobs+1 > quota.MaxPendingJobs {
+			return &QuotaError{Tenant: msg.Tenant, Quota: QuotaPendingJobs, Limit: float64(quota.MaxPendingJobs)}
+		}
+		if quota.MaxStoredBytes > 0 && usage.StoredBytes+int64(len(msg.Payload)) > quota.MaxStoredBytes {
+			return &QuotaError{Tenant: msg.Tenant, Quota: QuotaStoredBytes, Limit: float64(quota.MaxStoredBytes)}
+		}
+	}
+
+	// The rate is checked last so rejected jobs do not use up the tenant's tokens
+	if limit := quota.submitLimit(); limit.Enabled() {
+		bucket, ok := q.buckets[msg.Tenant]
+		if !ok {
+			bucket = &ratelimit.Bucket{}
+			q.buckets[msg.Tenant] = bucket
+		}
+		if wait := bucket.Take(limit, time.Now()); wait > 0 {
+			return &QuotaError{Tenant: msg.Tenant, Quota: QuotaSubmitRate, Limit: quota.SubmitRate, RetryAfter: wait}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// waiter is a worker blocked in PopWait
+type waiter struct {
+	workerID string
+	filter   JobFilter
+	job      chan *Message // Buffered, receives the job handed over by dispatchLocked
+}
+
+// PopWait removes and returns the next message matching the filter like Pop,
+// but waits for one while none is due, the queue is paused or the worker is draining
+// Waiting workers are served in arrival order; nil is returned once the context ends
+func (q *Queue) PopWait(ctx context.Context, workerID string, filter JobFilter) (*Message, error) {
+	q.mutex.Lock()
+	if err := q.seeConsumerLocked(workerID, filter.Tenant, time.Now()); err != nil {
+		q.mutex.Unlock()
+		return nil, err
+	}
+	w := &waiter{workerID: workerID, filter: filter, job: make(chan *Message, 1)}
+	q.waiters = append(q.waiters, w)
+	err := q.dispatchLocked()
+	q.mutex.Unlock()
+	if err != nil {
+		q.removeWaiter(w)
+		return nil, err
+	}
+
+	select {
+	case msg := <-w.job:
+		return msg, nil
+	case <-ctx.Done():
+	}
+
+	// A job may have been handed over while the context ended, it is returned rather than lost
+	q.removeWaiter(w)
+	q.TouchWorker(workerID)
+	select {
+	case msg := <-w.job:
+		return msg, nil
+	default:
+		return nil, nil
+	}
+}
+
+// removeWaiter stops handing jobs to a waiter
+func (q *Queue) removeWaiter(w *waiter) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for i, other := range q.waiters {
+		if other == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// dispatch hands due jobs to waiting workers, it runs when a scheduled job becomes due
+func (q *Queue) dispatch() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	_ = q.dispatchLocked()
+}
+
+// dispatchLocked hands due jobs to waiting workers in arrival order, must be called with the lock held
+// after jobs are queued, rescheduled or consumption resumes
+func (q *Queue) dispatchLocked() error {
+	if q.settings.Paused || len(q.waiters) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	taken := false
+	remaining := q.waiters[:0]
+	var startErr error
+	for i, w := range q.waiters {
+		index := -1
+		if !q.workerDrainingLocked(w.workerID) {
+			index = q.nextIndex(now, w.filter.takes)
+		}
+		if index < 0 {
+			remaining = append(remaining, w)
+			continue
+		}
+		msg, err := q.takeLocked(index, now, w.workerID)
+		if err != nil {
+			// The job stays queued and the workers keep waiting for the next dispatch
+			startErr = err
+			remaining = append(remaining, q.waiters[i:]...)
+			break
+		}
+		w.job <- msg
+		taken = true
+	}
+	clear(q.waiters[len(remaining):])
+	q.waiters = remaining
+
+	q.scheduleWakeupLocked(now)
+	if taken {
+		if err := q.storage.SaveQueue(q.messages); err != nil {
+			return err
+		}
+	}
+	return startErr
+}
+
+// scheduleWakeupLocked arms a timer for the earliest scheduled job while workers wait
+// Must be called with the lock held
+func (q *Queue) scheduleWakeupLocked(now time.Time) {
+	if q.wakeup != nil {
+		q.wakeup.Stop()
+		q.wakeup = nil
+	}
+	if len(q.waiters) == 0 {
+		return
+	}
+
+	var next *time.Time
+	for i := range q.messages {
+		scheduledAt := q.messages[i].ScheduledAt
+		if scheduledAt != nil && scheduledAt.After(now) && (next == nil || scheduledAt.Before(*next)) {
+			next = scheduledAt
+		}
+	}
+	if next != nil {
+		q.wakeup = time.AfterFunc(next.Sub(now), q.dispatch)
+	}
+}
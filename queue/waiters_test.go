@@ -0,0 +1,28 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPushSucceedsWhenDispatchFails(t *testing.T) {
+	q := newTestQueue(t)
+
+	// A queued job without a status record cannot be handed to the waiting worker
+	q.messages = append(q.messages, Message{ID: "orphan", Status: JobStatusPending, CreatedAt: time.Now()})
+	w := &waiter{workerID: "worker-1", job: make(chan *Message, 1)}
+	q.waiters = append(q.waiters, w)
+
+	if err := q.Push(Message{ID: "job", Payload: []byte("x")}); err != nil {
+		t.Fatalf("Push = %v, want the job queued", err)
+	}
+	if len(q.messages) != 2 || q.messages[1].ID != "job" {
+		t.Errorf("queue = %+v, want the orphan and the job", q.messages)
+	}
+	if status, err := q.GetStatusManager().GetJobStatus("job"); err != nil || status.Status != JobStatusPending {
+		t.Errorf("status = %+v, %v, want pending", status, err)
+	}
+	if len(q.waiters) != 1 {
+		t.Errorf("waiters = %d, want the worker still waiting", len(q.waiters))
+	}
+}
@@ -0,0 +1,269 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// throughputWindow is how far back completions count towards a worker's throughput
+const throughputWindow = 5 * time.Minute
+
+// Limits on what a worker may report about itself
+const (
+	maxWorkerIDLength = 128
+	maxWorkerLabels   = 64
+)
+
+var (
+	// ErrWorkerNotFound is returned for worker IDs the queue has never seen
+	ErrWorkerNotFound = errors.New("worker not found")
+	// ErrWorkerTenant is returned when a worker ID is already used by a worker of another tenant
+	ErrWorkerTenant = errors.New("worker ID is registered by another tenant")
+)
+
+// WorkerInfo is what a worker reports about itself
+type WorkerInfo struct {
+	ID       string            `json:"worker_id"`
+	Hostname string            `json:"hostname,omitempty"`
+	Version  string            `json:"version,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+// Validate checks the worker has an ID and a bounded number of labels
+func (w WorkerInfo) Validate() error {
+	if w.ID == "" {
+		return errors.New("worker_id is required")
+	}
+	if len(w.ID) > maxWorkerIDLength {
+		return fmt.Errorf("worker_id must not be longer than %d characters", maxWorkerIDLength)
+	}
+	if len(w.Labels) > maxWorkerLabels {
+		return fmt.Errorf("a worker may not have more than %d labels", maxWorkerLabels)
+	}
+	return nil
+}
+
+// Worker is a worker known to the queue, with its liveness and throughput
+type Worker struct {
+	WorkerInfo
+	Tenant       string    `json:"tenant,omitempty"` // Tenant of the credentials the worker uses
+	RegisteredAt time.Time `json:"registered_at"`
+	LastSeen     time.Time `json:"last_seen"`
+	Draining     bool      `json:"draining"`   // Drained workers finish their jobs but are not given new ones
+	Online       bool      `json:"online"`     // Connected, waiting for a job or seen within the worker timeout
+	Sessions     int       `json:"sessions"`   // Open Connect streams
+	Completed    int       `json:"completed"`  // Jobs completed since the worker was first seen
+	Throughput   float64   `json:"throughput"` // Jobs completed per minute over the last five minutes
+}
+
+// workerState is a registry entry, Worker fields are kept up to date except the computed ones
+type workerState struct {
+	Worker
+	completions []time.Time // Completion times within the throughput window
+}
+
+// SetWorkerTimeout sets how long after it was last seen an idle worker is reported offline
+func (q *Queue) SetWorkerTimeout(timeout time.Duration) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.workerTimeout = timeout
+}
+
+// RegisterWorker records a worker's identity, or updates it when the worker is already known
+// Draining and counters are kept across registrations
+func (q *Queue) RegisterWorker(info WorkerInfo, tenant string) (*Worker, error) {
+	if err := info.Validate(); err != nil {
+		return nil, err
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	now := time.Now()
+	state, err := q.seeWorkerLocked(info.ID, tenant, now)
+	if err != nil {
+		return nil, err
+	}
+	state.Hostname = info.Hostname
+	state.Version = info.Version
+	state.Labels = info.Labels
+	return q.snapshotLocked(state, now), nil
+}
+
+// ConnectWorker registers a worker opening a Connect stream, DisconnectWorker must be called when it ends
+func (q *Queue) ConnectWorker(info WorkerInfo, tenant string) (*Worker, error) {
+	worker, err := q.RegisterWorker(info, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.workers[info.ID].Sessions++
+	worker.Sessions++
+	return worker, nil
+}
+
+// DisconnectWorker records the end of a Connect stream
+func (q *Queue) DisconnectWorker(workerID string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if state, ok := q.workers[workerID]; ok && state.Sessions > 0 {
+		state.Sessions--
+		state.LastSeen = time.Now()
+	}
+}
+
+// TouchWorker records that a known worker is alive
+func (q *Queue) TouchWorker(workerID string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.touchWorkerLocked(workerID, time.Now())
+}
+
+// Workers returns the workers of a tenant, or every worker when it is empty, ordered by ID
+func (q *Queue) Workers(tenant string) []Worker {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	now := time.Now()
+	workers := make([]Worker, 0, len(q.workers))
+	for _, state := range q.workers {
+		if tenant == "" || state.Tenant == tenant {
+			workers = append(workers, *q.snapshotLocked(state, now))
+		}
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].ID < workers[j].ID })
+	return workers
+}
+
+// GetWorker returns a known worker
+func (q *Queue) GetWorker(workerID string) (*Worker, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	state, ok := q.workers[workerID]
+	if !ok {
+		return nil, ErrWorkerNotFound
+	}
+	return q.snapshotLocked(state, time.Now()), nil
+}
+
+// DrainWorker stops or resumes giving jobs to a worker, the jobs it holds are not affected
+// Polls of a drained worker find no job and its long polls keep waiting, like in a paused queue
+func (q *Queue) DrainWorker(workerID string, draining bool) (*Worker, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	state, ok := q.workers[workerID]
+	if !ok {
+		return nil, ErrWorkerNotFound
+	}
+	state.Draining = draining
+	if err := q.dispatchLocked(); err != nil {
+		return nil, err
+	}
+	return q.snapshotLocked(state, time.Now()), nil
+}
+
+// ReleaseWorker puts every job leased to a worker back in the queue, for workers that died
+// It returns the IDs of the released jobs; the worker stays registered
+func (q *Queue) ReleaseWorker(workerID string) ([]string, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	held, _ := q.statusMgr.ListJobs(JobFilter{Statuses: []JobStatus{JobStatusProcessing}, WorkerID: workerID}, nil, q.statusMgr.CountTotalJobs())
+	if _, ok := q.workers[workerID]; !ok && len(held) == 0 {
+		return nil, ErrWorkerNotFound
+	}
+
+	released := make([]string, 0, len(held))
+	for _, job := range held {
+		if err := q.releaseLocked(job.ID); err == nil {
+			released = append(released, job.ID)
+		}
+	}
+	if len(released) == 0 {
+		return released, nil
+	}
+
+	if err := q.storage.SaveQueue(q.messages); err != nil {
+		return released, err
+	}
+	return released, q.dispatchLocked()
+}
+
+// seeWorkerLocked returns the registry entry of a worker, creating it on first sight, and marks it seen
+// Must be called with the lock held
+func (q *Queue) seeWorkerLocked(workerID string, tenant string, now time.Time) (*workerState, error) {
+	state, ok := q.workers[workerID]
+	if !ok {
+		state = &workerState{Worker: Worker{
+			WorkerInfo:   WorkerInfo{ID: workerID},
+			Tenant:       tenant,
+			RegisteredAt: now,
+		}}
+		q.workers[workerID] = state
+	} else if state.Tenant != tenant {
+		return nil, ErrWorkerTenant
+	}
+	state.LastSeen = now
+	return state, nil
+}
+
+// seeConsumerLocked marks a worker taking jobs seen, anonymous consumers are not tracked
+// Must be called with the lock held
+func (q *Queue) seeConsumerLocked(workerID string, tenant string, now time.Time) error {
+	if workerID == "" {
+		return nil
+	}
+	_, err := q.seeWorkerLocked(workerID, tenant, now)
+	return err
+}
+
+// touchWorkerLocked marks a known worker seen, must be called with the lock held
+func (q *Queue) touchWorkerLocked(workerID string, now time.Time) {
+	if state, ok := q.workers[workerID]; ok {
+		state.LastSeen = now
+	}
+}
+
+// workerDrainingLocked reports whether jobs must not be given to a worker, must be called with the lock held
+func (q *Queue) workerDrainingLocked(workerID string) bool {
+	state, ok := q.workers[workerID]
+	return ok && state.Draining
+}
+
+// recordCompletionLocked counts a job completed by a worker, must be called with the lock held
+func (q *Queue) recordCompletionLocked(workerID string, now time.Time) {
+	state, ok := q.workers[workerID]
+	if !ok {
+		return
+	}
+	state.LastSeen = now
+	state.Completed++
+	state.completions = append(state.completions, now)
+}
+
+// snapshotLocked copies a worker with its computed fields, must be called with the lock held
+func (q *Queue) snapshotLocked(state *workerState, now time.Time) *Worker {
+	// Forget completions that left the throughput window
+	cutoff := now.Add(-throughputWindow)
+	kept := 0
+	for kept < len(state.completions) && state.completions[kept].Before(cutoff) {
+		kept++
+	}
+	state.completions = state.completions[kept:]
+
+	worker := state.Worker
+	worker.Throughput = float64(len(state.completions)) / throughputWindow.Minutes()
+	worker.Online = worker.Sessions > 0 || now.Sub(worker.LastSeen) < q.workerTimeout
+	for _, w := range q.waiters {
+		if w.workerID == worker.ID {
+			worker.Online = true
+		}
+	}
+	return &worker
+}
@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket rate, a zero rate is unlimited
+type Limit struct {
+	Rate  float64 // Tokens added per second
+	Burst int     // Bucket size, defaults to the rate rounded up
+}
+
+// Enabled reports whether the limit restricts anything
+func (l Limit) Enabled() bool {
+	return l.Rate > 0
+}
+
+func (l Limit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return math.Max(1, math.Ceil(l.Rate))
+}
+
+// Bucket is the state of one token bucket, the zero value is a full bucket
+type Bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Take removes one token and returns zero, or returns how long until a token is available
+// Nothing is taken when the bucket is empty
+func (b *Bucket) Take(limit Limit, now time.Time) time.Duration {
+	burst := limit.burst()
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
+
+// full reports whether the bucket has refilled completely, so forgetting it changes nothing
+func (b *Bucket) full(limit Limit, now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= limit.burst()
+}
+
+// sweepInterval is how often idle buckets are dropped
+const sweepInterval = time.Minute
+
+// Limiter keeps one token bucket per key, such as an API key or a client IP
+type Limiter struct {
+	limit     Limit
+	buckets   map[string]*Bucket
+	lastSweep time.Time
+	mutex     sync.Mutex
+}
+
+// NewLimiter creates a limiter applying the same limit to every key
+func NewLimiter(limit Limit) *Limiter {
+	return &Limiter{limit: limit, buckets: make(map[string]*Bucket), lastSweep: time.Now()}
+}
+
+// Enabled reports whether the limiter restricts anything, nil limiters do not
+func (l *Limiter) Enabled() bool {
+	return l != nil && l.limit.Enabled()
+}
+
+// Allow takes a token for the key and returns zero, or returns how long the caller should wait
+func (l *Limiter) Allow(key string) time.Duration {
+	if !l.Enabled() {
+		return 0
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweepLocked(now)
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &Bucket{}
+		l.buckets[key] = bucket
+	}
+	return bucket.Take(l.limit, now)
+}
+
+// sweepLocked drops full buckets so idle clients do not use memory, must be called with the lock held
+func (l *Limiter) sweepLocked(now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.full(l.limit, now) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
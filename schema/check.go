@@ -0,0 +1,88 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// knownTypes are the JSON Schema type names
+var knownTypes = []string{TypeNull, TypeBoolean, TypeObject, TypeArray, TypeNumber, TypeInteger, TypeString}
+
+// Check reports schemas that cannot be used for validation:
+// unknown types, invalid patterns and references that do not resolve
+func (s *Schema) Check() error {
+	return s.check(s, "#")
+}
+
+func (s *Schema) check(root *Schema, location string) error {
+	if s == nil || s.never {
+		return nil
+	}
+
+	for _, name := range s.Type {
+		if !containsString(knownTypes, name) {
+			return fmt.Errorf("%s: unknown type %q", location, name)
+		}
+	}
+	if s.Pattern != "" {
+		if _, err := regexp.Compile(s.Pattern); err != nil {
+			return fmt.Errorf("%s: invalid pattern: %v", location, err)
+		}
+	}
+	if s.Ref != "" && root.resolve(s.Ref) == nil {
+		return fmt.Errorf("%s: cannot resolve $ref %q, only local references are supported", location, s.Ref)
+	}
+
+	children := map[string]*Schema{
+		location + "/items":                s.Items,
+		location + "/additionalProperties": s.AdditionalProperties,
+	}
+	for name, child := range s.Properties {
+		children[location+"/properties/"+escapePointer(name)] = child
+	}
+	for name, child := range s.Defs {
+		children[location+"/$defs/"+escapePointer(name)] = child
+	}
+	for name, child := range s.Definitions {
+		children[location+"/definitions/"+escapePointer(name)] = child
+	}
+	for keyword, list := range map[string][]*Schema{"allOf": s.AllOf, "anyOf": s.AnyOf, "oneOf": s.OneOf} {
+		for i, child := range list {
+			children[fmt.Sprintf("%s/%s/%d", location, keyword, i)] = child
+		}
+	}
+
+	for childLocation, child := range children {
+		if err := child.check(root, childLocation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve finds the target of a local reference, nil when it does not resolve
+func (s *Schema) resolve(ref string) *Schema {
+	if ref == "#" {
+		return s
+	}
+	for prefix, defs := range map[string]map[string]*Schema{"#/$defs/": s.Defs, "#/definitions/": s.Definitions} {
+		if name, ok := strings.CutPrefix(ref, prefix); ok {
+			return defs[unescapePointer(name)]
+		}
+	}
+	return nil
+}
+
+func unescapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// Schema is the subset of JSON Schema (draft 2020-12) used to describe and validate JSON values
+// It is also the schema object of OpenAPI 3.1 documents
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"` // Local references (#, #/$defs/..., #/definitions/...) are resolved
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"` // Draft 7 name of $defs
+	Type                 Types              `json:"type,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+
+	never bool // The boolean schema false, nothing is valid against it
+}
+
+// Types lists the JSON types a value may have, it is written as a string when there is only one
+type Types []string
+
+// JSON type names
+const (
+	TypeNull    = "null"
+	TypeBoolean = "boolean"
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeString  = "string"
+)
+
+// False returns the schema no value is valid against, as used for additionalProperties: false
+func False() *Schema {
+	return &Schema{never: true}
+}
+
+// plainSchema has the fields of Schema without its JSON methods
+type plainSchema Schema
+
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	if s.never {
+		return []byte("false"), nil
+	}
+	return json.Marshal((*plainSchema)(s))
+}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	switch string(bytes.TrimSpace(data)) {
+	case "true":
+		*s = Schema{}
+		return nil
+	case "false":
+		*s = Schema{never: true}
+		return nil
+	}
+	return json.Unmarshal(data, (*plainSchema)(s))
+}
+
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = Types{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("schema type must be a string or an array of strings")
+	}
+	*t = list
+	return nil
+}
+
+// Has reports whether the type list allows the given type
+func (t Types) Has(name string) bool {
+	for _, value := range t {
+		if value == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,282 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// FieldError describes why a value, or a part of it, does not match a schema
+type FieldError struct {
+	Path    string `json:"path"` // JSON Pointer to the invalid value, empty for the value itself
+	Message string `json:"message"`
+}
+
+// Validate checks a value decoded by encoding/json against the schema
+// It returns every violation found, nil when the value is valid
+func (s *Schema) Validate(value interface{}) []FieldError {
+	v := &validator{root: s}
+	v.validate(s, value, "")
+	return v.errs
+}
+
+// ValidateJSON decodes a JSON document and validates it
+func (s *Schema) ValidateJSON(data []byte) ([]FieldError, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return s.Validate(value), nil
+}
+
+// validator walks a value and its schema, resolving references against the root schema
+type validator struct {
+	root  *Schema
+	errs  []FieldError
+	depth int // Nested references being followed
+}
+
+// maxRefDepth stops schemas that reference themselves without descending into the value
+const maxRefDepth = 1000
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) validate(s *Schema, value interface{}, path string) {
+	if s == nil {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		v.fail(path, format, args...)
+	}
+
+	if s.never {
+		fail("value is not allowed")
+		return
+	}
+
+	if s.Ref != "" {
+		if target := v.root.resolve(s.Ref); target != nil {
+			if v.depth >= maxRefDepth {
+				fail("schema references are too deeply nested")
+				return
+			}
+			v.depth++
+			v.validate(target, value, path)
+			v.depth--
+		}
+	}
+
+	actual := typeOf(value)
+	if len(s.Type) > 0 && !s.Type.Has(actual) && !(actual == TypeInteger && s.Type.Has(TypeNumber)) {
+		fail("expected %s, got %s", strings.Join(s.Type, " or "), actual)
+		return
+	}
+
+	if len(s.Enum) > 0 && !s.inEnum(value) {
+		fail("must be one of %s", formatEnum(s.Enum))
+	}
+
+	switch typed := value.(type) {
+	case string:
+		s.validateString(typed, fail)
+	case float64:
+		s.validateNumber(typed, fail)
+	case map[string]interface{}:
+		v.validateObject(s, typed, path)
+	case []interface{}:
+		v.validateArray(s, typed, path)
+	}
+
+	for _, sub := range s.AllOf {
+		v.validate(sub, value, path)
+	}
+	if len(s.AnyOf) > 0 && v.countMatches(s.AnyOf, value) == 0 {
+		fail("does not match any of the allowed schemas")
+	}
+	if len(s.OneOf) > 0 && v.countMatches(s.OneOf, value) != 1 {
+		fail("must match exactly one of the allowed schemas")
+	}
+}
+
+func (s *Schema) validateString(value string, fail func(string, ...interface{})) {
+	length := utf8.RuneCountInString(value)
+	if s.MinLength != nil && length < *s.MinLength {
+		fail("must be at least %d characters long", *s.MinLength)
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		fail("must be at most %d characters long", *s.MaxLength)
+	}
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			fail("schema pattern is invalid: %v", err)
+		} else if !pattern.MatchString(value) {
+			fail("must match the pattern %s", s.Pattern)
+		}
+	}
+	if !validFormat(s.Format, value) {
+		fail("must be a valid %s", s.Format)
+	}
+}
+
+func (s *Schema) validateNumber(value float64, fail func(string, ...interface{})) {
+	if s.Minimum != nil && value < *s.Minimum {
+		fail("must be at least %s", formatNumber(*s.Minimum))
+	}
+	if s.Maximum != nil && value > *s.Maximum {
+		fail("must be at most %s", formatNumber(*s.Maximum))
+	}
+}
+
+func (v *validator) validateObject(s *Schema, value map[string]interface{}, path string) {
+	for _, name := range s.Required {
+		if _, ok := value[name]; !ok {
+			v.fail(path+"/"+escapePointer(name), "is required")
+		}
+	}
+
+	// Sorted so errors are reported in a stable order
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		childPath := path + "/" + escapePointer(name)
+		if property, ok := s.Properties[name]; ok {
+			v.validate(property, value[name], childPath)
+			continue
+		}
+		if s.AdditionalProperties != nil {
+			if s.AdditionalProperties.never {
+				v.fail(childPath, "unknown property")
+				continue
+			}
+			v.validate(s.AdditionalProperties, value[name], childPath)
+		}
+	}
+}
+
+func (v *validator) validateArray(s *Schema, value []interface{}, path string) {
+	if s.MinItems != nil && len(value) < *s.MinItems {
+		v.fail(path, "must have at least %d items", *s.MinItems)
+	}
+	if s.MaxItems != nil && len(value) > *s.MaxItems {
+		v.fail(path, "must have at most %d items", *s.MaxItems)
+	}
+	for i, item := range value {
+		v.validate(s.Items, item, path+"/"+strconv.Itoa(i))
+	}
+}
+
+func (s *Schema) inEnum(value interface{}) bool {
+	for _, allowed := range s.Enum {
+		if reflect.DeepEqual(normalize(allowed), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// countMatches counts the schemas a value is valid against, without reporting their errors
+func (v *validator) countMatches(schemas []*Schema, value interface{}) int {
+	count := 0
+	for _, sub := range schemas {
+		branch := &validator{root: v.root, depth: v.depth}
+		branch.validate(sub, value, "")
+		if len(branch.errs) == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// typeOf returns the JSON type name of a decoded value, whole numbers are integers
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return TypeNull
+	case bool:
+		return TypeBoolean
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return TypeInteger
+		}
+		return TypeNumber
+	case string:
+		return TypeString
+	case []interface{}:
+		return TypeArray
+	case map[string]interface{}:
+		return TypeObject
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// normalize converts a Go value written in a schema (such as an int enum value) to its decoded JSON form
+func normalize(value interface{}) interface{} {
+	switch value.(type) {
+	case nil, bool, float64, string, []interface{}, map[string]interface{}:
+		return value
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return value
+	}
+	return decoded
+}
+
+// validFormat checks the formats the API relies on, unknown formats are annotations only
+func validFormat(format, value string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "uri":
+		parsed, err := url.Parse(value)
+		return err == nil && parsed.Scheme != "" && parsed.Host != ""
+	case "uuid":
+		_, err := uuid.Parse(value)
+		return err == nil
+	case "email":
+		at := strings.LastIndex(value, "@")
+		return at > 0 && at < len(value)-1
+	}
+	return true
+}
+
+func formatEnum(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		data, _ := json.Marshal(value)
+		parts[i] = string(data)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// escapePointer escapes a property name for use in a JSON Pointer
+func escapePointer(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
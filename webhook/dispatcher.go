@@ -0,0 +1,397 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PAFFx/job-poll-queue/queue"
+	"github.com/google/uuid"
+)
+
+// DeliveryStatus represents the current state of a webhook delivery
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// stateKey names the persisted delivery records in storage
+const stateKey = "webhooks"
+
+// Delivery tracks the notification of one job's terminal state to its callback URL
+type Delivery struct {
+	ID             string          `json:"id"`
+	JobID          string          `json:"job_id"`
+	URL            string          `json:"url"`
+	Signed         bool            `json:"signed,omitempty"` // Signed with the job's secret in queue.CallbackSecrets
+	Body           json.RawMessage `json:"body"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// Config controls delivery retries, how many finished deliveries are kept and where callbacks may go
+type Config struct {
+	MaxAttempts    int
+	Timeout        time.Duration
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	KeepSucceeded  int // Succeeded deliveries kept, the oldest are dropped first; zero keeps all
+	KeepFailed     int // Failed deliveries kept, the oldest are dropped first; zero keeps all
+	// Hosts callback URLs may name, "*.example.com" allows subdomains; any host when empty
+	AllowedHosts []string
+}
+
+// Dispatcher delivers job results to callback URLs with retries and persisted status
+type Dispatcher struct {
+	config     Config
+	client     *http.Client
+	storage    *queue.Storage
+	secrets    *queue.CallbackSecrets
+	deliveries map[string]*Delivery
+	inFlight   map[string]bool
+	wake       chan struct{}
+	mutex      sync.Mutex
+}
+
+// ErrDeliveryNotFound is returned for unknown delivery IDs
+var ErrDeliveryNotFound = errors.New("delivery not found")
+
+// NewDispatcher creates a dispatcher and loads persisted deliveries from storage
+// Signed deliveries are signed with the secrets of their jobs' callbacks
+func NewDispatcher(storage *queue.Storage, secrets *queue.CallbackSecrets, config Config) (*Dispatcher, error) {
+	d := &Dispatcher{
+		config:     config,
+		client:     &http.Client{Timeout: config.Timeout},
+		storage:    storage,
+		secrets:    secrets,
+		deliveries: make(map[string]*Delivery),
+		inFlight:   make(map[string]bool),
+		wake:       make(chan struct{}, 1),
+	}
+
+	// Deliveries saved by older versions carry their secret, it moves to the secret store
+	var deliveries []struct {
+		*Delivery
+		Secret string `json:"secret"`
+	}
+	if _, err := storage.LoadState(stateKey, &deliveries); err != nil {
+		return nil, err
+	}
+	legacy := false
+	for _, delivery := range deliveries {
+		if delivery.Secret != "" {
+			if err := secrets.Put(delivery.JobID, delivery.Secret); err != nil {
+				return nil, err
+			}
+			delivery.Signed, legacy = true, true
+		}
+		d.deliveries[delivery.ID] = delivery.Delivery
+	}
+	if legacy {
+		d.saveLocked()
+	}
+
+	return d, nil
+}
+
+// Enqueue schedules a delivery for a job that reached a terminal state
+// Jobs without a callback are ignored
+func (d *Dispatcher) Enqueue(job queue.Message) {
+	if job.Callback == nil || job.Callback.URL == "" {
+		return
+	}
+
+	body, err := json.Marshal(newNotification(job))
+	if err != nil {
+		log.Printf("Failed to encode webhook for job %s: %v", job.ID, err)
+		return
+	}
+
+	now := time.Now()
+	d.mutex.Lock()
+	delivery := &Delivery{
+		ID:            uuid.New().String(),
+		JobID:         job.ID,
+		URL:           job.Callback.URL,
+		Signed:        job.Callback.Signed,
+		Body:          body,
+		Status:        DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	d.deliveries[delivery.ID] = delivery
+	d.saveLocked()
+	d.mutex.Unlock()
+
+	d.signal()
+}
+
+// Run delivers due webhooks until the process exits
+func (d *Dispatcher) Run() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		for _, delivery := range d.due() {
+			go d.attempt(delivery)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// List returns all deliveries, newest first, optionally filtered by status
+func (d *Dispatcher) List(status DeliveryStatus) []Delivery {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	result := make([]Delivery, 0, len(d.deliveries))
+	for _, delivery := range d.deliveries {
+		if status != "" && delivery.Status != status {
+			continue
+		}
+		result = append(result, *delivery)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
+
+// Get returns a single delivery
+func (d *Dispatcher) Get(id string) (*Delivery, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	delivery, exists := d.deliveries[id]
+	if !exists {
+		return nil, ErrDeliveryNotFound
+	}
+	result := *delivery
+	return &result, nil
+}
+
+// Replay schedules a delivery to be sent again with a fresh set of attempts
+func (d *Dispatcher) Replay(id string) (*Delivery, error) {
+	d.mutex.Lock()
+	delivery, exists := d.deliveries[id]
+	if !exists {
+		d.mutex.Unlock()
+		return nil, ErrDeliveryNotFound
+	}
+
+	now := time.Now()
+	delivery.Status = DeliveryPending
+	delivery.Attempts = 0
+	delivery.LastError = ""
+	delivery.NextAttemptAt = now
+	delivery.UpdatedAt = now
+	d.saveLocked()
+	result := *delivery
+	d.mutex.Unlock()
+
+	d.signal()
+	return &result, nil
+}
+
+// due returns the pending deliveries whose next attempt time has passed
+func (d *Dispatcher) due() []Delivery {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now()
+	var result []Delivery
+	for id, delivery := range d.deliveries {
+		if delivery.Status != DeliveryPending || d.inFlight[id] || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		d.inFlight[id] = true
+		result = append(result, *delivery)
+	}
+	return result
+}
+
+// attempt sends one delivery attempt and records the outcome
+func (d *Dispatcher) attempt(delivery Delivery) {
+	statusCode, err := d.send(delivery)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.inFlight, delivery.ID)
+
+	current, exists := d.deliveries[delivery.ID]
+	if !exists {
+		return
+	}
+
+	now := time.Now()
+	current.Attempts++
+	current.LastStatusCode = statusCode
+	current.UpdatedAt = now
+
+	switch {
+	case err == nil:
+		current.Status = DeliverySucceeded
+		current.LastError = ""
+	case current.Attempts >= d.config.MaxAttempts:
+		current.Status = DeliveryFailed
+		current.LastError = err.Error()
+	default:
+		current.LastError = err.Error()
+		current.NextAttemptAt = now.Add(d.backoff(current.Attempts))
+	}
+
+	d.saveLocked()
+}
+
+// send POSTs the delivery body with its signature headers
+func (d *Dispatcher) send(delivery Delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", delivery.ID)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Job-ID", delivery.JobID)
+	if delivery.Signed {
+		secret, ok := d.secrets.Get(delivery.JobID)
+		if !ok {
+			return 0, errors.New("callback secret is no longer stored")
+		}
+		req.Header.Set(SignatureHeader, Sign(secret, timestamp, delivery.Body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("callback responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt, doubling per attempt
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.config.InitialBackoff
+	for i := 1; i < attempts && delay < d.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.config.MaxBackoff {
+		delay = d.config.MaxBackoff
+	}
+	return delay
+}
+
+// saveLocked persists all deliveries, must be called with the lock held
+// Finished deliveries over the retention limits are dropped first
+func (d *Dispatcher) saveLocked() {
+	d.pruneLocked(DeliverySucceeded, d.config.KeepSucceeded)
+	d.pruneLocked(DeliveryFailed, d.config.KeepFailed)
+
+	deliveries := make([]*Delivery, 0, len(d.deliveries))
+	for _, delivery := range d.deliveries {
+		deliveries = append(deliveries, delivery)
+	}
+	if err := d.storage.SaveState(stateKey, deliveries); err != nil {
+		log.Printf("Failed to save webhook deliveries: %v", err)
+	}
+}
+
+// pruneLocked drops the least recently updated deliveries with the given status beyond keep
+// Must be called with the lock held
+func (d *Dispatcher) pruneLocked(status DeliveryStatus, keep int) {
+	if keep <= 0 {
+		return
+	}
+	var finished []*Delivery
+	for _, delivery := range d.deliveries {
+		if delivery.Status == status {
+			finished = append(finished, delivery)
+		}
+	}
+	if len(finished) <= keep {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].UpdatedAt.After(finished[j].UpdatedAt)
+	})
+	for _, delivery := range finished[keep:] {
+		delete(d.deliveries, delivery.ID)
+		if err := d.secrets.Delete(delivery.JobID); err != nil {
+			log.Printf("Failed to delete the callback secret of job %s: %v", delivery.JobID, err)
+		}
+	}
+}
+
+// signal wakes the run loop without blocking
+func (d *Dispatcher) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// notification is the JSON body posted to callback URLs
+type notification struct {
+	JobID           string            `json:"job_id"`
+	Status          queue.JobStatus   `json:"status"`
+	Payload         string            `json:"payload"`
+	PayloadEncoding string            `json:"payload_encoding"`
+	ContentType     string            `json:"content_type,omitempty"`
+	StatusCode      int               `json:"status_code,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	Error           string            `json:"error,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	CompletedAt     *time.Time        `json:"completed_at,omitempty"`
+}
+
+func newNotification(job queue.Message) notification {
+	n := notification{
+		JobID:       job.ID,
+		Status:      job.Status,
+		ContentType: job.ResultContentType,
+		StatusCode:  job.ResultStatusCode,
+		Headers:     job.ResultHeaders,
+		Error:       job.Error,
+		CreatedAt:   job.CreatedAt,
+		CompletedAt: job.CompletedAt,
+	}
+
+	// Binary results are base64 encoded
+	if utf8.Valid(job.Result) {
+		n.Payload, n.PayloadEncoding = string(job.Result), "text"
+	} else {
+		n.Payload, n.PayloadEncoding = base64.StdEncoding.EncodeToString(job.Result), "base64"
+	}
+	return n
+}
@@ -0,0 +1,305 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PAFFx/job-poll-queue/queue"
+)
+
+// newTestDispatcher creates a dispatcher stored in dir, a temporary directory when empty
+func newTestDispatcher(t *testing.T, dir string, config Config) *Dispatcher {
+	t.Helper()
+	if dir == "" {
+		dir = t.TempDir()
+	}
+	storage, err := queue.NewStorage("test", dir)
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	secrets, err := queue.NewCallbackSecrets(storage)
+	if err != nil {
+		t.Fatalf("NewCallbackSecrets: %v", err)
+	}
+	d, err := NewDispatcher(storage, secrets, config)
+	if err != nil {
+		t.Fatalf("NewDispatcher: %v", err)
+	}
+	return d
+}
+
+// deliverDue makes one attempt at every due delivery, as one round of Run does
+func deliverDue(d *Dispatcher) {
+	for _, delivery := range d.due() {
+		d.attempt(delivery)
+	}
+}
+
+// callbackServer answers callbacks with the given statuses in turn, repeating the last one
+type callbackServer struct {
+	*httptest.Server
+	statuses []int
+	mutex    sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newCallbackServer(t *testing.T, statuses ...int) *callbackServer {
+	s := &callbackServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mutex.Lock()
+		status := s.statuses[min(len(s.requests), len(s.statuses)-1)]
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, body)
+		s.mutex.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func finishedJob(id, url string, signed bool) queue.Message {
+	completed := time.Now()
+	return queue.Message{
+		ID:          id,
+		Status:      queue.JobStatusCompleted,
+		Result:      []byte("done"),
+		Callback:    &queue.Callback{URL: url, Signed: signed},
+		CreatedAt:   completed.Add(-time.Second),
+		CompletedAt: &completed,
+	}
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		maxAttempts  int
+		rounds       int
+		wantStatus   DeliveryStatus
+		wantAttempts int
+		wantCode     int
+	}{
+		{"first attempt succeeds", []int{http.StatusNoContent}, 3, 3, DeliverySucceeded, 1, http.StatusNoContent},
+		{"succeeds after failures", []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK}, 5, 5, DeliverySucceeded, 3, http.StatusOK},
+		{"still retrying", []int{http.StatusServiceUnavailable}, 5, 2, DeliveryPending, 2, http.StatusServiceUnavailable},
+		{"gives up after the last attempt", []int{http.StatusInternalServerError}, 3, 5, DeliveryFailed, 3, http.StatusInternalServerError},
+		{"redirects are not success", []int{http.StatusNotModified}, 1, 1, DeliveryFailed, 1, http.StatusNotModified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newCallbackServer(t, tt.statuses...)
+			d := newTestDispatcher(t, "", Config{MaxAttempts: tt.maxAttempts, Timeout: time.Second})
+			d.Enqueue(finishedJob("job-1", server.URL, false))
+
+			for range tt.rounds {
+				deliverDue(d)
+			}
+
+			deliveries := d.List("")
+			if len(deliveries) != 1 {
+				t.Fatalf("List = %d deliveries, want 1", len(deliveries))
+			}
+			got := deliveries[0]
+			if got.Status != tt.wantStatus || got.Attempts != tt.wantAttempts || got.LastStatusCode != tt.wantCode {
+				t.Errorf("delivery = %s after %d attempts with %d, want %s after %d with %d",
+					got.Status, got.Attempts, got.LastStatusCode, tt.wantStatus, tt.wantAttempts, tt.wantCode)
+			}
+			if len(server.requests) != tt.wantAttempts {
+				t.Errorf("callback received %d requests, want %d", len(server.requests), tt.wantAttempts)
+			}
+			if (got.LastError == "") != (tt.wantStatus == DeliverySucceeded) {
+				t.Errorf("last error = %q with status %s", got.LastError, got.Status)
+			}
+		})
+	}
+}
+
+func TestDeliveryWaitsForBackoff(t *testing.T) {
+	server := newCallbackServer(t, http.StatusInternalServerError)
+	d := newTestDispatcher(t, "", Config{MaxAttempts: 3, Timeout: time.Second, InitialBackoff: time.Hour, MaxBackoff: time.Hour})
+	d.Enqueue(finishedJob("job-1", server.URL, false))
+
+	deliverDue(d)
+	deliverDue(d)
+	delivery := d.List("")[0]
+	if delivery.Attempts != 1 || len(server.requests) != 1 {
+		t.Fatalf("made %d attempts before the backoff passed, want 1", delivery.Attempts)
+	}
+	if wait := time.Until(delivery.NextAttemptAt); wait < 59*time.Minute {
+		t.Errorf("next attempt in %v, want about an hour", wait)
+	}
+
+	// A replay is due at once with a fresh set of attempts
+	if _, err := d.Replay(delivery.ID); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	deliverDue(d)
+	if delivery, _ := d.Get(delivery.ID); delivery.Attempts != 1 || len(server.requests) != 2 {
+		t.Errorf("after replay: %d attempts and %d requests, want 1 and 2", delivery.Attempts, len(server.requests))
+	}
+}
+
+func TestDeliverySignature(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+	}{
+		{"signed", "s3cret"},
+		{"unsigned", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newCallbackServer(t, http.StatusOK)
+			d := newTestDispatcher(t, "", Config{MaxAttempts: 1, Timeout: time.Second})
+			if tt.secret != "" {
+				if err := d.secrets.Put("job-1", tt.secret); err != nil {
+					t.Fatalf("Put: %v", err)
+				}
+			}
+			d.Enqueue(finishedJob("job-1", server.URL, tt.secret != ""))
+			deliverDue(d)
+
+			if len(server.requests) != 1 {
+				t.Fatalf("callback received %d requests, want 1", len(server.requests))
+			}
+			req, body := server.requests[0], server.bodies[0]
+			if req.Header.Get("X-Job-ID") != "job-1" || req.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("headers = %v", req.Header)
+			}
+
+			signature := req.Header.Get(SignatureHeader)
+			if tt.secret == "" {
+				if signature != "" {
+					t.Errorf("unsigned delivery has signature %q", signature)
+				}
+			} else if !Verify(tt.secret, req.Header.Get("X-Webhook-Timestamp"), body, signature) {
+				t.Errorf("signature %q does not verify", signature)
+			}
+
+			var n notification
+			if err := json.Unmarshal(body, &n); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if n.JobID != "job-1" || n.Status != queue.JobStatusCompleted || n.Payload != "done" || n.PayloadEncoding != "text" {
+				t.Errorf("notification = %+v", n)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := &Dispatcher{config: Config{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := d.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestRetention(t *testing.T) {
+	tests := []struct {
+		name          string
+		keepSucceeded int
+		keepFailed    int
+		want          []string
+	}{
+		{"keep all", 0, 0, []string{"failed-1", "failed-2", "pending-1", "succeeded-1", "succeeded-2", "succeeded-3"}},
+		{"newest succeeded", 2, 0, []string{"failed-1", "failed-2", "pending-1", "succeeded-2", "succeeded-3"}},
+		{"newest failed", 0, 1, []string{"failed-2", "pending-1", "succeeded-1", "succeeded-2", "succeeded-3"}},
+		{"pending never dropped", 1, 1, []string{"failed-2", "pending-1", "succeeded-3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			d := newTestDispatcher(t, dir, Config{KeepSucceeded: tt.keepSucceeded, KeepFailed: tt.keepFailed})
+
+			// Numbered by last update, oldest first
+			start := time.Now().Add(-time.Hour)
+			for i, id := range []string{"succeeded-1", "failed-1", "pending-1", "succeeded-2", "failed-2", "succeeded-3"} {
+				status := DeliveryStatus(id[:len(id)-2])
+				d.deliveries[id] = &Delivery{ID: id, JobID: id, Signed: true, Status: status, UpdatedAt: start.Add(time.Duration(i) * time.Minute)}
+				if err := d.secrets.Put(id, "secret-"+id); err != nil {
+					t.Fatalf("Put: %v", err)
+				}
+			}
+			d.mutex.Lock()
+			d.saveLocked()
+			d.mutex.Unlock()
+
+			// The pruned set is what a restarted server loads
+			reloaded := newTestDispatcher(t, dir, Config{})
+			var got []string
+			for _, delivery := range reloaded.List("") {
+				got = append(got, delivery.ID)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("kept %v, want %v", got, tt.want)
+			}
+
+			// Secrets go with the deliveries signed with them
+			for _, id := range []string{"succeeded-1", "failed-1", "pending-1", "succeeded-2", "failed-2", "succeeded-3"} {
+				if _, ok := reloaded.secrets.Get(id); ok != slices.Contains(tt.want, id) {
+					t.Errorf("secret of %s kept = %v, want %v", id, ok, !ok)
+				}
+			}
+		})
+	}
+}
+
+func TestDeliveryWithoutSecretFails(t *testing.T) {
+	server := newCallbackServer(t, http.StatusOK)
+	d := newTestDispatcher(t, "", Config{MaxAttempts: 1, Timeout: time.Second})
+	d.Enqueue(finishedJob("job-1", server.URL, true))
+	deliverDue(d)
+
+	if len(server.requests) != 0 {
+		t.Errorf("callback received %d requests, want none without the secret", len(server.requests))
+	}
+	if delivery := d.List("")[0]; delivery.Status != DeliveryFailed || delivery.LastError == "" {
+		t.Errorf("delivery = %s with error %q, want failed", delivery.Status, delivery.LastError)
+	}
+}
+
+func TestLegacyDeliverySecrets(t *testing.T) {
+	dir := t.TempDir()
+	legacy := `[{"id":"delivery-1","job_id":"job-1","url":"http://example.com","secret":"s3cret","body":{},"status":"pending"}]`
+	if err := os.WriteFile(filepath.Join(dir, "test-webhooks.json"), []byte(legacy), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	d := newTestDispatcher(t, dir, Config{})
+	if delivery, err := d.Get("delivery-1"); err != nil || !delivery.Signed {
+		t.Fatalf("Get = %+v, %v, want a signed delivery", delivery, err)
+	}
+	if secret, _ := d.secrets.Get("job-1"); secret != "s3cret" {
+		t.Errorf("stored secret = %q, want s3cret", secret)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "test-webhooks.json"))
+	if err != nil || strings.Contains(string(data), "s3cret") {
+		t.Errorf("delivery state = %s, %v, want it without the secret", data, err)
+	}
+}
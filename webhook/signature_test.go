@@ -0,0 +1,39 @@
+package webhook
+
+import "testing"
+
+func TestSign(t *testing.T) {
+	// printf '1700000000.{"job_id":"1"}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=61f3cec7c93547812a67ab2a72d759971124afcc20f39be5d3fae4dbde4f1d27"
+	if got := Sign("secret", "1700000000", []byte(`{"job_id":"1"}`)); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"job_id":"1"}`)
+	signature := Sign("secret", "1700000000", body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{"valid", "secret", "1700000000", body, signature, true},
+		{"other secret", "other", "1700000000", body, signature, false},
+		{"replayed with a new timestamp", "secret", "1700000060", body, signature, false},
+		{"changed body", "secret", "1700000000", []byte(`{"job_id":"2"}`), signature, false},
+		{"without prefix", "secret", "1700000000", body, signature[len("sha256="):], false},
+		{"empty signature", "secret", "1700000000", body, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.secret, tt.timestamp, tt.body, tt.signature); got != tt.want {
+				t.Errorf("Verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package webhook
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// CheckURL reports whether jobs may be submitted with the callback URL
+// It must be http or https, and name a host of the allowlist when there is one
+func (d *Dispatcher) CheckURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return errors.New("invalid callback URL: " + raw)
+	}
+	if !d.hostAllowed(parsed.Hostname()) {
+		return errors.New("callback host is not allowed: " + parsed.Hostname())
+	}
+	return nil
+}
+
+// hostAllowed matches a host against the allowlist, "*.example.com" allows the subdomains of example.com
+func (d *Dispatcher) hostAllowed(host string) bool {
+	if len(d.config.AllowedHosts) == 0 {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, allowed := range d.config.AllowedHosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == "" {
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok && strings.HasPrefix(suffix, ".") {
+			if strings.HasSuffix(host, suffix) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
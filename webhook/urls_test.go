@@ -0,0 +1,32 @@
+package webhook
+
+import "testing"
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		url     string
+		wantErr bool
+	}{
+		{"any host", nil, "https://hooks.example.com/done", false},
+		{"plain http", nil, "http://10.0.0.5:8080/done", false},
+		{"other scheme", nil, "ftp://example.com/done", true},
+		{"no scheme", nil, "example.com/done", true},
+		{"no host", nil, "http:///done", true},
+		{"allowed host", []string{"hooks.example.com"}, "https://Hooks.Example.com:8443/done", false},
+		{"host not allowed", []string{"hooks.example.com"}, "https://evil.example.org/done", true},
+		{"subdomain", []string{"*.example.com"}, "https://a.b.example.com/done", false},
+		{"wildcard excludes the domain", []string{"*.example.com"}, "https://example.com/done", true},
+		{"suffix is not a subdomain", []string{"*.example.com"}, "https://badexample.com/done", true},
+		{"blank entries are skipped", []string{"", "hooks.example.com"}, "https://hooks.example.com/done", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Dispatcher{config: Config{AllowedHosts: tt.allowed}}
+			if err := d.CheckURL(tt.url); (err != nil) != tt.wantErr {
+				t.Errorf("CheckURL(%q) = %v, want error %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}